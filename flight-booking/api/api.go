@@ -0,0 +1,531 @@
+// Package api serves flight search and booking as a JSON REST API:
+//
+//	GET    /api/flights                 search flights, see search
+//	GET    /api/flights/{id}/seats      seat map, ?format=ascii for text
+//	POST   /api/flights/{id}/quotes     price a seat: {"seat":"12A"}
+//	GET    /api/quotes/{id}             fetch a quote with its pricing audit
+//	POST   /api/flights/{id}/holds      hold a seat: {"seat":"12A",
+//	                                    "user":"alice","quote":"Q..."}
+//	GET    /api/bookings/{id}           fetch a booking
+//	POST   /api/bookings/{id}/confirm   pay and confirm: {"payment_method":"upi"}
+//	DELETE /api/bookings/{id}           cancel a held or confirmed booking
+//
+// Errors are {"error": "..."} with
+//
+//	400  invalid input or a quote for another seat
+//	402  the payment was declined
+//	403  a quote used by someone else
+//	404  unknown flight, seat, booking or quote
+//	409  the flight has departed, the seat is taken or blocked, the
+//	     quote expired or the booking is in the wrong state
+//	503  the booking workers are all busy
+//	504  the payment timed out
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"flight-booking/inventory"
+	"flight-booking/models"
+	"flight-booking/pricing"
+	"flight-booking/seatmap"
+	"flight-booking/service"
+	"flight-booking/strategy"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Server struct {
+	flights  *inventory.Inventory
+	pricing  *pricing.Engine
+	bookings *service.BookingStore
+	// requests feeds the booking worker pool
+	requests chan<- service.BookingRequest
+}
+
+func NewServer(flights *inventory.Inventory, engine *pricing.Engine, bookings *service.BookingStore, requests chan<- service.BookingRequest) *Server {
+	return &Server{
+		flights:  flights,
+		pricing:  engine,
+		bookings: bookings,
+		requests: requests,
+	}
+}
+
+// Register adds the API routes to mux
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/flights", s.search)
+	mux.HandleFunc("GET /api/flights/{id}/seats", s.seatMap)
+	mux.HandleFunc("POST /api/flights/{id}/quotes", s.quote)
+	mux.HandleFunc("GET /api/quotes/{id}", s.getQuote)
+	mux.HandleFunc("POST /api/flights/{id}/holds", s.hold)
+	mux.HandleFunc("GET /api/bookings/{id}", s.getBooking)
+	mux.HandleFunc("POST /api/bookings/{id}/confirm", s.confirm)
+	mux.HandleFunc("DELETE /api/bookings/{id}", s.cancel)
+}
+
+type flightView struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Origin      string               `json:"origin"`
+	Destination string               `json:"destination"`
+	Departure   time.Time            `json:"departure"`
+	Arrival     time.Time            `json:"arrival"`
+	Aircraft    string               `json:"aircraft"`
+	Price       float64              `json:"price"`
+	Seats       int                  `json:"seats"`
+	Available   int                  `json:"available"`
+	Cabins      map[models.Cabin]int `json:"cabins"`
+}
+
+type bookingView struct {
+	ID            string            `json:"id"`
+	FlightID      string            `json:"flight_id"`
+	Seat          string            `json:"seat"`
+	User          string            `json:"user"`
+	Status        string            `json:"status"`
+	Amount        float64           `json:"amount"`
+	PaymentMethod string            `json:"payment_method,omitempty"`
+	HoldExpiresAt *time.Time        `json:"hold_expires_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Quote         *models.FareQuote `json:"quote,omitempty"`
+}
+
+func newBookingView(b models.Booking) bookingView {
+	view := bookingView{
+		ID:            b.ID,
+		FlightID:      b.Flight.ID,
+		Seat:          b.SeatNo,
+		User:          b.UserName,
+		Status:        string(b.Status),
+		Amount:        b.Amount,
+		PaymentMethod: b.PaymentMethod,
+		CreatedAt:     b.CreatedAt,
+		Quote:         b.Quote,
+	}
+	if b.Status == models.BookingHeld || b.Status == models.BookingPaying {
+		expiry := b.HoldExpiry
+		view.HoldExpiresAt = &expiry
+	}
+	return view
+}
+
+// search lists the flights matching the query parameters, all optional:
+//
+//	origin, destination  airport codes
+//	from, to             first and last departure date, YYYY-MM-DD (UTC);
+//	                     flights that have left are never listed
+//	cabin                economy or business
+//	seats                available seats needed in the cabin
+//	sort                 departure (default) or price
+//
+// price is the current fare of the requested cabin, economy by default.
+// available counts the seats of the requested cabin; cabins has the
+// available seats of every cabin.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := s.flights.Search(query)
+	flights := make([]flightView, len(results))
+	for i, result := range results {
+		f := result.Flight
+		flights[i] = flightView{
+			ID:          f.ID,
+			Name:        f.Name,
+			Origin:      f.Origin,
+			Destination: f.Destination,
+			Departure:   f.Departure,
+			Arrival:     f.Arrival,
+			Aircraft:    f.Aircraft,
+			Price:       result.Price,
+			Seats:       f.Seats,
+			Available:   result.Available,
+			Cabins:      f.Availability(),
+		}
+	}
+	writeJSON(w, http.StatusOK, flights)
+}
+
+func parseQuery(r *http.Request) (inventory.Query, error) {
+	params := r.URL.Query()
+	query := inventory.Query{
+		Origin:      strings.TrimSpace(params.Get("origin")),
+		Destination: strings.TrimSpace(params.Get("destination")),
+	}
+	var err error
+	if from := params.Get("from"); from != "" {
+		if query.From, err = time.Parse(time.DateOnly, from); err != nil {
+			return query, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if query.To, err = time.Parse(time.DateOnly, to); err != nil {
+			return query, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		// to is inclusive
+		query.To = query.To.AddDate(0, 0, 1)
+	}
+	// Flights that have left are not on sale
+	if now := time.Now(); query.From.Before(now) {
+		query.From = now
+	}
+	if !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, fmt.Errorf("no departures between from and to")
+	}
+	if cabin := params.Get("cabin"); cabin != "" {
+		if query.Cabin, err = models.ParseCabin(strings.ToLower(cabin)); err != nil {
+			return query, err
+		}
+	}
+	if seats := params.Get("seats"); seats != "" {
+		if query.Seats, err = strconv.Atoi(seats); err != nil || query.Seats < 1 {
+			return query, fmt.Errorf("invalid seats %q, expected a positive number", seats)
+		}
+	}
+	query.Sort, err = inventory.ParseSort(params.Get("sort"))
+	return query, err
+}
+
+func (s *Server) seatMap(w http.ResponseWriter, r *http.Request) {
+	flight, ok := s.flight(w, r)
+	if !ok {
+		return
+	}
+	// Departed flights are shown without prices
+	prices, _ := s.pricing.PriceSeats(flight, time.Now())
+	seats := seatmap.New(flight, func(seat models.SeatSpec) float64 {
+		return prices[seat.Number]
+	})
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, seats)
+	case "ascii":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		seats.WriteASCII(w)
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json or ascii")
+	}
+}
+
+func (s *Server) quote(w http.ResponseWriter, r *http.Request) {
+	flight, ok := s.flight(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Seat string `json:"seat"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	body.Seat = strings.TrimSpace(body.Seat)
+	if body.Seat == "" {
+		writeError(w, http.StatusBadRequest, "seat is required")
+		return
+	}
+	quote, err := s.pricing.Quote(flight, body.Seat)
+	if err != nil {
+		writeSeatError(w, body.Seat, err)
+		return
+	}
+	w.Header().Set("Location", "/api/quotes/"+quote.ID)
+	writeJSON(w, http.StatusCreated, quote)
+}
+
+func (s *Server) getQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := s.pricing.Lookup(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, quote)
+}
+
+// hold holds a seat at the fare of the quote in the request, or at a
+// fresh quote if there is none
+func (s *Server) hold(w http.ResponseWriter, r *http.Request) {
+	flight, ok := s.flight(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Seat  string `json:"seat"`
+		User  string `json:"user"`
+		Quote string `json:"quote"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	body.Seat, body.User = strings.TrimSpace(body.Seat), strings.TrimSpace(body.User)
+	if body.Seat == "" || body.User == "" {
+		writeError(w, http.StatusBadRequest, "seat and user are required")
+		return
+	}
+
+	var quote models.FareQuote
+	var err error
+	if body.Quote == "" {
+		quote, err = s.pricing.Quote(flight, body.Seat)
+		if err != nil {
+			writeQuoteError(w, body.Seat, err)
+			return
+		}
+	}
+	// The seat is held by the booking, so its ID is chosen first
+	booking := models.Booking{
+		ID:       s.bookings.NewID(),
+		UserName: body.User,
+		Flight:   flight,
+		SeatNo:   body.Seat,
+		Status:   models.BookingHeld,
+	}
+	booking.HoldExpiry, err = flight.HoldSeat(body.Seat, booking.Holder())
+	if err != nil {
+		writeSeatError(w, body.Seat, err)
+		return
+	}
+	// A quote is claimed by the booking, so only once the seat is held
+	if body.Quote != "" {
+		quote, err = s.pricing.Honour(body.Quote, flight.ID, body.Seat, booking.Holder())
+		if err != nil {
+			flight.ReleaseSeat(body.Seat, booking.Holder())
+			writeQuoteError(w, body.Seat, err)
+			return
+		}
+	}
+	booking.Amount, booking.Quote = quote.Price, &quote
+	s.pricing.RecordHold(flight.ID, time.Now())
+	created, err := s.bookings.Create(booking)
+	if err != nil {
+		flight.ReleaseSeat(body.Seat, booking.Holder())
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/bookings/"+created.ID)
+	writeJSON(w, http.StatusCreated, newBookingView(created))
+}
+
+func (s *Server) getBooking(w http.ResponseWriter, r *http.Request) {
+	booking, err := s.bookings.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newBookingView(booking))
+}
+
+// confirm pays for a held booking on the worker pool. A declined or
+// timed out payment keeps the hold so another method can be tried. The
+// quoted fare is charged while the quote is valid; after that the seat
+// is priced again, and a different fare must be confirmed again.
+func (s *Server) confirm(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	payment, ok := strategy.ByName(body.PaymentMethod)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown payment method %q, expected one of %s",
+			body.PaymentMethod, strings.Join(strategy.Methods(), ", ")))
+		return
+	}
+
+	// Mark the booking as paying so it cannot be paid or cancelled twice
+	id := r.PathValue("id")
+	repriced := false
+	booking, err := s.bookings.Update(id, func(b *models.Booking) error {
+		if b.Status != models.BookingHeld {
+			return bookingStateError(b)
+		}
+		if b.Quote != nil && !b.Quote.Valid(time.Now()) {
+			quote, err := s.pricing.Quote(b.Flight, b.SeatNo)
+			if err != nil {
+				return err
+			}
+			repriced = quote.Price != b.Amount
+			b.Quote, b.Amount = &quote, quote.Price
+			if repriced {
+				return nil
+			}
+		}
+		b.Status = models.BookingPaying
+		return nil
+	})
+	if err != nil {
+		writeBookingError(w, err)
+		return
+	}
+	if repriced {
+		writeError(w, http.StatusConflict, fmt.Sprintf("fare quote expired, the fare is now %.2f; confirm again to pay it", booking.Amount))
+		return
+	}
+
+	results := make(chan service.BookingResult, 1)
+	select {
+	case s.requests <- service.BookingRequest{Booking: booking, Payment: payment, Held: true, Result: results}:
+	default:
+		s.bookings.Update(id, func(b *models.Booking) error {
+			b.Status = models.BookingHeld
+			return nil
+		})
+		writeError(w, http.StatusServiceUnavailable, "all booking workers are busy, try again")
+		return
+	}
+	result := <-results
+
+	booking, _ = s.bookings.Update(id, func(b *models.Booking) error {
+		switch {
+		case result.Err == nil:
+			b.Status = models.BookingConfirmed
+			b.PaymentMethod = strings.ToLower(body.PaymentMethod)
+		case errors.Is(result.Err, service.ErrPaymentFailed), errors.Is(result.Err, service.ErrPaymentTimeout):
+			b.Status = models.BookingHeld
+			b.HoldExpiry = result.HoldExpiry
+		case errors.Is(result.Err, service.ErrRefundDue):
+			b.Status = models.BookingRefundDue
+			b.PaymentMethod = strings.ToLower(body.PaymentMethod)
+		default:
+			// The hold lapsed or was lost
+			b.Status = models.BookingExpired
+		}
+		return nil
+	})
+
+	switch {
+	case result.Err == nil:
+		writeJSON(w, http.StatusOK, newBookingView(booking))
+	case errors.Is(result.Err, service.ErrPaymentFailed):
+		writeError(w, http.StatusPaymentRequired, "payment was declined")
+	case errors.Is(result.Err, service.ErrPaymentTimeout):
+		writeError(w, http.StatusGatewayTimeout, "payment timed out")
+	case errors.Is(result.Err, service.ErrRefundDue):
+		writeError(w, http.StatusConflict, "the hold lapsed while paying; the payment will be refunded")
+	default:
+		writeSeatError(w, booking.SeatNo, result.Err)
+	}
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	booking, err := s.bookings.Update(r.PathValue("id"), func(b *models.Booking) error {
+		switch b.Status {
+		case models.BookingHeld, models.BookingExpired:
+			// An expired hold may already be gone, and the seat held by
+			// another booking; either way this booking no longer holds it
+			err := b.Flight.ReleaseSeat(b.SeatNo, b.Holder())
+			if err != nil && !errors.Is(err, models.ErrHoldExpired) && !errors.Is(err, models.ErrNotHeld) && !errors.Is(err, models.ErrNotHolder) {
+				return err
+			}
+		case models.BookingConfirmed:
+			if err := b.Flight.CancelSeat(b.SeatNo, b.Holder()); err != nil {
+				return err
+			}
+		default:
+			return bookingStateError(b)
+		}
+		b.Status = models.BookingCancelled
+		return nil
+	})
+	if err != nil {
+		writeBookingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBookingView(booking))
+}
+
+// flight looks up the flight named in the path, answering 404 if unknown
+func (s *Server) flight(w http.ResponseWriter, r *http.Request) (*models.Flight, bool) {
+	flight, ok := s.flights.Flight(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("flight %s not found", r.PathValue("id")))
+	}
+	return flight, ok
+}
+
+// stateError is a booking that cannot be changed in its current status
+type stateError struct {
+	status models.BookingStatus
+}
+
+func (e stateError) Error() string {
+	if e.status == models.BookingExpired {
+		return "hold expired"
+	}
+	return "booking is " + string(e.status)
+}
+
+func bookingStateError(b *models.Booking) error {
+	return stateError{status: b.Status}
+}
+
+func writeBookingError(w http.ResponseWriter, err error) {
+	var state stateError
+	switch {
+	case errors.Is(err, service.ErrBookingNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.As(err, &state):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeQuoteError(w http.ResponseWriter, seatNo string, err error) {
+	switch {
+	case errors.Is(err, pricing.ErrQuoteNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, pricing.ErrQuoteMismatch):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pricing.ErrQuoteExpired):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, pricing.ErrQuoteClaimed):
+		writeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeSeatError(w, seatNo, err)
+	}
+}
+
+func writeSeatError(w http.ResponseWriter, seatNo string, err error) {
+	switch {
+	case errors.Is(err, models.ErrSeatNotFound):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("seat %s not found", seatNo))
+	case errors.Is(err, models.ErrSeatTaken):
+		writeError(w, http.StatusConflict, fmt.Sprintf("seat %s is taken", seatNo))
+	case errors.Is(err, models.ErrSeatBlocked):
+		writeError(w, http.StatusConflict, fmt.Sprintf("seat %s is blocked", seatNo))
+	case errors.Is(err, models.ErrDeparted):
+		writeError(w, http.StatusConflict, "the flight has departed")
+	case errors.Is(err, models.ErrNotHeld), errors.Is(err, models.ErrNotHolder),
+		errors.Is(err, models.ErrHoldExpired), errors.Is(err, models.ErrNotBooked):
+		writeError(w, http.StatusConflict, fmt.Sprintf("seat %s: %v", seatNo, err))
+	default:
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("seat %s: %v", seatNo, err))
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,286 @@
+package api_test
+
+import (
+	"encoding/json"
+	"flight-booking/api"
+	"flight-booking/inventory"
+	"flight-booking/models"
+	"flight-booking/pricing"
+	"flight-booking/repository"
+	"flight-booking/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testAPI struct {
+	url    string
+	flight *models.Flight
+	engine *pricing.Engine
+}
+
+// newAPI serves the API for one flight of a business seat and two
+// economy seats, departing in ten days, with one booking worker
+func newAPI(t *testing.T) *testAPI {
+	t.Helper()
+	f := models.NewFlight("T100", "Test Air", 1000, []models.SeatSpec{
+		{Number: "1A", Cabin: models.Business, Row: 1, Column: "A"},
+		{Number: "2A", Cabin: models.Economy, Row: 2, Column: "A"},
+		{Number: "2B", Cabin: models.Economy, Row: 2, Column: "B"},
+	})
+	f.Fares = map[models.SeatClass]float64{models.ClassEconomy: 1000, models.ClassBusiness: 3500}
+	f.Origin, f.Destination = "DEL", "BOM"
+	f.Departure = time.Now().Add(10 * 24 * time.Hour)
+
+	inv, err := inventory.New([]*models.Flight{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookings, err := service.NewBookingStore(repository.NewMemory(), inv.Flights())
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan service.BookingRequest, 1)
+	var wg sync.WaitGroup
+	service.StartWorkerPool(requests, 1, &wg)
+
+	engine := pricing.NewEngine()
+	mux := http.NewServeMux()
+	api.NewServer(inv, engine, bookings, requests).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		close(requests)
+		wg.Wait()
+	})
+	return &testAPI{url: srv.URL, flight: f, engine: engine}
+}
+
+// do sends a request with a JSON body, or none if body is empty, and
+// decodes the JSON response into out unless out is nil
+func (a *testAPI) do(t *testing.T, method, path, body string, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, a.url+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type booking struct {
+	ID     string  `json:"id"`
+	Seat   string  `json:"seat"`
+	Status string  `json:"status"`
+	Amount float64 `json:"amount"`
+	Error  string  `json:"error"`
+}
+
+func TestSearch(t *testing.T) {
+	a := newAPI(t)
+	departure := a.flight.Departure.UTC().Format(time.DateOnly)
+	tests := []struct {
+		query  string
+		status int
+		// found is the number of flights listed
+		found int
+	}{
+		{"", http.StatusOK, 1},
+		{"?origin=del&destination=BOM&cabin=Business&seats=1&sort=price", http.StatusOK, 1},
+		{"?from=" + departure + "&to=" + departure, http.StatusOK, 1},
+		{"?destination=LHR", http.StatusOK, 0},
+		{"?cabin=economy&seats=3", http.StatusOK, 0},
+		{"?from=tomorrow", http.StatusBadRequest, 0},
+		{"?to=2001-01-01", http.StatusBadRequest, 0},
+		{"?cabin=first", http.StatusBadRequest, 0},
+		{"?seats=0", http.StatusBadRequest, 0},
+		{"?sort=seats", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var flights []struct {
+				ID string `json:"id"`
+			}
+			var out interface{} = &flights
+			if tt.status != http.StatusOK {
+				out = nil
+			}
+			if status := a.do(t, "GET", "/api/flights"+tt.query, "", out); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if len(flights) != tt.found {
+				t.Errorf("found %d flights, want %d", len(flights), tt.found)
+			}
+		})
+	}
+}
+
+func TestSeatMap(t *testing.T) {
+	a := newAPI(t)
+	var m struct {
+		Rows []struct {
+			Seats []struct {
+				Number string  `json:"number"`
+				Price  float64 `json:"price"`
+			} `json:"seats"`
+		} `json:"rows"`
+	}
+	if status := a.do(t, "GET", "/api/flights/T100/seats", "", &m); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	// Seats are priced like quotes
+	for _, row := range m.Rows {
+		for _, seat := range row.Seats {
+			quote, err := a.engine.Price(a.flight, seat.Number, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seat.Price != quote.Price {
+				t.Errorf("seat %s priced %v, quoted %v", seat.Number, seat.Price, quote.Price)
+			}
+		}
+	}
+
+	resp, err := http.Get(a.url + "/api/flights/T100/seats?format=ascii")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || !strings.Contains(string(text), "T100") {
+		t.Errorf("ascii map = %s %q", resp.Header.Get("Content-Type"), text)
+	}
+
+	if status := a.do(t, "GET", "/api/flights/T100/seats?format=xml", "", nil); status != http.StatusBadRequest {
+		t.Errorf("format=xml: status = %d, want 400", status)
+	}
+	if status := a.do(t, "GET", "/api/flights/T999/seats", "", nil); status != http.StatusNotFound {
+		t.Errorf("unknown flight: status = %d, want 404", status)
+	}
+}
+
+func TestHoldAtQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		// hold is the body of the hold, with QUOTE standing for the ID
+		// of a quote for seat 2A
+		hold   string
+		status int
+	}{
+		{"quoted seat", `{"seat":"2A","user":"alice","quote":"QUOTE"}`, http.StatusCreated},
+		{"without a quote", `{"seat":"2A","user":"alice"}`, http.StatusCreated},
+		{"another seat", `{"seat":"2B","user":"alice","quote":"QUOTE"}`, http.StatusBadRequest},
+		{"unknown quote", `{"seat":"2A","user":"alice","quote":"Q0"}`, http.StatusNotFound},
+		{"unknown seat", `{"seat":"9Z","user":"alice"}`, http.StatusNotFound},
+		{"no user", `{"seat":"2A"}`, http.StatusBadRequest},
+		{"unknown field", `{"seat":"2A","user":"alice","class":"first"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAPI(t)
+			var quote models.FareQuote
+			if status := a.do(t, "POST", "/api/flights/T100/quotes", `{"seat":"2A"}`, &quote); status != http.StatusCreated {
+				t.Fatalf("quote: status = %d", status)
+			}
+			var held booking
+			status := a.do(t, "POST", "/api/flights/T100/holds", strings.ReplaceAll(tt.hold, "QUOTE", quote.ID), &held)
+			if status != tt.status {
+				t.Fatalf("hold: status = %d (%s), want %d", status, held.Error, tt.status)
+			}
+			if status != http.StatusCreated {
+				// A refused hold leaves every seat available
+				if seats := a.flight.AvailableSeats(); len(seats) != 3 {
+					t.Errorf("available seats = %v after a refused hold", seats)
+				}
+				return
+			}
+			if held.Status != "held" || held.Amount != quote.Price {
+				t.Errorf("booking = %+v, want held at %v", held, quote.Price)
+			}
+			var fetched booking
+			if status := a.do(t, "GET", "/api/bookings/"+held.ID, "", &fetched); status != http.StatusOK || fetched != held {
+				t.Errorf("GET booking = %d %+v, want %+v", status, fetched, held)
+			}
+		})
+	}
+}
+
+func TestQuoteBelongsToOneBooking(t *testing.T) {
+	a := newAPI(t)
+	var quote models.FareQuote
+	a.do(t, "POST", "/api/flights/T100/quotes", `{"seat":"2A"}`, &quote)
+	var held booking
+	if status := a.do(t, "POST", "/api/flights/T100/holds", `{"seat":"2A","user":"alice","quote":"`+quote.ID+`"}`, &held); status != http.StatusCreated {
+		t.Fatalf("hold: status = %d", status)
+	}
+	if status := a.do(t, "DELETE", "/api/bookings/"+held.ID, "", nil); status != http.StatusOK {
+		t.Fatalf("cancel: status = %d", status)
+	}
+	// The seat is free again but the quote was claimed by alice's booking
+	if status := a.do(t, "POST", "/api/flights/T100/holds", `{"seat":"2A","user":"bob","quote":"`+quote.ID+`"}`, nil); status != http.StatusForbidden {
+		t.Errorf("reusing the quote: status = %d, want 403", status)
+	}
+	if seats := a.flight.AvailableSeats(); len(seats) != 3 {
+		t.Errorf("available seats = %v after a refused hold", seats)
+	}
+}
+
+func TestConfirmAndCancel(t *testing.T) {
+	a := newAPI(t)
+	var held booking
+	if status := a.do(t, "POST", "/api/flights/T100/holds", `{"seat":"2A","user":"alice"}`, &held); status != http.StatusCreated {
+		t.Fatalf("hold: status = %d", status)
+	}
+	if status := a.do(t, "POST", "/api/flights/T100/holds", `{"seat":"2A","user":"bob"}`, nil); status != http.StatusConflict {
+		t.Errorf("holding a held seat: status = %d, want 409", status)
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		// want is the booking status after the step
+		want string
+	}{
+		{"unknown payment method", "POST", "/confirm", `{"payment_method":"cash"}`, http.StatusBadRequest, "held"},
+		{"confirm", "POST", "/confirm", `{"payment_method":"card"}`, http.StatusOK, "confirmed"},
+		{"confirm twice", "POST", "/confirm", `{"payment_method":"card"}`, http.StatusConflict, "confirmed"},
+		{"cancel", "DELETE", "", "", http.StatusOK, "cancelled"},
+		{"cancel twice", "DELETE", "", "", http.StatusConflict, "cancelled"},
+	}
+	for _, step := range steps {
+		if status := a.do(t, step.method, "/api/bookings/"+held.ID+step.path, step.body, nil); status != step.status {
+			t.Fatalf("%s: status = %d, want %d", step.name, status, step.status)
+		}
+		var b booking
+		a.do(t, "GET", "/api/bookings/"+held.ID, "", &b)
+		if b.Status != step.want {
+			t.Fatalf("after %s the booking is %s, want %s", step.name, b.Status, step.want)
+		}
+	}
+	if status, _ := a.flight.SeatStatus("2A"); status != models.Available {
+		t.Errorf("seat of the cancelled booking is %s, want available", status)
+	}
+	if status := a.do(t, "DELETE", "/api/bookings/BK0", "", nil); status != http.StatusNotFound {
+		t.Errorf("unknown booking: status = %d, want 404", status)
+	}
+}
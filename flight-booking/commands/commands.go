@@ -0,0 +1,404 @@
+// Package commands exposes flight bookings as tcp-adapter commands for
+// the ticket counter terminals:
+//
+//	SEATS:                  -> SEATS:1A,1C,...   (available seats)
+//	QUOTE:<seat>            -> QUOTED:<seat>     (fare quote, see below)
+//	HOLD:<seat>             -> HELD:<seat>
+//	EXTEND:<seat>           -> EXTENDED:<seat>   (restarts the hold period)
+//	CONFIRM:<seat>:<method> -> CONFIRMED:<seat>  (pays for a held seat)
+//	RELEASE:<seat>          -> RELEASED:<seat>
+//	BOOK:<seat>:<method>    -> BOOKED:<seat>     (hold, pay and confirm)
+//
+// Every command acts on the flight named in a "flight" header, or on the
+// first flight of the inventory without one; SEATS, QUOTED, CONFIRMED and
+// BOOKED name the flight in the same header.
+//
+// Holds belong to the connection and are released when it closes.
+// Signed requests hold seats for their signing key instead, or for a
+// customer of that key named in a "holder" header; unsigned requests may
+// not name a holder. Only the holder may extend, confirm or release a
+// hold. HELD and EXTENDED carry the hold's
+// expiry in an "expires" header.
+//
+// QUOTED carries the fare in an "amount" header and the quote ID in a
+// "quote" header, valid until "expires". CONFIRM and BOOK charge the fare
+// of the quote named in a "quote" header, or the current fare. A quote
+// is claimed by the first holder to use it.
+//
+// Methods are the names accepted by strategy.ByName. Failures are error
+// frames:
+//
+//	NOT_FOUND         unknown flight, seat or quote
+//	FORBIDDEN         someone else's hold or quote
+//	BAD_REQUEST       a quote for another seat
+//	CONFLICT          the flight has departed, the seat is blocked or in
+//	                  the wrong state, or the hold or quote expired
+//	PAYMENT_REQUIRED  the payment was declined
+//	TIMEOUT           the payment took longer than service.PaymentTimeout
+//
+// A payment taken for a seat that can no longer be confirmed is recorded
+// as due for refund and reported as CONFLICT.
+package commands
+
+import (
+	"errors"
+	"flight-booking/inventory"
+	"flight-booking/models"
+	"flight-booking/pricing"
+	"flight-booking/service"
+	"flight-booking/strategy"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/signing"
+)
+
+// Request and response headers
+const (
+	HeaderFlight  = "flight"
+	HeaderAmount  = "amount"
+	HeaderHolder  = "holder"
+	HeaderExpires = "expires"
+	HeaderQuote   = "quote"
+)
+
+// Register adds the booking commands for the flights of inv, priced by
+// engine, to router
+func Register(router *handler.Router, inv *inventory.Inventory, engine *pricing.Engine) {
+	b := &bookings{inventory: inv, pricing: engine}
+	router.Handle("SEATS", b.seats)
+	router.Handle("QUOTE", b.quote)
+	router.Handle("HOLD", b.hold)
+	router.Handle("EXTEND", b.extend)
+	router.Handle("CONFIRM", b.confirm)
+	router.Handle("RELEASE", b.release)
+	router.Handle("BOOK", b.book)
+}
+
+type bookings struct {
+	inventory *inventory.Inventory
+	pricing   *pricing.Engine
+}
+
+// flightOf resolves the flight named in the "flight" header, or the first
+// flight of the inventory if there is none
+func (b *bookings) flightOf(msg *protocol.Message) (*models.Flight, *protocol.Error) {
+	id := strings.TrimSpace(msg.Header(HeaderFlight))
+	if id == "" {
+		flights := b.inventory.Flights()
+		if len(flights) == 0 {
+			return nil, protocol.NewError(protocol.CodeNotFound, "No flights on sale")
+		}
+		return flights[0], nil
+	}
+	flight, ok := b.inventory.Flight(id)
+	if !ok {
+		return nil, protocol.Errorf(protocol.CodeNotFound, "No flight %s", id)
+	}
+	return flight, nil
+}
+
+func (b *bookings) seats(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	response := protocol.NewMessage("SEATS", strings.Join(flight.AvailableSeats(), ","))
+	response.SetHeader(HeaderFlight, flight.ID)
+	return response
+}
+
+func (b *bookings) quote(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo := strings.TrimSpace(msg.Payload)
+	if seatNo == "" {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Seat number is required")
+	}
+	quote, err := b.pricing.Quote(flight, seatNo)
+	if err != nil {
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	response := protocol.NewMessage("QUOTED", seatNo)
+	response.SetHeader(HeaderFlight, flight.ID)
+	response.SetHeader(HeaderQuote, quote.ID)
+	response.SetHeader(HeaderAmount, formatAmount(quote.Price))
+	response.SetHeader(HeaderExpires, quote.ExpiresAt.UTC().Format(time.RFC3339))
+	return response
+}
+
+func (b *bookings) hold(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo := strings.TrimSpace(msg.Payload)
+	if seatNo == "" {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Seat number is required")
+	}
+	holder, perr := holderOf(ctx, msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	expires, err := flight.HoldSeat(seatNo, holder)
+	if err != nil {
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	b.pricing.RecordHold(flight.ID, time.Now())
+	return held("HELD", seatNo, expires)
+}
+
+func (b *bookings) extend(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo := strings.TrimSpace(msg.Payload)
+	if seatNo == "" {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Seat number is required")
+	}
+	holder, perr := holderOf(ctx, msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	expires, err := flight.ExtendHold(seatNo, holder)
+	if err != nil {
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	return held("EXTENDED", seatNo, expires)
+}
+
+// confirm pays for a held seat. A declined or timed out payment keeps
+// the hold, so the customer can try another method or RELEASE.
+func (b *bookings) confirm(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo, payment, perr := parsePayment("CONFIRM", msg.Payload)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	holder, perr := holderOf(ctx, msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	// Check the hold before charging or claiming a quote; ExtendHold
+	// also keeps it from lapsing while the payment runs
+	if _, err := flight.ExtendHold(seatNo, holder); err != nil {
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	price, perr := b.fare(flight, msg, seatNo, holder)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+
+	start := time.Now()
+	if err := service.Charge(payment, price, service.PaymentTimeout); err != nil {
+		return b.paymentError(seatNo, err, start).ToMessage()
+	}
+	if err := service.ConfirmPaid(flight, seatNo, holder, price); err != nil {
+		service.GlobalMetrics.Record(time.Since(start), "failed")
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	service.GlobalMetrics.Record(time.Since(start), "success")
+	return paid(flight, "CONFIRMED", seatNo, price)
+}
+
+func (b *bookings) release(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo := strings.TrimSpace(msg.Payload)
+	if seatNo == "" {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Seat number is required")
+	}
+	holder, perr := holderOf(ctx, msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	if err := flight.ReleaseSeat(seatNo, holder); err != nil {
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	return protocol.NewMessage("RELEASED", seatNo)
+}
+
+// book holds, pays for and confirms a seat in one step, like the
+// booking workers. The seat is released again if the payment fails.
+func (b *bookings) book(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	flight, perr := b.flightOf(msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	seatNo, payment, perr := parsePayment("BOOK", msg.Payload)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+	holder, perr := holderOf(ctx, msg)
+	if perr != nil {
+		return perr.ToMessage()
+	}
+
+	start := time.Now()
+	if _, err := flight.HoldSeat(seatNo, holder); err != nil {
+		if err != models.ErrSeatNotFound {
+			service.GlobalMetrics.Record(time.Since(start), "failed")
+		}
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	price, perr := b.fare(flight, msg, seatNo, holder)
+	if perr != nil {
+		flight.ReleaseSeat(seatNo, holder)
+		return perr.ToMessage()
+	}
+	b.pricing.RecordHold(flight.ID, start)
+	if err := service.Charge(payment, price, service.PaymentTimeout); err != nil {
+		flight.ReleaseSeat(seatNo, holder)
+		return b.paymentError(seatNo, err, start).ToMessage()
+	}
+	if err := service.ConfirmPaid(flight, seatNo, holder, price); err != nil {
+		service.GlobalMetrics.Record(time.Since(start), "failed")
+		return seatError(flight, seatNo, err).ToMessage()
+	}
+	service.GlobalMetrics.Record(time.Since(start), "success")
+	return paid(flight, "BOOKED", seatNo, price)
+}
+
+// fare returns the fare of the quote in the "quote" header, or the
+// current fare of the seat. The quote is claimed for holder.
+func (b *bookings) fare(flight *models.Flight, msg *protocol.Message, seatNo, holder string) (float64, *protocol.Error) {
+	id := strings.TrimSpace(msg.Header(HeaderQuote))
+	if id == "" {
+		quote, err := b.pricing.Price(flight, seatNo, time.Now())
+		if err != nil {
+			return 0, seatError(flight, seatNo, err)
+		}
+		return quote.Price, nil
+	}
+	quote, err := b.pricing.Honour(id, flight.ID, seatNo, holder)
+	switch {
+	case errors.Is(err, pricing.ErrQuoteNotFound):
+		return 0, protocol.Errorf(protocol.CodeNotFound, "No quote %s", id)
+	case errors.Is(err, pricing.ErrQuoteMismatch):
+		return 0, protocol.Errorf(protocol.CodeBadRequest, "Quote %s is not for seat %s", id, seatNo)
+	case errors.Is(err, pricing.ErrQuoteExpired):
+		return 0, protocol.Errorf(protocol.CodeConflict, "Quote %s expired", id)
+	case errors.Is(err, pricing.ErrQuoteClaimed):
+		return 0, protocol.Errorf(protocol.CodeForbidden, "Quote %s was used by someone else", id)
+	}
+	return quote.Price, nil
+}
+
+// parsePayment splits a <seat>:<method> payload
+func parsePayment(command, payload string) (string, strategy.PaymentStrategy, *protocol.Error) {
+	seatNo, method, ok := strings.Cut(strings.TrimSpace(payload), ":")
+	if !ok {
+		return "", nil, protocol.Errorf(protocol.CodeBadRequest, "Expected %s:<seat>:<%s>", command, strings.Join(strategy.Methods(), "|"))
+	}
+	payment, ok := strategy.ByName(method)
+	if !ok {
+		return "", nil, protocol.Errorf(protocol.CodeBadRequest, "Unknown payment method %q", method)
+	}
+	seatNo = strings.TrimSpace(seatNo)
+	if seatNo == "" {
+		return "", nil, protocol.NewError(protocol.CodeBadRequest, "Seat number is required")
+	}
+	return seatNo, payment, nil
+}
+
+// holderOf identifies who holds seats for a request. Only a verified
+// signing key may name a holder, and the name is scoped to that key, so
+// no client can act on holds made by another connection or terminal.
+func holderOf(ctx *handler.Context, msg *protocol.Message) (string, *protocol.Error) {
+	name := strings.TrimSpace(msg.Header(HeaderHolder))
+	keyID := signing.KeyID(ctx)
+	if keyID == "" {
+		if name != "" {
+			return "", protocol.NewError(protocol.CodeForbidden, "A holder can only be named in a signed request")
+		}
+		return connHolder(ctx), nil
+	}
+	if name == "" {
+		return "key:" + keyID, nil
+	}
+	return "key:" + keyID + "/" + name, nil
+}
+
+// connHolder is the holder of a connection's unsigned holds. It uses the
+// connection ID, as a remote address can be reused by a later client or
+// shared by several behind NAT.
+func connHolder(ctx *handler.Context) string {
+	return "conn:" + ctx.ID
+}
+
+// Disconnect returns a callback that releases the unsigned holds of a
+// connection once it has gone, for the adapter's OnDisconnect. Holds of
+// signed requests belong to the key and survive the connection.
+func Disconnect(inv *inventory.Inventory) func(ctx *handler.Context) {
+	return func(ctx *handler.Context) {
+		holder := connHolder(ctx)
+		for _, flight := range inv.Flights() {
+			if released := flight.ReleaseHolder(holder); len(released) > 0 {
+				log.Printf("Released seats %s on flight %s held by %s", strings.Join(released, ","), flight.ID, ctx.RemoteAddr)
+			}
+		}
+	}
+}
+
+// seatError converts an error of a seat operation to an error frame
+func seatError(flight *models.Flight, seatNo string, err error) *protocol.Error {
+	switch {
+	case errors.Is(err, service.ErrRefundDue):
+		return protocol.Errorf(protocol.CodeConflict, "Hold on seat %s lapsed while paying; the payment will be refunded", seatNo)
+	case errors.Is(err, models.ErrSeatNotFound):
+		return protocol.Errorf(protocol.CodeNotFound, "No seat %s on flight %s", seatNo, flight.ID)
+	case errors.Is(err, models.ErrNotHolder):
+		return protocol.Errorf(protocol.CodeForbidden, "Seat %s is held by someone else", seatNo)
+	case errors.Is(err, models.ErrHoldExpired):
+		return protocol.Errorf(protocol.CodeConflict, "Hold on seat %s expired", seatNo)
+	case errors.Is(err, models.ErrSeatBlocked):
+		return protocol.Errorf(protocol.CodeConflict, "Seat %s is blocked", seatNo)
+	case errors.Is(err, models.ErrDeparted):
+		return protocol.Errorf(protocol.CodeConflict, "Flight %s has departed", flight.ID)
+	case errors.Is(err, models.ErrSeatTaken), errors.Is(err, models.ErrNotHeld):
+		status, _ := flight.SeatStatus(seatNo)
+		return protocol.Errorf(protocol.CodeConflict, "Seat %s is %s", seatNo, status)
+	}
+	return protocol.Errorf(protocol.CodeInternal, "Seat %s: %v", seatNo, err)
+}
+
+// paymentError records a failed payment and converts it to an error frame
+func (b *bookings) paymentError(seatNo string, err error, start time.Time) *protocol.Error {
+	if err == service.ErrPaymentTimeout {
+		service.GlobalMetrics.Record(time.Since(start), "timeout")
+		return protocol.Errorf(protocol.CodeTimeout, "Payment for seat %s timed out", seatNo)
+	}
+	service.GlobalMetrics.Record(time.Since(start), "failed")
+	return protocol.Errorf(protocol.CodePaymentRequired, "Payment for seat %s was declined", seatNo)
+}
+
+// held builds the answer for a new or extended hold
+func held(command, seatNo string, expires time.Time) *protocol.Message {
+	response := protocol.NewMessage(command, seatNo)
+	response.SetHeader(HeaderExpires, expires.UTC().Format(time.RFC3339))
+	return response
+}
+
+// paid builds the answer for a confirmed seat
+func paid(flight *models.Flight, command, seatNo string, amount float64) *protocol.Message {
+	response := protocol.NewMessage(command, seatNo)
+	response.SetHeader(HeaderFlight, flight.ID)
+	response.SetHeader(HeaderAmount, formatAmount(amount))
+	return response
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,143 @@
+package commands_test
+
+import (
+	"flight-booking/commands"
+	"flight-booking/inventory"
+	"flight-booking/models"
+	"flight-booking/pricing"
+	"testing"
+	"time"
+
+	"tcp-adapter/pkg/adaptertest"
+	"tcp-adapter/pkg/protocol"
+)
+
+// newServer serves the booking commands for two flights of two seats
+func newServer(t *testing.T) *adaptertest.Server {
+	t.Helper()
+	var flights []*models.Flight
+	for _, id := range []string{"T100", "T200"} {
+		flights = append(flights, models.NewFlight(id, "Test Air", 1000, []models.SeatSpec{
+			{Number: "1A", Cabin: models.Economy, Row: 1, Column: "A"},
+			{Number: "1B", Cabin: models.Economy, Row: 1, Column: "B"},
+		}))
+	}
+	inv, err := inventory.New(flights, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := adaptertest.NewServer(t)
+	commands.Register(srv.Router(), inv, pricing.NewEngine())
+	srv.Adapter.OnDisconnect(commands.Disconnect(inv))
+	return srv
+}
+
+// request builds a message with headers given as name, value pairs
+func request(t *testing.T, s string, headers ...string) *protocol.Message {
+	msg := adaptertest.ParseMessage(t, s)
+	for i := 0; i+1 < len(headers); i += 2 {
+		msg.SetHeader(headers[i], headers[i+1])
+	}
+	return msg
+}
+
+func TestHoldAndBook(t *testing.T) {
+	c := newServer(t).Client()
+	c.Run(`
+		SEATS: => SEATS:1A,1B
+		HOLD:1A => HELD:1A
+		EXTEND:1A => EXTENDED:1A
+		SEATS: => SEATS:1B
+		RELEASE:1A => RELEASED:1A
+		BOOK:1B:card => BOOKED:1B
+		SEATS: => SEATS:1A
+	`)
+	c.ExpectError("HOLD:1B", protocol.CodeConflict)
+	c.ExpectError("HOLD:9Z", protocol.CodeNotFound)
+	c.ExpectError("BOOK:1A", protocol.CodeBadRequest)
+	c.ExpectError("BOOK:1A:cash", protocol.CodeBadRequest)
+	c.ExpectError("CONFIRM:1A:card", protocol.CodeConflict)
+}
+
+// TestHoldsBelongToTheConnection uses net.Pipe clients, which all have
+// the same remote address, so holds must be told apart by connection
+func TestHoldsBelongToTheConnection(t *testing.T) {
+	srv := newServer(t)
+	alice, bob := srv.Client(), srv.Client()
+
+	alice.Expect("HOLD:1A", "HELD:1A")
+	bob.ExpectError("EXTEND:1A", protocol.CodeForbidden)
+	bob.ExpectError("RELEASE:1A", protocol.CodeForbidden)
+	bob.ExpectError("CONFIRM:1A:card", protocol.CodeForbidden)
+	bob.ExpectError("BOOK:1A:card", protocol.CodeConflict)
+
+	// Unsigned requests may not pose as another holder
+	bob.Write(request(t, "RELEASE:1A", commands.HeaderHolder, "alice"))
+	adaptertest.AssertError(t, bob.Read(), protocol.CodeForbidden)
+
+	alice.Expect("CONFIRM:1A:card", "CONFIRMED:1A")
+	bob.Expect("SEATS:", "SEATS:1B")
+}
+
+func TestDisconnectReleasesHolds(t *testing.T) {
+	srv := newServer(t)
+	alice, bob := srv.Client(), srv.Client()
+
+	alice.Expect("HOLD:1A", "HELD:1A")
+	alice.Write(request(t, "HOLD:1B", commands.HeaderFlight, "T200"))
+	adaptertest.AssertMessage(t, alice.Read(), protocol.NewMessage("HELD", "1B"))
+	bob.Expect("HOLD:1B", "HELD:1B")
+	alice.Close()
+
+	// A new connection from the same address does not inherit the holds
+	deadline := time.Now().Add(adaptertest.DefaultTimeout)
+	for {
+		if got := bob.Send("SEATS:"); got.Payload == "1A" || time.Now().After(deadline) {
+			adaptertest.AssertMessage(t, got, protocol.NewMessage("SEATS", "1A"))
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	bob.Write(request(t, "SEATS:", commands.HeaderFlight, "T200"))
+	adaptertest.AssertMessage(t, bob.Read(), protocol.NewMessage("SEATS", "1A,1B"))
+	srv.Client().ExpectError("RELEASE:1B", protocol.CodeForbidden)
+	bob.Expect("RELEASE:1B", "RELEASED:1B")
+}
+
+func TestFlightHeader(t *testing.T) {
+	c := newServer(t).Client()
+	c.Write(request(t, "HOLD:1A", commands.HeaderFlight, "T200"))
+	adaptertest.AssertMessage(t, c.Read(), protocol.NewMessage("HELD", "1A"))
+
+	// The first flight is untouched
+	c.Expect("SEATS:", "SEATS:1A,1B")
+	c.Write(request(t, "SEATS:", commands.HeaderFlight, "T200"))
+	seats := c.Read()
+	adaptertest.AssertMessage(t, seats, protocol.NewMessage("SEATS", "1B"))
+	if got := seats.Header(commands.HeaderFlight); got != "T200" {
+		t.Errorf("flight header = %q, want T200", got)
+	}
+
+	c.Write(request(t, "SEATS:", commands.HeaderFlight, "T999"))
+	adaptertest.AssertError(t, c.Read(), protocol.CodeNotFound)
+}
+
+func TestQuotedBooking(t *testing.T) {
+	c := newServer(t).Client()
+	quoted := c.Expect("QUOTE:1A", "QUOTED:1A")
+	id, amount := quoted.Header(commands.HeaderQuote), quoted.Header(commands.HeaderAmount)
+	if id == "" || amount == "" {
+		t.Fatalf("QUOTED headers = %v", quoted.Headers)
+	}
+
+	c.Write(request(t, "BOOK:1B:card", commands.HeaderQuote, id))
+	adaptertest.AssertError(t, c.Read(), protocol.CodeBadRequest)
+	c.Write(request(t, "BOOK:1A:card", commands.HeaderQuote, id))
+	booked := c.Read()
+	adaptertest.AssertMessage(t, booked, protocol.NewMessage("BOOKED", "1A"))
+	if got := booked.Header(commands.HeaderAmount); got != amount {
+		t.Errorf("charged %s, want the quoted %s", got, amount)
+	}
+	// The failed BOOK released its hold on 1B
+	c.Expect("SEATS:", "SEATS:1B")
+}
@@ -3,47 +3,77 @@ package factory
 import (
 	"flight-booking/models"
 	"fmt"
+	"time"
 )
 
+// flightTypes are the demo flights of CreateFlight and
+// CreateAircraftFlight, departing the day after they are created
+var flightTypes = map[string]Departure{
+	"domestic":      {"D101", "IndiGo", "DEL", "BOM", 9 * time.Hour, 2*time.Hour + 10*time.Minute, "A320", 5000},
+	"international": {"I201", "Air India", "DEL", "LHR", 14 * time.Hour, 9*time.Hour + 30*time.Minute, "B787", 25000},
+}
+
+// CreateFlight creates the demo flight of flightType with seats economy
+// seats numbered A1..An, or nil for an unknown type.
+//
+// Deprecated: use CreateAircraftFlight, which seats the flight like its
+// aircraft and sets its fares.
 func CreateFlight(flightType string, seats int) *models.Flight {
-	switch flightType {
-	case "domestic":
-
-		d_flight := &models.Flight{
-			ID:          "D101",
-			Name:        "IndiGo",
-			Seats:       seats,
-			Seat_metrix: make(map[string]*models.Seat),
-			Price:       5000,
-		}
-		for i := 1; i <= seats; i++ {
-			seatNumber := fmt.Sprintf("A%d", i)
-			d_flight.Seat_metrix[seatNumber] = &models.Seat{
-				Number: seatNumber,
-				Status: models.Available,
-			}
-		}
-		return d_flight
+	d, ok := flightTypes[flightType]
+	if !ok {
+		return nil
+	}
+	f := models.NewFlight(d.Number, d.Airline, d.Price, seatSpecs(0, seats))
+	f.Aircraft = d.Aircraft
+	d.route(f, nextDay(time.Now(), d.At))
+	return f
+}
 
-	case "international":
+// CreateAircraftFlight creates the demo flight of flightType seated like
+// its aircraft, or nil for an unknown type
+func CreateAircraftFlight(flightType string) *models.Flight {
+	d, ok := flightTypes[flightType]
+	if !ok {
+		return nil
+	}
+	f := newFlight(d.Number, d.Airline, d.Price, d.Aircraft)
+	d.route(f, nextDay(time.Now(), d.At))
+	return f
+}
 
-		i_flight := &models.Flight{
-			ID:          "I201",
-			Name:        "Air India",
-			Seats:       seats,
-			Seat_metrix: make(map[string]*models.Seat),
-			Price:       25000,
-		}
-		for i := 1; i <= seats; i++ {
-			seatNumber := fmt.Sprintf("A%d", i)
-			i_flight.Seat_metrix[seatNumber] = &models.Seat{
-				Number: seatNumber,
-				Status: models.Available,
-			}
+// seatSpecs names seats A1..An; the first business seats are in the
+// business cabin
+func seatSpecs(business, seats int) []models.SeatSpec {
+	specs := make([]models.SeatSpec, seats)
+	for i := range specs {
+		specs[i] = models.SeatSpec{Number: fmt.Sprintf("A%d", i+1), Cabin: models.Economy}
+		if i < business {
+			specs[i].Cabin = models.Business
 		}
-		return i_flight
+	}
+	return specs
+}
 
-	default:
-		return nil
+// newFlight creates a flight seated like aircraft with the fares of an
+// economy price
+func newFlight(id, name string, price float64, aircraft string) *models.Flight {
+	layout, ok := Layouts[aircraft]
+	if !ok {
+		panic("factory: no layout for aircraft " + aircraft)
 	}
+	f := models.NewFlight(id, name, price, layout.Seats())
+	f.Aircraft = layout.Model
+	f.Fares = Fares(price)
+	return f
+}
+
+// nextDay returns the time of day at on the day after now, in UTC
+func nextDay(now time.Time, at time.Duration) time.Time {
+	return day(now).AddDate(0, 0, 1).Add(at)
+}
+
+// day returns midnight UTC of the day of t
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
 }
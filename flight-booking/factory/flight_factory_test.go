@@ -0,0 +1,53 @@
+package factory
+
+import (
+	"flight-booking/models"
+	"testing"
+	"time"
+)
+
+func TestCreateFlight(t *testing.T) {
+	tests := []struct {
+		flightType string
+		id         string
+		aircraft   string
+	}{
+		{"domestic", "D101", "A320"},
+		{"international", "I201", "B787"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flightType, func(t *testing.T) {
+			f := CreateFlight(tt.flightType, 3)
+			if f == nil || f.ID != tt.id || f.Aircraft != tt.aircraft {
+				t.Fatalf("CreateFlight = %+v, want %s on %s", f, tt.id, tt.aircraft)
+			}
+			// The deprecated constructor keeps its A1..An economy seats
+			if seats := f.AvailableSeats(); len(seats) != 3 || seats[0] != "A1" || seats[2] != "A3" {
+				t.Errorf("seats = %v, want A1..A3", seats)
+			}
+			if cabins := f.Cabins(); len(cabins) != 1 || cabins[0] != models.Economy {
+				t.Errorf("cabins = %v, want economy only", cabins)
+			}
+
+			seated := CreateAircraftFlight(tt.flightType)
+			if seated == nil || seated.ID != tt.id {
+				t.Fatalf("CreateAircraftFlight = %+v, want %s", seated, tt.id)
+			}
+			if want := len(Layouts[tt.aircraft].Seats()); seated.Seats != want {
+				t.Errorf("%s has %d seats, want the %d of its aircraft", tt.aircraft, seated.Seats, want)
+			}
+			if seated.Fare(models.ClassBusiness) != Fares(seated.Price)[models.ClassBusiness] {
+				t.Errorf("business fare = %v", seated.Fare(models.ClassBusiness))
+			}
+			for _, f := range []*models.Flight{f, seated} {
+				if !f.Departure.After(time.Now()) || !f.Arrival.After(f.Departure) {
+					t.Errorf("%s departs %v and arrives %v", f.ID, f.Departure, f.Arrival)
+				}
+			}
+		})
+	}
+
+	if CreateFlight("charter", 3) != nil || CreateAircraftFlight("charter") != nil {
+		t.Error("unknown flight type created a flight")
+	}
+}
@@ -0,0 +1,119 @@
+package factory
+
+import (
+	"flight-booking/models"
+	"math"
+	"time"
+)
+
+// Layouts are the seating templates of the aircraft types
+var Layouts = map[string]models.Layout{
+	"ATR72": {
+		Model:    "ATR72",
+		Cabins:   []models.CabinLayout{{Cabin: models.Economy, FirstRow: 1, LastRow: 18, Columns: "AC DF"}},
+		ExitRows: []int{1},
+		Blocked:  []string{"18D", "18F"},
+	},
+	"A320": {
+		Model: "A320",
+		Cabins: []models.CabinLayout{
+			{Cabin: models.Business, FirstRow: 1, LastRow: 2, Columns: "AC DF"},
+			{Cabin: models.Economy, FirstRow: 3, LastRow: 27, Columns: "ABC DEF"},
+		},
+		ExitRows:         []int{12, 13},
+		ExtraLegroomRows: []int{3},
+	},
+	"A321": {
+		Model: "A321",
+		Cabins: []models.CabinLayout{
+			{Cabin: models.Business, FirstRow: 1, LastRow: 3, Columns: "AC DF"},
+			{Cabin: models.Economy, FirstRow: 4, LastRow: 32, Columns: "ABC DEF"},
+		},
+		ExitRows:         []int{4, 16, 17},
+		ExtraLegroomRows: []int{5},
+	},
+	"B787": {
+		Model: "B787",
+		Cabins: []models.CabinLayout{
+			{Cabin: models.Business, FirstRow: 1, LastRow: 6, Columns: "AC DG HK"},
+			{Cabin: models.Economy, FirstRow: 10, LastRow: 38, Columns: "ABC DEG HJK"},
+		},
+		ExitRows:         []int{10, 25},
+		ExtraLegroomRows: []int{26},
+		Blocked:          []string{"38D", "38E", "38G"},
+	},
+}
+
+// ClassMarkup multiplies the economy fare for the other seat classes
+var ClassMarkup = map[models.SeatClass]float64{
+	models.ClassEconomy:     1,
+	models.ClassEconomyPlus: 1.2,
+	models.ClassBusiness:    3.5,
+}
+
+// Fares returns the fare of each seat class for an economy fare
+func Fares(economy float64) map[models.SeatClass]float64 {
+	fares := make(map[models.SeatClass]float64, len(ClassMarkup))
+	for class, markup := range ClassMarkup {
+		fares[class] = math.Round(economy * markup)
+	}
+	return fares
+}
+
+// Departure is a daily flight of the timetable
+type Departure struct {
+	Number      string
+	Airline     string
+	Origin      string
+	Destination string
+	// At is the departure time of day in UTC
+	At       time.Duration
+	Duration time.Duration
+	Aircraft string
+	Price    float64
+}
+
+// Timetable is the daily schedule created by CreateSchedule
+var Timetable = []Departure{
+	{"6E201", "IndiGo", "DEL", "BOM", 3*time.Hour + 30*time.Minute, 2*time.Hour + 10*time.Minute, "A320", 4800},
+	{"AI865", "Air India", "DEL", "BOM", 7 * time.Hour, 2*time.Hour + 15*time.Minute, "A321", 5600},
+	{"UK955", "Vistara", "DEL", "BOM", 13*time.Hour + 45*time.Minute, 2*time.Hour + 5*time.Minute, "A320", 5200},
+	{"6E202", "IndiGo", "BOM", "DEL", 6*time.Hour + 15*time.Minute, 2*time.Hour + 10*time.Minute, "A320", 4900},
+	{"AI866", "Air India", "BOM", "DEL", 11 * time.Hour, 2*time.Hour + 10*time.Minute, "A321", 5500},
+	{"6E5301", "IndiGo", "BLR", "DEL", 2*time.Hour + 30*time.Minute, 2*time.Hour + 45*time.Minute, "A321", 6100},
+	{"6E5302", "IndiGo", "DEL", "BLR", 9 * time.Hour, 2*time.Hour + 50*time.Minute, "A321", 6300},
+	{"6E7121", "IndiGo", "BLR", "GOI", 5 * time.Hour, 1*time.Hour + 10*time.Minute, "ATR72", 3200},
+	{"AI111", "Air India", "DEL", "LHR", 8*time.Hour + 30*time.Minute, 9*time.Hour + 30*time.Minute, "B787", 38000},
+	{"AI131", "Air India", "BOM", "LHR", 9 * time.Hour, 9*time.Hour + 45*time.Minute, "B787", 36500},
+	{"EK511", "Emirates", "DEL", "DXB", 4 * time.Hour, 3*time.Hour + 40*time.Minute, "B787", 17500},
+}
+
+// CreateSchedule creates a flight of every timetable entry for each of
+// the days starting with the day of start. Flight IDs are the flight
+// number and date, e.g. 6E201-20250101.
+func CreateSchedule(start time.Time, days int) []*models.Flight {
+	var flights []*models.Flight
+	first := day(start)
+	for i := 0; i < days; i++ {
+		date := first.AddDate(0, 0, i)
+		for _, d := range Timetable {
+			flights = append(flights, CreateScheduledFlight(d, date))
+		}
+	}
+	return flights
+}
+
+// CreateScheduledFlight creates the flight of d on date
+func CreateScheduledFlight(d Departure, date time.Time) *models.Flight {
+	departure := day(date).Add(d.At)
+	f := newFlight(d.Number+"-"+departure.Format("20060102"), d.Airline, d.Price, d.Aircraft)
+	d.route(f, departure)
+	return f
+}
+
+// route sets the airports and times of f for its departure at departure
+func (d Departure) route(f *models.Flight, departure time.Time) {
+	f.Origin, f.Destination = d.Origin, d.Destination
+	f.Departure = departure
+	f.Arrival = departure.Add(d.Duration)
+}
@@ -0,0 +1,21 @@
+package factory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreateSchedule(t *testing.T) {
+	start := time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC)
+	flights := CreateSchedule(start, 2)
+	if len(flights) != 2*len(Timetable) {
+		t.Fatalf("scheduled %d flights, want %d", len(flights), 2*len(Timetable))
+	}
+	first, next := flights[0], flights[len(Timetable)]
+	if first.ID != "6E201-20250101" || next.ID != "6E201-20250102" {
+		t.Errorf("IDs = %s, %s", first.ID, next.ID)
+	}
+	if want := time.Date(2025, 1, 1, 3, 30, 0, 0, time.UTC); !first.Departure.Equal(want) {
+		t.Errorf("departure = %v, want %v", first.Departure, want)
+	}
+}
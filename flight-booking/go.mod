@@ -1,3 +1,7 @@
 module flight-booking
 
 go 1.25.7
+
+require tcp-adapter v0.0.0
+
+replace tcp-adapter => ../tcp-adapter
@@ -0,0 +1,148 @@
+// Package inventory holds the flights on sale and searches them by
+// route, departure date, cabin and seat availability.
+package inventory
+
+import (
+	"flight-booking/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort orders search results
+type Sort string
+
+const (
+	ByDeparture Sort = "departure"
+	ByPrice     Sort = "price"
+)
+
+// ParseSort accepts "departure" or "price"; empty means departure
+func ParseSort(name string) (Sort, error) {
+	switch order := Sort(strings.ToLower(name)); order {
+	case "":
+		return ByDeparture, nil
+	case ByDeparture, ByPrice:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown sort %q, expected departure or price", name)
+}
+
+// Query filters a search. Zero fields match every flight.
+type Query struct {
+	Origin      string
+	Destination string
+	// From and To bound the departure time: From <= departure < To
+	From time.Time
+	To   time.Time
+	// Cabin restricts Seats to one cabin
+	Cabin models.Cabin
+	// Seats is the number of available seats needed
+	Seats int
+	// Sort by price compares the fares of Cabin, economy by default
+	Sort Sort
+}
+
+// Pricer gives the current fare of a cabin
+type Pricer interface {
+	CabinPrice(f *models.Flight, cabin models.Cabin, now time.Time) float64
+}
+
+// staticPricer uses the fares of the flights
+type staticPricer struct{}
+
+func (staticPricer) CabinPrice(f *models.Flight, cabin models.Cabin, now time.Time) float64 {
+	return f.CabinFare(cabin)
+}
+
+// Result is a flight matching a query
+type Result struct {
+	Flight *models.Flight
+	// Price is the fare of the queried cabin, economy by default
+	Price float64
+	// Available is the number of available seats in the queried cabin,
+	// or in the whole flight
+	Available int
+}
+
+// Inventory is read-only once created, so it needs no locking; seat
+// states are guarded by their flights
+type Inventory struct {
+	flights map[string]*models.Flight
+	order   []*models.Flight
+	pricer  Pricer
+}
+
+// New creates an inventory of flights, which must have unique IDs,
+// priced by pricer or by their fares if pricer is nil
+func New(flights []*models.Flight, pricer Pricer) (*Inventory, error) {
+	if pricer == nil {
+		pricer = staticPricer{}
+	}
+	inv := &Inventory{flights: make(map[string]*models.Flight, len(flights)), pricer: pricer}
+	for _, f := range flights {
+		if _, ok := inv.flights[f.ID]; ok {
+			return nil, fmt.Errorf("duplicate flight %s", f.ID)
+		}
+		inv.flights[f.ID] = f
+		inv.order = append(inv.order, f)
+	}
+	return inv, nil
+}
+
+// Flight returns the flight with id
+func (inv *Inventory) Flight(id string) (*models.Flight, bool) {
+	f, ok := inv.flights[id]
+	return f, ok
+}
+
+// Flights returns all flights in the order they were added
+func (inv *Inventory) Flights() []*models.Flight {
+	return append([]*models.Flight(nil), inv.order...)
+}
+
+// Search returns the flights matching q in q.Sort order. Departed
+// flights are not for sale and never match.
+func (inv *Inventory) Search(q Query) []Result {
+	results := []Result{}
+	now := time.Now()
+	for _, f := range inv.order {
+		if f.Departed(now) ||
+			q.Origin != "" && !strings.EqualFold(f.Origin, q.Origin) ||
+			q.Destination != "" && !strings.EqualFold(f.Destination, q.Destination) ||
+			!q.From.IsZero() && f.Departure.Before(q.From) ||
+			!q.To.IsZero() && !f.Departure.Before(q.To) {
+			continue
+		}
+
+		available := 0
+		for cabin, n := range f.Availability() {
+			if q.Cabin == "" || cabin == q.Cabin {
+				available += n
+			}
+		}
+		if q.Cabin != "" && !hasCabin(f, q.Cabin) || available < q.Seats {
+			continue
+		}
+		results = append(results, Result{Flight: f, Price: inv.pricer.CabinPrice(f, q.Cabin, now), Available: available})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if q.Sort == ByPrice && a.Price != b.Price {
+			return a.Price < b.Price
+		}
+		return a.Flight.Departure.Before(b.Flight.Departure)
+	})
+	return results
+}
+
+func hasCabin(f *models.Flight, cabin models.Cabin) bool {
+	for _, c := range f.Cabins() {
+		if c == cabin {
+			return true
+		}
+	}
+	return false
+}
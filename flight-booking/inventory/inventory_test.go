@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"flight-booking/models"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Sort
+		wantErr bool
+	}{
+		{"", ByDeparture, false},
+		{"departure", ByDeparture, false},
+		{"Price", ByPrice, false},
+		{"seats", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSort(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// flight makes a flight of one business and two economy seats
+func flight(id, origin, destination string, departure time.Time, economy float64) *models.Flight {
+	f := models.NewFlight(id, "Test Air", economy, []models.SeatSpec{
+		{Number: "1A", Cabin: models.Business},
+		{Number: "2A", Cabin: models.Economy},
+		{Number: "2B", Cabin: models.Economy},
+	})
+	f.Origin, f.Destination, f.Departure = origin, destination, departure
+	f.Fares = map[models.SeatClass]float64{models.ClassEconomy: economy, models.ClassBusiness: 4000 - economy}
+	return f
+}
+
+func TestSearch(t *testing.T) {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 2)
+	flights := []*models.Flight{
+		flight("F1", "DEL", "BOM", day.Add(18*time.Hour), 1000),
+		flight("F2", "DEL", "BOM", day.Add(9*time.Hour), 3000),
+		flight("F3", "BOM", "DEL", day.Add(12*time.Hour), 2000),
+		flight("F4", "DEL", "BOM", day.AddDate(0, 0, 1).Add(9*time.Hour), 1500),
+		flight("F5", "DEL", "BOM", time.Now().Add(-time.Hour), 500),
+		models.NewFlight("F6", "Test Air", 800, []models.SeatSpec{{Number: "1A"}}),
+	}
+	flights[5].Origin, flights[5].Destination, flights[5].Departure = "DEL", "GOI", day.Add(6*time.Hour)
+	// F1 has one economy seat left and F3 none
+	if _, err := flights[0].HoldSeat("2A", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for _, seat := range []string{"2A", "2B"} {
+		if _, err := flights[2].HoldSeat(seat, "bob"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inv, err := New(flights, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		// want is the IDs of the results with their price/availability
+		want string
+	}{
+		{"departed flights never match", Query{}, "F6 800/1 F2 3000/3 F3 2000/1 F1 1000/2 F4 1500/3"},
+		{"route ignores case", Query{Origin: "del", Destination: "bom"}, "F2 3000/3 F1 1000/2 F4 1500/3"},
+		{"departure day", Query{Origin: "DEL", Destination: "BOM", From: day, To: day.AddDate(0, 0, 1)}, "F2 3000/3 F1 1000/2"},
+		{"economy seats", Query{Cabin: models.Economy, Seats: 2}, "F2 3000/2 F4 1500/2"},
+		{"business cabin", Query{Cabin: models.Business}, "F2 1000/1 F3 2000/1 F1 3000/1 F4 2500/1"},
+		{"by economy price", Query{Cabin: models.Economy, Sort: ByPrice}, "F6 800/1 F1 1000/1 F4 1500/2 F3 2000/0 F2 3000/2"},
+		{"by business price", Query{Cabin: models.Business, Sort: ByPrice}, "F2 1000/1 F3 2000/1 F4 2500/1 F1 3000/1"},
+		{"too many seats", Query{Seats: 4}, ""},
+		{"unknown route", Query{Origin: "LHR"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range inv.Search(tt.query) {
+				got = append(got, fmt.Sprintf("%s %v/%d", r.Flight.ID, r.Price, r.Available))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("Search = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRejectsDuplicates(t *testing.T) {
+	departure := time.Now().Add(time.Hour)
+	if _, err := New([]*models.Flight{
+		flight("F1", "DEL", "BOM", departure, 1000),
+		flight("F1", "BOM", "DEL", departure, 1000),
+	}, nil); err == nil {
+		t.Fatal("New accepted two flights F1")
+	}
+}
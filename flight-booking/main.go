@@ -1,22 +1,108 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
+	"flight-booking/api"
+	"flight-booking/commands"
 	"flight-booking/factory"
+	"flight-booking/inventory"
 	"flight-booking/models"
+	"flight-booking/pricing"
+	"flight-booking/repository"
+	"flight-booking/seatmap"
 	"flight-booking/service"
 	"flight-booking/strategy"
 	"fmt"
+	"html"
+	"log"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"tcp-adapter/pkg/adapter"
+	"tcp-adapter/pkg/signing"
 )
 
 func main() {
+	tcpAddr := flag.String("tcp", "", "serve booking commands to ticket counters on this address (e.g. localhost:9090) instead of running the simulation")
+	apiEnabled := flag.Bool("api", false, "serve the REST API under /api next to /metrics instead of running the simulation")
+	workers := flag.Int("workers", 100, "booking workers for the REST API")
+	dataDir := flag.String("data", "", "directory for the flight and booking journal (kept in memory if empty)")
+	days := flag.Int("schedule-days", 14, "days of the timetable to put on sale when the repository has no flights")
+	quoteTTL := flag.Duration("quote-ttl", pricing.DefaultQuoteTTL, "how long a fare quote is honoured")
+	maxQuotes := flag.Int("max-quotes", pricing.DefaultMaxQuotes, "how many fare quotes are kept")
+	seatMap := flag.String("seatmap", "", "print the seat map of this flight and exit")
+	counterKeys := flag.String("counter-keys", "", "signing keys of the ticket counters (id=secret lines); signed requests may hold seats for a named customer")
+	holdTTL := flag.Duration("hold-ttl", models.DefaultHoldTTL, "how long a held seat is kept before it becomes available again")
+	flag.Parse()
+
+	// Like Flight.HoldTTL, zero means the default. A hold must outlast a
+	// payment, or it could lapse while the customer is being charged.
+	if *holdTTL <= 0 {
+		*holdTTL = models.DefaultHoldTTL
+	}
+	if *holdTTL < service.PaymentTimeout {
+		log.Fatalf("-hold-ttl %s is shorter than the payment timeout %s", *holdTTL, service.PaymentTimeout)
+	}
 
-	flight := factory.CreateFlight("domestic", 100)
-	go startMetricsServer()
+	repo, err := openRepository(*dataDir)
+	if err != nil {
+		log.Fatalf("Opening repository: %v", err)
+	}
+	defer repo.Close()
+	flights, err := service.LoadFlights(repo, func() []*models.Flight {
+		flights := []*models.Flight{
+			factory.CreateAircraftFlight("domestic"),
+			factory.CreateAircraftFlight("international"),
+		}
+		return append(flights, factory.CreateSchedule(time.Now(), *days)...)
+	})
+	if err != nil {
+		log.Fatalf("Loading flights: %v", err)
+	}
+	engine := pricing.NewEngine()
+	engine.QuoteTTL = *quoteTTL
+	engine.MaxQuotes = *maxQuotes
+	inv, err := inventory.New(flights, engine)
+	if err != nil {
+		log.Fatalf("Loading flights: %v", err)
+	}
+	if *seatMap != "" {
+		f, ok := inv.Flight(*seatMap)
+		if !ok {
+			log.Fatalf("Flight %s not found", *seatMap)
+		}
+		seatmap.New(f, nil).WriteASCII(os.Stdout)
+		return
+	}
+	for _, f := range flights {
+		f.HoldTTL = *holdTTL
+	}
+	stopReaper := service.StartHoldReaper(flights, min(service.HoldReapInterval, *holdTTL))
+	defer stopReaper()
+	flight := flights[0]
+
+	if *tcpAddr != "" || *apiEnabled {
+		if err := serve(repo, inv, engine, *tcpAddr, *counterKeys, *apiEnabled, *workers); err != nil {
+			repo.Close()
+			log.Fatal(err)
+		}
+		return
+	}
+
+	metricsServer := newMetricsServer(inv, nil)
+	go func() {
+		log.Fatalf("Metrics server: %v", metricsServer.ListenAndServe())
+	}()
 	requests := make(chan service.BookingRequest, 100)
 
 	var wg sync.WaitGroup
@@ -25,12 +111,13 @@ func main() {
 	service.StartWorkerPool(requests, 100, &wg)
 
 	// send booking request
+	seats := flight.SeatNumbers()
 	for i := 0; i < 20000; i++ {
 
 		booking := models.Booking{
 			UserName: fmt.Sprintf("User-%d", i),
 			Flight:   flight,
-			SeatNo:   fmt.Sprintf("A%d", rand.Intn(100)+1),
+			SeatNo:   seats[rand.Intn(len(seats))],
 		}
 
 		requests <- service.BookingRequest{
@@ -44,9 +131,108 @@ func main() {
 	wg.Wait()
 	time.Sleep(100 * time.Second)
 }
-func startMetricsServer() {
+
+// serve runs the ticket counter commands and/or the REST API until
+// interrupted
+func serve(repo repository.Repository, inv *inventory.Inventory, engine *pricing.Engine, tcpAddr, counterKeys string, apiEnabled bool, workers int) error {
+	var apiServer *api.Server
+	if apiEnabled {
+		bookings, err := service.NewBookingStore(repo, inv.Flights())
+		if err != nil {
+			return fmt.Errorf("loading bookings: %w", err)
+		}
+		requests := make(chan service.BookingRequest, workers)
+		var wg sync.WaitGroup
+		service.StartWorkerPool(requests, workers, &wg)
+		defer func() {
+			close(requests)
+			wg.Wait()
+		}()
+		apiServer = api.NewServer(inv, engine, bookings, requests)
+	}
+
+	errs := make(chan error, 2)
+	httpServer := newMetricsServer(inv, apiServer)
+	go func() {
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			errs <- fmt.Errorf("http server: %w", err)
+		}
+	}()
+	// Stop taking requests before the worker pool above is shut down
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*service.PaymentTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("Stopping http server: %v", err)
+		}
+	}()
+
+	if tcpAddr != "" {
+		tcpAdapter, err := newCounterAdapter(inv, engine, tcpAddr, counterKeys)
+		if err != nil {
+			return fmt.Errorf("ticket counter server: %w", err)
+		}
+		defer tcpAdapter.Stop()
+		go func() {
+			if err := tcpAdapter.Start(); err != nil {
+				errs <- fmt.Errorf("ticket counter server: %w", err)
+			}
+		}()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-sigChan:
+		return nil
+	}
+}
+
+// openRepository opens the journal in dir, or a memory repository if dir
+// is empty
+func openRepository(dir string) (repository.Repository, error) {
+	if dir == "" {
+		return repository.NewMemory(), nil
+	}
+	return repository.OpenFile(dir)
+}
+
+// newCounterAdapter creates a tcp-adapter serving the booking commands.
+// With a keys file, signed requests are verified so that the commands can
+// trust the signing key as the holder of seats.
+func newCounterAdapter(inv *inventory.Inventory, engine *pricing.Engine, addr, keysFile string) (*adapter.TCPAdapter, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portText)
+	}
+
+	tcpAdapter := adapter.NewTCPAdapter(host, port)
+	if keysFile != "" {
+		keys, err := signing.NewKeyring(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		tcpAdapter.Router().Use(signing.NewVerifier(signing.Config{Keys: keys}).Interceptor())
+	}
+	commands.Register(tcpAdapter.Router(), inv, engine)
+	tcpAdapter.OnDisconnect(commands.Disconnect(inv))
+	return tcpAdapter, nil
+}
+
+// newMetricsServer creates the server for /metrics, /dashboard and, if
+// apiServer is set, the REST API
+func newMetricsServer(inv *inventory.Inventory, apiServer *api.Server) *http.Server {
 
 	mux := http.NewServeMux()
+	if apiServer != nil {
+		apiServer.Register(mux)
+	}
 
 	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
 
@@ -55,6 +241,7 @@ func startMetricsServer() {
 			"success":        service.GlobalMetrics.Success,
 			"failed":         service.GlobalMetrics.Failed,
 			"timeout":        service.GlobalMetrics.Timeout,
+			"refunds_due":    service.GlobalMetrics.RefundsDue,
 			"avg_latency":    service.GlobalMetrics.AverageLatency().String(),
 		}
 
@@ -63,7 +250,15 @@ func startMetricsServer() {
 
 	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
 
-		html := `
+		// seat map of ?flight=, the simulated flight by default
+		flight, ok := inv.Flight(r.URL.Query().Get("flight"))
+		if !ok {
+			flight = inv.Flights()[0]
+		}
+		var seats strings.Builder
+		seatmap.New(flight, nil).WriteASCII(&seats)
+
+		page := `
 		<html>
 		<head>
 		<title>Booking Metrics Dashboard</title>
@@ -76,17 +271,20 @@ func startMetricsServer() {
 		<p>Failed due to Held by other user: %d</p>
 		<p>failed due to payment Timeout: %d</p>
 		<p>Average Latency: %s</p>
+		<h2>Seat Map</h2>
+		<pre>%s</pre>
 		</body>
 		</html>`
 
-		fmt.Fprintf(w, html,
+		fmt.Fprintf(w, page,
 			service.GlobalMetrics.TotalRequests,
 			service.GlobalMetrics.Success,
 			service.GlobalMetrics.Failed,
 			service.GlobalMetrics.Timeout,
 			service.GlobalMetrics.AverageLatency(),
+			html.EscapeString(seats.String()),
 		)
 	})
 
-	http.ListenAndServe(":8080", mux)
+	return &http.Server{Addr: ":8080", Handler: mux}
 }
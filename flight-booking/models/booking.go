@@ -1,8 +1,43 @@
 package models
 
-type Booking struct{
+import "time"
+
+type BookingStatus string
+
+const (
+	BookingHeld      BookingStatus = "held"
+	BookingConfirmed BookingStatus = "confirmed"
+	BookingCancelled BookingStatus = "cancelled"
+	// BookingPaying is a held booking whose payment is in progress
+	BookingPaying BookingStatus = "paying"
+	// BookingExpired is a held booking whose hold lapsed
+	BookingExpired BookingStatus = "expired"
+	// BookingRefundDue is a booking that was paid for but whose seat
+	// could not be confirmed
+	BookingRefundDue BookingStatus = "refund_due"
+)
+
+type Booking struct {
+	ID       string
 	UserName string
-	Flight *Flight
-	SeatNo string
+	Flight   *Flight
+	SeatNo   string
+
+	Status        BookingStatus
+	Amount        float64
+	PaymentMethod string
+	HoldExpiry    time.Time
+	CreatedAt     time.Time
+	// Quote is the fare the booking is charged, with its audit
+	Quote *FareQuote
 }
 
+// Holder is who holds and owns the booking's seat: the booking itself,
+// so that another booking of the same user cannot release or confirm
+// it. Bookings without an ID hold seats for their user.
+func (b Booking) Holder() string {
+	if b.ID != "" {
+		return b.ID
+	}
+	return b.UserName
+}
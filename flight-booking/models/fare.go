@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// FareStep is the part one pricing rule had in a fare
+type FareStep struct {
+	Rule       string  `json:"rule"`
+	Detail     string  `json:"detail"`
+	Multiplier float64 `json:"multiplier"`
+	// Price is the fare after the step
+	Price float64 `json:"price"`
+}
+
+// FareQuote is the price of a seat, honoured until it expires. Steps
+// record how the price was reached.
+type FareQuote struct {
+	ID        string     `json:"id"`
+	FlightID  string     `json:"flight_id"`
+	SeatNo    string     `json:"seat"`
+	Class     SeatClass  `json:"class"`
+	Price     float64    `json:"price"`
+	Steps     []FareStep `json:"steps"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// Valid reports whether the quote can still be honoured at now
+func (q *FareQuote) Valid(now time.Time) bool {
+	return now.Before(q.ExpiresAt)
+}
@@ -1,7 +1,25 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
+)
+
+// DefaultHoldTTL is how long a seat stays held when Flight.HoldTTL is unset
+const DefaultHoldTTL = 10 * time.Minute
+
+var (
+	ErrSeatNotFound = errors.New("seat not found")
+	ErrSeatTaken    = errors.New("seat is not available")
+	ErrSeatBlocked  = errors.New("seat is blocked")
+	ErrNotHeld      = errors.New("seat is not held")
+	ErrNotBooked    = errors.New("seat is not booked")
+	ErrNotHolder    = errors.New("seat is held by someone else")
+	ErrHoldExpired  = errors.New("seat hold expired")
+	ErrDeparted     = errors.New("flight has departed")
 )
 
 type SeatStatus int
@@ -12,63 +30,322 @@ const (
 	Booked
 )
 
+func (s SeatStatus) String() string {
+	switch s {
+	case Held:
+		return "held"
+	case Booked:
+		return "booked"
+	}
+	return "available"
+}
+
+func (s SeatStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *SeatStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "available":
+		*s = Available
+	case "held":
+		*s = Held
+	case "booked":
+		*s = Booked
+	default:
+		return fmt.Errorf("unknown seat status %q", text)
+	}
+	return nil
+}
+
 type Flight struct {
 	ID          string
 	Name        string
 	Seats       int
 	Seat_metrix map[string]*Seat
-	Price       float64
-	mu          sync.Mutex //
+	// Price is the economy fare, used for seat classes missing in Fares
+	Price float64
+	Fares map[SeatClass]float64
+
+	// Origin and Destination are airport codes such as DEL
+	Origin      string
+	Destination string
+	Departure   time.Time
+	Arrival     time.Time
+	Aircraft    string
+
+	// HoldTTL is how long HoldSeat and ExtendHold keep a seat
+	HoldTTL time.Duration
+	mu      sync.Mutex //
+	journal SeatJournal
 }
 
 type Seat struct {
-	Number string
+	SeatSpec
 	Status SeatStatus
-	mu     sync.Mutex
+	// LockedBy is the holder of a held seat and the owner of a booked one
+	LockedBy string
+	// LockExpiry is when a hold lapses and the seat is available again
+	LockExpiry time.Time
+	mu         sync.Mutex
+}
+
+// expireLocked makes the seat available again if its hold has lapsed
+func (s *Seat) expireLocked(now time.Time) bool {
+	if s.Status != Held || now.Before(s.LockExpiry) {
+		return false
+	}
+	s.Status = Available
+	s.LockedBy = ""
+	s.LockExpiry = time.Time{}
+	return true
 }
 
-func (f *Flight) HoldSeat(seatNo string) bool {
+// checkHolderLocked verifies that holder may confirm or release the seat
+func (s *Seat) checkHolderLocked(holder string, now time.Time) error {
+	wasHolder := s.Status == Held && s.LockedBy == holder
+	if s.expireLocked(now) {
+		if wasHolder {
+			return ErrHoldExpired
+		}
+		return ErrNotHeld
+	}
+	if s.Status != Held {
+		return ErrNotHeld
+	}
+	if s.LockedBy != holder {
+		return ErrNotHolder
+	}
+	return nil
+}
+
+func (f *Flight) holdTTL() time.Duration {
+	if f.HoldTTL > 0 {
+		return f.HoldTTL
+	}
+	return DefaultHoldTTL
+}
+
+// Departed reports whether the flight left before now. Flights without a
+// departure time never do.
+func (f *Flight) Departed(now time.Time) bool {
+	return !f.Departure.IsZero() && !now.Before(f.Departure)
+}
+
+// HoldSeat holds an available seat for holder until the returned expiry
+func (f *Flight) HoldSeat(seatNo, holder string) (time.Time, error) {
 	seat, exists := f.Seat_metrix[seatNo]
 	if !exists {
-		return false
+		return time.Time{}, ErrSeatNotFound
+	}
+	if seat.Blocked {
+		return time.Time{}, ErrSeatBlocked
 	}
 	seat.mu.Lock()
 	defer seat.mu.Unlock()
 
+	now := time.Now()
+	if f.Departed(now) {
+		return time.Time{}, ErrDeparted
+	}
+	seat.expireLocked(now)
 	if seat.Status != Available {
-		return false
+		return time.Time{}, ErrSeatTaken
+	}
+	next := SeatState{Number: seatNo, Status: Held, LockedBy: holder, LockExpiry: now.Add(f.holdTTL())}
+	if err := f.save(next); err != nil {
+		return time.Time{}, err
+	}
+	seat.applyLocked(next)
+	return seat.LockExpiry, nil
+}
 
+// ExtendHold restarts the hold period of a seat held by holder
+func (f *Flight) ExtendHold(seatNo, holder string) (time.Time, error) {
+	seat, exists := f.Seat_metrix[seatNo]
+	if !exists {
+		return time.Time{}, ErrSeatNotFound
 	}
-	seat.Status = Held
+	seat.mu.Lock()
+	defer seat.mu.Unlock()
 
-	return true
+	now := time.Now()
+	if f.Departed(now) {
+		return time.Time{}, ErrDeparted
+	}
+	if err := seat.checkHolderLocked(holder, now); err != nil {
+		return time.Time{}, err
+	}
+	next := seat.stateLocked()
+	next.LockExpiry = now.Add(f.holdTTL())
+	if err := f.save(next); err != nil {
+		return time.Time{}, err
+	}
+	seat.applyLocked(next)
+	return seat.LockExpiry, nil
 }
 
-func (f *Flight) ConfirmSeat(seatNo string) bool {
+// ConfirmSeat books a seat held by holder, who becomes its owner
+func (f *Flight) ConfirmSeat(seatNo, holder string) error {
 	seat, exists := f.Seat_metrix[seatNo]
 	if !exists {
-		return false
+		return ErrSeatNotFound
 	}
 	seat.mu.Lock()
 	defer seat.mu.Unlock()
 
-	if seat.Status != Held {
-		return false
+	now := time.Now()
+	if f.Departed(now) {
+		return ErrDeparted
 	}
-	seat.Status = Booked
-	return true
+	if err := seat.checkHolderLocked(holder, now); err != nil {
+		return err
+	}
+	next := SeatState{Number: seatNo, Status: Booked, LockedBy: holder}
+	if err := f.save(next); err != nil {
+		return err
+	}
+	seat.applyLocked(next)
+	return nil
 }
 
-func (f *Flight) ReleaseSeat(seatNo string) {
+// ReleaseSeat returns a seat held by holder to Available
+func (f *Flight) ReleaseSeat(seatNo, holder string) error {
 	seat, exists := f.Seat_metrix[seatNo]
 	if !exists {
-		return
+		return ErrSeatNotFound
 	}
 
 	seat.mu.Lock()
 	defer seat.mu.Unlock()
 
-	if seat.Status == Held {
-		seat.Status = Available
+	if err := seat.checkHolderLocked(holder, time.Now()); err != nil {
+		return err
+	}
+	next := SeatState{Number: seatNo, Status: Available}
+	if err := f.save(next); err != nil {
+		return err
 	}
+	seat.applyLocked(next)
+	return nil
+}
+
+// CancelSeat makes a seat booked by owner available again, up to the
+// departure of the flight
+func (f *Flight) CancelSeat(seatNo, owner string) error {
+	seat, exists := f.Seat_metrix[seatNo]
+	if !exists {
+		return ErrSeatNotFound
+	}
+	seat.mu.Lock()
+	defer seat.mu.Unlock()
+
+	if f.Departed(time.Now()) {
+		return ErrDeparted
+	}
+	if seat.Status != Booked {
+		return ErrNotBooked
+	}
+	if seat.LockedBy != owner {
+		return ErrNotHolder
+	}
+	next := SeatState{Number: seatNo, Status: Available}
+	if err := f.save(next); err != nil {
+		return err
+	}
+	seat.applyLocked(next)
+	return nil
+}
+
+// ReleaseExpired returns every seat whose hold has lapsed to Available
+// and returns their numbers
+func (f *Flight) ReleaseExpired(now time.Time) []string {
+	var released []string
+	for number, seat := range f.Seat_metrix {
+		seat.mu.Lock()
+		if seat.expireLocked(now) {
+			released = append(released, number)
+		}
+		seat.mu.Unlock()
+	}
+	sortSeats(released)
+	return released
+}
+
+// ReleaseHolder returns every seat still held by holder to Available,
+// e.g. when the client that took the holds has gone, and returns their
+// numbers. Seats whose state cannot be saved stay held until they lapse.
+func (f *Flight) ReleaseHolder(holder string) []string {
+	var released []string
+	now := time.Now()
+	for number, seat := range f.Seat_metrix {
+		seat.mu.Lock()
+		if !seat.expireLocked(now) && seat.Status == Held && seat.LockedBy == holder {
+			next := SeatState{Number: number, Status: Available}
+			if f.save(next) == nil {
+				seat.applyLocked(next)
+				released = append(released, number)
+			}
+		}
+		seat.mu.Unlock()
+	}
+	sortSeats(released)
+	return released
+}
+
+// SeatStatus returns the current status of a seat and whether it exists
+func (f *Flight) SeatStatus(seatNo string) (SeatStatus, bool) {
+	seat, exists := f.Seat_metrix[seatNo]
+	if !exists {
+		return Available, false
+	}
+	seat.mu.Lock()
+	defer seat.mu.Unlock()
+	seat.expireLocked(time.Now())
+	return seat.Status, true
+}
+
+// AvailableSeats returns the numbers of all available seats in order;
+// blocked seats are never available
+func (f *Flight) AvailableSeats() []string {
+	var seats []string
+	now := time.Now()
+	for number, seat := range f.Seat_metrix {
+		if seat.Blocked {
+			continue
+		}
+		seat.mu.Lock()
+		seat.expireLocked(now)
+		if seat.Status == Available {
+			seats = append(seats, number)
+		}
+		seat.mu.Unlock()
+	}
+	sortSeats(seats)
+	return seats
+}
+
+// SeatMap returns the state of every seat in order
+func (f *Flight) SeatMap() []SeatState {
+	numbers := f.SeatNumbers()
+	now := time.Now()
+	seats := make([]SeatState, len(numbers))
+	for i, number := range numbers {
+		seat := f.Seat_metrix[number]
+		seat.mu.Lock()
+		seat.expireLocked(now)
+		seats[i] = seat.stateLocked()
+		seat.mu.Unlock()
+	}
+	return seats
+}
+
+// sortSeats orders seat numbers so that A2 comes before A10
+func sortSeats(seats []string) {
+	sort.Slice(seats, func(i, j int) bool {
+		if len(seats[i]) != len(seats[j]) {
+			return len(seats[i]) < len(seats[j])
+		}
+		return seats[i] < seats[j]
+	})
 }
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SeatState is a snapshot of one seat
+type SeatState struct {
+	Number     string     `json:"number"`
+	Status     SeatStatus `json:"status"`
+	LockedBy   string     `json:"locked_by,omitempty"`
+	LockExpiry time.Time  `json:"lock_expiry,omitempty"`
+}
+
+// SeatJournal persists seat transitions. SaveSeat is called with the new
+// state before it becomes visible; if it fails the transition is not made.
+type SeatJournal interface {
+	SaveSeat(flightID string, seat SeatState) error
+}
+
+// SeatNumbers returns the numbers of all seats in order
+func (f *Flight) SeatNumbers() []string {
+	numbers := make([]string, 0, len(f.Seat_metrix))
+	for number := range f.Seat_metrix {
+		numbers = append(numbers, number)
+	}
+	sortSeats(numbers)
+	return numbers
+}
+
+// SetJournal makes every following seat transition go through journal
+func (f *Flight) SetJournal(journal SeatJournal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.journal = journal
+}
+
+// RestoreSeat sets a seat to a saved state without journaling it
+func (f *Flight) RestoreSeat(state SeatState) error {
+	seat, exists := f.Seat_metrix[state.Number]
+	if !exists {
+		return fmt.Errorf("flight %s has no seat %s", f.ID, state.Number)
+	}
+	seat.mu.Lock()
+	defer seat.mu.Unlock()
+	seat.applyLocked(state)
+	return nil
+}
+
+// save journals the next state of a seat
+func (f *Flight) save(next SeatState) error {
+	f.mu.Lock()
+	journal := f.journal
+	f.mu.Unlock()
+	if journal == nil {
+		return nil
+	}
+	if err := journal.SaveSeat(f.ID, next); err != nil {
+		return fmt.Errorf("saving seat %s: %w", next.Number, err)
+	}
+	return nil
+}
+
+func (s *Seat) stateLocked() SeatState {
+	return SeatState{
+		Number:     s.Number,
+		Status:     s.Status,
+		LockedBy:   s.LockedBy,
+		LockExpiry: s.LockExpiry,
+	}
+}
+
+func (s *Seat) applyLocked(state SeatState) {
+	s.Status = state.Status
+	s.LockedBy = state.LockedBy
+	s.LockExpiry = state.LockExpiry
+}
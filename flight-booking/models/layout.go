@@ -0,0 +1,221 @@
+package models
+
+import "fmt"
+
+type Cabin string
+
+const (
+	Economy  Cabin = "economy"
+	Business Cabin = "business"
+)
+
+// ParseCabin accepts "economy" or "business"
+func ParseCabin(name string) (Cabin, error) {
+	switch cabin := Cabin(name); cabin {
+	case Economy, Business:
+		return cabin, nil
+	}
+	return "", fmt.Errorf("unknown cabin %q", name)
+}
+
+// SeatSpec describes a seat of an aircraft layout
+type SeatSpec struct {
+	Number string `json:"number"`
+	Cabin  Cabin  `json:"cabin"`
+	Row    int    `json:"row,omitempty"`
+	Column string `json:"column,omitempty"`
+	Window bool   `json:"window,omitempty"`
+	Aisle  bool   `json:"aisle,omitempty"`
+	// AfterAisle seats have the aisle on their left
+	AfterAisle bool `json:"after_aisle,omitempty"`
+	// ExitRow seats are next to an emergency exit and have extra legroom
+	ExitRow      bool `json:"exit_row,omitempty"`
+	ExtraLegroom bool `json:"extra_legroom,omitempty"`
+	// Blocked seats are never sold
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+// SeatClass is what a seat is priced as
+type SeatClass string
+
+const (
+	ClassEconomy     SeatClass = "economy"
+	ClassEconomyPlus SeatClass = "economy_plus"
+	ClassBusiness    SeatClass = "business"
+)
+
+// Class returns the seat class: extra legroom seats of the economy cabin
+// are economy plus
+func (s SeatSpec) Class() SeatClass {
+	switch {
+	case s.Cabin == Business:
+		return ClassBusiness
+	case s.ExtraLegroom:
+		return ClassEconomyPlus
+	}
+	return ClassEconomy
+}
+
+// CabinLayout is a block of rows with the same seating
+type CabinLayout struct {
+	Cabin    Cabin
+	FirstRow int
+	LastRow  int
+	// Columns are the seat letters from left to right with a space for
+	// each aisle, e.g. "ABC DEF"
+	Columns string
+}
+
+// Layout is the seating template of an aircraft type
+type Layout struct {
+	Model  string
+	Cabins []CabinLayout
+	// ExitRows are next to the emergency exits; they and ExtraLegroomRows
+	// have extra legroom
+	ExitRows         []int
+	ExtraLegroomRows []int
+	// Blocked seats, e.g. "31D", are not sold
+	Blocked []string
+}
+
+// Seats returns the specs of every seat of l, row by row
+func (l Layout) Seats() []SeatSpec {
+	exit := make(map[int]bool, len(l.ExitRows))
+	for _, row := range l.ExitRows {
+		exit[row] = true
+	}
+	legroom := make(map[int]bool, len(l.ExtraLegroomRows))
+	for _, row := range l.ExtraLegroomRows {
+		legroom[row] = true
+	}
+	blocked := make(map[string]bool, len(l.Blocked))
+	for _, number := range l.Blocked {
+		blocked[number] = true
+	}
+
+	var seats []SeatSpec
+	for _, cabin := range l.Cabins {
+		columns := []rune(cabin.Columns)
+		for row := cabin.FirstRow; row <= cabin.LastRow; row++ {
+			for i, column := range columns {
+				if column == ' ' {
+					continue
+				}
+				number := fmt.Sprintf("%d%c", row, column)
+				seats = append(seats, SeatSpec{
+					Number:       number,
+					Cabin:        cabin.Cabin,
+					Row:          row,
+					Column:       string(column),
+					Window:       i == 0 || i == len(columns)-1,
+					Aisle:        i > 0 && columns[i-1] == ' ' || i < len(columns)-1 && columns[i+1] == ' ',
+					AfterAisle:   i > 0 && columns[i-1] == ' ',
+					ExitRow:      exit[row],
+					ExtraLegroom: exit[row] || legroom[row],
+					Blocked:      blocked[number],
+				})
+			}
+		}
+	}
+	return seats
+}
+
+// NewFlight creates a flight whose seats are all available
+func NewFlight(id, name string, price float64, seats []SeatSpec) *Flight {
+	f := &Flight{
+		ID:          id,
+		Name:        name,
+		Seats:       len(seats),
+		Seat_metrix: make(map[string]*Seat, len(seats)),
+		Price:       price,
+	}
+	for _, spec := range seats {
+		cabin := spec.Cabin
+		if cabin == "" {
+			cabin = Economy
+		}
+		spec.Cabin = cabin
+		f.Seat_metrix[spec.Number] = &Seat{SeatSpec: spec, Status: Available}
+	}
+	return f
+}
+
+// Layout returns the specs of all seats in order
+func (f *Flight) Layout() []SeatSpec {
+	numbers := f.SeatNumbers()
+	seats := make([]SeatSpec, len(numbers))
+	for i, number := range numbers {
+		seats[i] = f.Seat_metrix[number].SeatSpec
+	}
+	return seats
+}
+
+// Cabins returns the cabins of the flight, business first
+func (f *Flight) Cabins() []Cabin {
+	var business, economy bool
+	for _, seat := range f.Seat_metrix {
+		business = business || seat.Cabin == Business
+		economy = economy || seat.Cabin == Economy
+	}
+	var cabins []Cabin
+	if business {
+		cabins = append(cabins, Business)
+	}
+	if economy {
+		cabins = append(cabins, Economy)
+	}
+	return cabins
+}
+
+// Availability returns the number of available seats in each cabin
+func (f *Flight) Availability() map[Cabin]int {
+	available := make(map[Cabin]int)
+	for _, cabin := range f.Cabins() {
+		available[cabin] = 0
+	}
+	for _, number := range f.AvailableSeats() {
+		available[f.Seat_metrix[number].Cabin]++
+	}
+	return available
+}
+
+// Fare returns the price of a seat class, or Price if the flight has
+// no fare for it
+func (f *Flight) Fare(class SeatClass) float64 {
+	if fare, ok := f.Fares[class]; ok {
+		return fare
+	}
+	return f.Price
+}
+
+// CabinFare returns the lowest fare of a cabin
+func (f *Flight) CabinFare(cabin Cabin) float64 {
+	if cabin == Business {
+		return f.Fare(ClassBusiness)
+	}
+	return f.Fare(ClassEconomy)
+}
+
+// SeatPrice returns the fare of the class of a seat
+func (f *Flight) SeatPrice(seatNo string) (float64, error) {
+	seat, exists := f.Seat_metrix[seatNo]
+	if !exists {
+		return 0, ErrSeatNotFound
+	}
+	return f.Fare(seat.Class()), nil
+}
+
+// LoadFactor returns the share of the sellable seats of a cabin that are
+// held or booked
+func (f *Flight) LoadFactor(cabin Cabin) float64 {
+	sellable := 0
+	for _, seat := range f.Seat_metrix {
+		if seat.Cabin == cabin && !seat.Blocked {
+			sellable++
+		}
+	}
+	if sellable == 0 {
+		return 0
+	}
+	return 1 - float64(f.Availability()[cabin])/float64(sellable)
+}
@@ -0,0 +1,284 @@
+// Package pricing computes fares from pluggable rules. A fare starts at
+// the flight's economy price and every rule multiplies it in turn; the
+// steps are kept with the quote as its audit. Quotes are honoured until
+// they expire, and only for the holder who first uses them.
+package pricing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flight-booking/models"
+	"math"
+	"sync"
+	"time"
+)
+
+// DemandWindow is how far back holds count as demand
+const DemandWindow = time.Hour
+
+// DefaultQuoteTTL is how long a quote is honoured when Engine.QuoteTTL
+// is unset
+const DefaultQuoteTTL = 15 * time.Minute
+
+// QuoteRetention is how long an expired quote can still be looked up
+const QuoteRetention = time.Hour
+
+// DefaultMaxQuotes is how many quotes are kept when Engine.MaxQuotes is
+// unset
+const DefaultMaxQuotes = 100000
+
+var (
+	ErrQuoteNotFound = errors.New("quote not found")
+	ErrQuoteExpired  = errors.New("quote expired")
+	ErrQuoteMismatch = errors.New("quote is for another seat")
+	ErrQuoteClaimed  = errors.New("quote was used by someone else")
+)
+
+type Engine struct {
+	// QuoteTTL is how long a quote is honoured
+	QuoteTTL time.Duration
+	// MaxQuotes caps the quotes kept, so clients asking for quotes cannot
+	// grow memory without bound; the oldest are dropped first
+	MaxQuotes int
+	rules     []Rule
+
+	mu     sync.Mutex
+	quotes map[string]*models.FareQuote
+	// order has the IDs of the kept quotes, oldest first
+	order []string
+	// claims has the holder that first used each quote
+	claims map[string]string
+	// demand has the times seats were held, per flight
+	demand map[string][]time.Time
+}
+
+// NewEngine creates an engine applying rules in order, or DefaultRules
+// if there are none
+func NewEngine(rules ...Rule) *Engine {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &Engine{
+		rules:  rules,
+		quotes: make(map[string]*models.FareQuote),
+		claims: make(map[string]string),
+		demand: make(map[string][]time.Time),
+	}
+}
+
+// Price computes the fare of a seat at now without issuing a quote
+func (e *Engine) Price(f *models.Flight, seatNo string, now time.Time) (models.FareQuote, error) {
+	seat, exists := f.Seat_metrix[seatNo]
+	if !exists {
+		return models.FareQuote{}, models.ErrSeatNotFound
+	}
+	if f.Departed(now) {
+		return models.FareQuote{}, models.ErrDeparted
+	}
+	price, steps := e.price(f, seat.SeatSpec, e.snapshot(f, now))
+	return models.FareQuote{
+		FlightID: f.ID,
+		SeatNo:   seatNo,
+		Class:    seat.Class(),
+		Price:    price,
+		Steps:    steps,
+		IssuedAt: now,
+	}, nil
+}
+
+// CabinPrice is the current fare of an economy or business seat without
+// extra legroom
+func (e *Engine) CabinPrice(f *models.Flight, cabin models.Cabin, now time.Time) float64 {
+	if cabin == "" {
+		cabin = models.Economy
+	}
+	price, _ := e.price(f, models.SeatSpec{Cabin: cabin}, e.snapshot(f, now))
+	return price
+}
+
+// PriceSeats computes the fare of every seat of a flight at now, e.g. for
+// a seat map. The flight-level inputs are computed once for all seats.
+func (e *Engine) PriceSeats(f *models.Flight, now time.Time) (map[string]float64, error) {
+	if f.Departed(now) {
+		return nil, models.ErrDeparted
+	}
+	snap := e.snapshot(f, now)
+	prices := make(map[string]float64, len(f.Seat_metrix))
+	for number, seat := range f.Seat_metrix {
+		prices[number], _ = e.price(f, seat.SeatSpec, snap)
+	}
+	return prices, nil
+}
+
+// inputs are the pricing inputs shared by the seats of a flight at one
+// moment
+type inputs struct {
+	demand          int
+	daysToDeparture int
+	// loadFactors is filled per cabin as seats of that cabin are priced
+	loadFactors map[models.Cabin]float64
+}
+
+// snapshot computes the flight-level inputs at now
+func (e *Engine) snapshot(f *models.Flight, now time.Time) *inputs {
+	snap := &inputs{demand: e.Demand(f.ID, now), loadFactors: make(map[models.Cabin]float64)}
+	if !f.Departure.IsZero() {
+		snap.daysToDeparture = int(math.Floor(f.Departure.Sub(now).Hours() / 24))
+	}
+	return snap
+}
+
+func (e *Engine) price(f *models.Flight, seat models.SeatSpec, snap *inputs) (float64, []models.FareStep) {
+	load, ok := snap.loadFactors[seat.Cabin]
+	if !ok {
+		load = f.LoadFactor(seat.Cabin)
+		snap.loadFactors[seat.Cabin] = load
+	}
+	in := Input{
+		Flight:          f,
+		Seat:            seat,
+		LoadFactor:      load,
+		Demand:          snap.demand,
+		DaysToDeparture: snap.daysToDeparture,
+	}
+
+	price := f.Price
+	steps := []models.FareStep{{Rule: "base", Detail: "economy fare", Multiplier: 1, Price: price}}
+	for _, rule := range e.rules {
+		multiplier, detail, ok := rule.Apply(in)
+		if !ok {
+			continue
+		}
+		price *= multiplier
+		steps = append(steps, models.FareStep{
+			Rule:       rule.Name(),
+			Detail:     detail,
+			Multiplier: math.Round(multiplier*1000) / 1000,
+			Price:      math.Round(price*100) / 100,
+		})
+	}
+	return math.Round(price), steps
+}
+
+// Quote prices a seat and keeps the price for QuoteTTL
+func (e *Engine) Quote(f *models.Flight, seatNo string) (models.FareQuote, error) {
+	now := time.Now()
+	quote, err := e.Price(f, seatNo, now)
+	if err != nil {
+		return quote, err
+	}
+	ttl := e.QuoteTTL
+	if ttl <= 0 {
+		ttl = DefaultQuoteTTL
+	}
+	quote.ExpiresAt = now.Add(ttl)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pruneLocked(now)
+	for {
+		quote.ID = newQuoteID()
+		if _, taken := e.quotes[quote.ID]; !taken {
+			break
+		}
+	}
+	e.quotes[quote.ID] = &quote
+	e.order = append(e.order, quote.ID)
+	return quote, nil
+}
+
+// pruneLocked drops the quotes past QuoteRetention and, while the engine
+// is full, the oldest ones
+func (e *Engine) pruneLocked(now time.Time) {
+	max := e.MaxQuotes
+	if max <= 0 {
+		max = DefaultMaxQuotes
+	}
+	cutoff := now.Add(-QuoteRetention)
+	drop := 0
+	for ; drop < len(e.order); drop++ {
+		q := e.quotes[e.order[drop]]
+		if len(e.order)-drop < max && q.Valid(cutoff) {
+			break
+		}
+		delete(e.quotes, e.order[drop])
+		delete(e.claims, e.order[drop])
+	}
+	e.order = e.order[drop:]
+}
+
+// Lookup returns an issued quote, including quotes that expired less
+// than QuoteRetention ago
+func (e *Engine) Lookup(id string) (models.FareQuote, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	quote, ok := e.quotes[id]
+	if !ok {
+		return models.FareQuote{}, ErrQuoteNotFound
+	}
+	return *quote, nil
+}
+
+// Honour returns the quote with id if it is still valid for the seat and
+// holder. The first holder to be honoured a quote claims it, so a quote
+// ID that leaks cannot be used for another hold or booking.
+func (e *Engine) Honour(id, flightID, seatNo, holder string) (models.FareQuote, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stored, ok := e.quotes[id]
+	if !ok {
+		return models.FareQuote{}, ErrQuoteNotFound
+	}
+	quote := *stored
+	if quote.FlightID != flightID || quote.SeatNo != seatNo {
+		return quote, ErrQuoteMismatch
+	}
+	if !quote.Valid(time.Now()) {
+		return quote, ErrQuoteExpired
+	}
+	if claimedBy, claimed := e.claims[id]; claimed && claimedBy != holder {
+		return quote, ErrQuoteClaimed
+	}
+	e.claims[id] = holder
+	return quote, nil
+}
+
+// RecordHold counts a seat held on a flight as demand
+func (e *Engine) RecordHold(flightID string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.demand[flightID] = append(recent(e.demand[flightID], now), now)
+}
+
+// Demand returns the number of seats held on a flight in the last
+// DemandWindow
+func (e *Engine) Demand(flightID string, now time.Time) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	holds := recent(e.demand[flightID], now)
+	if len(holds) == 0 {
+		delete(e.demand, flightID)
+	} else {
+		e.demand[flightID] = holds
+	}
+	return len(holds)
+}
+
+// recent drops the holds older than DemandWindow; holds are in order
+func recent(holds []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-DemandWindow)
+	i := 0
+	for i < len(holds) && !holds[i].After(cutoff) {
+		i++
+	}
+	return holds[i:]
+}
+
+func newQuoteID() string {
+	var b [5]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("no randomness: " + err.Error())
+	}
+	return "Q" + hex.EncodeToString(b[:])
+}
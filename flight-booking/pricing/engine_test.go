@@ -0,0 +1,175 @@
+package pricing
+
+import (
+	"errors"
+	"flight-booking/models"
+	"testing"
+	"time"
+)
+
+func TestPriceSteps(t *testing.T) {
+	f := ruleFlight()
+	quote, err := NewEngine().Price(f, "10A", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000 base, x1.2 economy plus, x0.9 empty cabin, x1.15 for 9 or 10
+	// days to departure, x1 without demand
+	if quote.Price != 1242 {
+		t.Errorf("price = %v, want 1242", quote.Price)
+	}
+	var rules []string
+	for _, step := range quote.Steps {
+		rules = append(rules, step.Rule)
+	}
+	want := []string{"base", "cabin", "load_factor", "days_to_departure", "demand"}
+	if len(rules) != len(want) {
+		t.Fatalf("steps = %v, want %v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Fatalf("steps = %v, want %v", rules, want)
+		}
+	}
+	if last := quote.Steps[len(quote.Steps)-1]; last.Price != quote.Price {
+		t.Errorf("last step price = %v, want the quoted %v", last.Price, quote.Price)
+	}
+
+	if _, err := NewEngine().Price(f, "99Z", time.Now()); !errors.Is(err, models.ErrSeatNotFound) {
+		t.Errorf("unknown seat: err = %v", err)
+	}
+	f.Departure = time.Now().Add(-time.Minute)
+	if _, err := NewEngine().Price(f, "10A", time.Now()); !errors.Is(err, models.ErrDeparted) {
+		t.Errorf("departed flight: err = %v", err)
+	}
+}
+
+func TestPriceSeats(t *testing.T) {
+	e := NewEngine()
+	f := ruleFlight()
+	if _, err := f.HoldSeat("11A", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	e.RecordHold(f.ID, time.Now())
+	now := time.Now()
+	prices, err := e.PriceSeats(f, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != len(f.Seat_metrix) {
+		t.Fatalf("priced %d seats, want %d", len(prices), len(f.Seat_metrix))
+	}
+	for number := range f.Seat_metrix {
+		quote, err := e.Price(f, number, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prices[number] != quote.Price {
+			t.Errorf("seat %s: PriceSeats = %v, Price = %v", number, prices[number], quote.Price)
+		}
+	}
+
+	f.Departure = now.Add(-time.Minute)
+	if _, err := e.PriceSeats(f, now); !errors.Is(err, models.ErrDeparted) {
+		t.Errorf("departed flight: err = %v", err)
+	}
+}
+
+func TestQuoteTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"default", 0, DefaultQuoteTTL},
+		{"negative", -time.Minute, DefaultQuoteTTL},
+		{"configured", 2 * time.Minute, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine()
+			e.QuoteTTL = tt.ttl
+			quote, err := e.Quote(ruleFlight(), "11A")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := quote.ExpiresAt.Sub(quote.IssuedAt); got != tt.want {
+				t.Errorf("quote is valid for %s, want %s", got, tt.want)
+			}
+			if quote.ID == "" {
+				t.Error("quote has no ID")
+			}
+			if stored, err := e.Lookup(quote.ID); err != nil || stored.Price != quote.Price {
+				t.Errorf("Lookup = %+v, %v", stored, err)
+			}
+		})
+	}
+}
+
+func TestHonour(t *testing.T) {
+	e := NewEngine()
+	f := ruleFlight()
+	quote, err := e.Quote(f, "11A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.QuoteTTL = time.Nanosecond
+	expired, err := e.Quote(f, "11A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		name    string
+		id      string
+		flight  string
+		seat    string
+		holder  string
+		wantErr error
+	}{
+		{"unknown quote", "Qnope", f.ID, "11A", "alice", ErrQuoteNotFound},
+		{"other seat", quote.ID, f.ID, "10A", "alice", ErrQuoteMismatch},
+		{"other flight", quote.ID, "T200", "11A", "alice", ErrQuoteMismatch},
+		{"expired", expired.ID, f.ID, "11A", "alice", ErrQuoteExpired},
+		{"first use claims it", quote.ID, f.ID, "11A", "alice", nil},
+		{"same holder again", quote.ID, f.ID, "11A", "alice", nil},
+		{"another holder", quote.ID, f.ID, "11A", "mallory", ErrQuoteClaimed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Honour(tt.id, tt.flight, tt.seat, tt.holder)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Honour err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Price != quote.Price {
+				t.Errorf("honoured price = %v, want %v", got.Price, quote.Price)
+			}
+		})
+	}
+
+	// Expired quotes can still be looked up for their audit
+	if _, err := e.Lookup(expired.ID); err != nil {
+		t.Errorf("Lookup of expired quote: %v", err)
+	}
+}
+
+func TestMaxQuotes(t *testing.T) {
+	e := NewEngine()
+	e.MaxQuotes = 2
+	f := ruleFlight()
+	var ids []string
+	for i := 0; i < 4; i++ {
+		quote, err := e.Quote(f, "11A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, quote.ID)
+	}
+	for i, id := range ids {
+		_, err := e.Lookup(id)
+		if kept := i >= len(ids)-e.MaxQuotes; kept != (err == nil) {
+			t.Errorf("Lookup of quote %d = %v, kept %v", i, err, kept)
+		}
+	}
+}
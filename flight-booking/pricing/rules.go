@@ -0,0 +1,134 @@
+package pricing
+
+import (
+	"flight-booking/models"
+	"fmt"
+	"math"
+)
+
+// Input is what a rule prices
+type Input struct {
+	Flight *models.Flight
+	Seat   models.SeatSpec
+	// LoadFactor is the held or booked share of the seat's cabin
+	LoadFactor float64
+	// DaysToDeparture is negative once the flight has left
+	DaysToDeparture int
+	// Demand is the number of seats held on the flight in the last
+	// DemandWindow
+	Demand int
+}
+
+// Rule adjusts a fare by a multiplier
+type Rule interface {
+	Name() string
+	// Apply returns the multiplier for in and why, or false if the rule
+	// does not apply
+	Apply(in Input) (multiplier float64, detail string, ok bool)
+}
+
+// Bucket applies Multiplier to values from From up to the From of the
+// next bucket
+type Bucket struct {
+	From       float64
+	Multiplier float64
+}
+
+// bucketFor returns the last bucket whose From is at most value
+func bucketFor(buckets []Bucket, value float64) (Bucket, bool) {
+	var found Bucket
+	ok := false
+	for _, b := range buckets {
+		if value >= b.From && (!ok || b.From >= found.From) {
+			found, ok = b, true
+		}
+	}
+	return found, ok
+}
+
+// CabinRule prices the seat class from the flight's fares
+type CabinRule struct{}
+
+func (CabinRule) Name() string { return "cabin" }
+
+func (CabinRule) Apply(in Input) (float64, string, bool) {
+	if in.Flight.Price <= 0 {
+		return 0, "", false
+	}
+	class := in.Seat.Class()
+	return in.Flight.Fare(class) / in.Flight.Price, fmt.Sprintf("%s seat in %s", class, in.Seat.Cabin), true
+}
+
+// LoadFactorRule raises fares as the cabin fills up; Buckets are load
+// factors from 0 to 1
+type LoadFactorRule struct {
+	Buckets []Bucket
+}
+
+func (LoadFactorRule) Name() string { return "load_factor" }
+
+func (r LoadFactorRule) Apply(in Input) (float64, string, bool) {
+	b, ok := bucketFor(r.Buckets, in.LoadFactor)
+	if !ok {
+		return 0, "", false
+	}
+	return b.Multiplier, fmt.Sprintf("%s cabin %.0f%% full", in.Seat.Cabin, in.LoadFactor*100), true
+}
+
+// DaysToDepartureRule prices by how early the seat is bought; Buckets
+// are days before departure
+type DaysToDepartureRule struct {
+	Buckets []Bucket
+}
+
+func (DaysToDepartureRule) Name() string { return "days_to_departure" }
+
+func (r DaysToDepartureRule) Apply(in Input) (float64, string, bool) {
+	if in.Flight.Departure.IsZero() {
+		return 0, "", false
+	}
+	b, ok := bucketFor(r.Buckets, float64(in.DaysToDeparture))
+	if !ok {
+		return 0, "", false
+	}
+	return b.Multiplier, fmt.Sprintf("%d days to departure", in.DaysToDeparture), true
+}
+
+// DemandRule adds Step for every Per seats held on the flight in the last
+// DemandWindow, up to Max
+type DemandRule struct {
+	Per  int
+	Step float64
+	Max  float64
+}
+
+func (DemandRule) Name() string { return "demand" }
+
+func (r DemandRule) Apply(in Input) (float64, string, bool) {
+	if r.Per <= 0 {
+		return 0, "", false
+	}
+	raise := math.Min(r.Max, r.Step*float64(in.Demand/r.Per))
+	return 1 + raise, fmt.Sprintf("%d seats held in the last hour", in.Demand), true
+}
+
+// DefaultRules are the rules of an engine created without any
+func DefaultRules() []Rule {
+	return []Rule{
+		CabinRule{},
+		LoadFactorRule{Buckets: []Bucket{
+			{From: 0, Multiplier: 0.9},
+			{From: 0.5, Multiplier: 1},
+			{From: 0.8, Multiplier: 1.25},
+			{From: 0.95, Multiplier: 1.5},
+		}},
+		DaysToDepartureRule{Buckets: []Bucket{
+			{From: 0, Multiplier: 1.5},
+			{From: 3, Multiplier: 1.3},
+			{From: 7, Multiplier: 1.15},
+			{From: 14, Multiplier: 1},
+			{From: 30, Multiplier: 0.85},
+		}},
+		DemandRule{Per: 5, Step: 0.05, Max: 0.3},
+	}
+}
@@ -0,0 +1,114 @@
+package pricing
+
+import (
+	"flight-booking/models"
+	"testing"
+	"time"
+)
+
+func TestBucketFor(t *testing.T) {
+	buckets := []Bucket{
+		{From: 0.5, Multiplier: 1},
+		{From: 0, Multiplier: 0.9},
+		{From: 0.8, Multiplier: 1.25},
+	}
+	tests := []struct {
+		name    string
+		buckets []Bucket
+		value   float64
+		want    float64
+		wantOK  bool
+	}{
+		{"no buckets", nil, 1, 0, false},
+		{"below the first bucket", buckets, -1, 0, false},
+		{"at the first bucket", buckets, 0, 0.9, true},
+		{"inside a bucket", buckets, 0.3, 0.9, true},
+		{"at a bucket boundary", buckets, 0.5, 1, true},
+		{"just below a boundary", buckets, 0.79, 1, true},
+		{"past the last bucket", buckets, 5, 1.25, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ok := bucketFor(tt.buckets, tt.value)
+			if ok != tt.wantOK || b.Multiplier != tt.want {
+				t.Fatalf("bucketFor(%v) = %v, %v; want multiplier %v, %v", tt.value, b, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func ruleFlight() *models.Flight {
+	f := models.NewFlight("T100", "Test Air", 1000, []models.SeatSpec{
+		{Number: "1A", Cabin: models.Business, Row: 1, Column: "A"},
+		{Number: "10A", Cabin: models.Economy, Row: 10, Column: "A", ExitRow: true, ExtraLegroom: true},
+		{Number: "11A", Cabin: models.Economy, Row: 11, Column: "A"},
+	})
+	f.Fares = map[models.SeatClass]float64{
+		models.ClassEconomy:     1000,
+		models.ClassEconomyPlus: 1200,
+		models.ClassBusiness:    3500,
+	}
+	f.Departure = time.Now().Add(10 * 24 * time.Hour)
+	return f
+}
+
+func TestRules(t *testing.T) {
+	f := ruleFlight()
+	business := f.Seat_metrix["1A"].SeatSpec
+	exitRow := f.Seat_metrix["10A"].SeatSpec
+	economy := f.Seat_metrix["11A"].SeatSpec
+	free := models.NewFlight("F1", "Free", 0, []models.SeatSpec{economy})
+	undated := models.NewFlight("U1", "Undated", 1000, []models.SeatSpec{economy})
+	defaults := DefaultRules()
+
+	tests := []struct {
+		name       string
+		rule       Rule
+		in         Input
+		multiplier float64
+		detail     string
+		ok         bool
+	}{
+		{"cabin economy", CabinRule{}, Input{Flight: f, Seat: economy}, 1, "economy seat in economy", true},
+		{"cabin extra legroom", CabinRule{}, Input{Flight: f, Seat: exitRow}, 1.2, "economy_plus seat in economy", true},
+		{"cabin business", CabinRule{}, Input{Flight: f, Seat: business}, 3.5, "business seat in business", true},
+		{"cabin without a base price", CabinRule{}, Input{Flight: free, Seat: economy}, 0, "", false},
+
+		{"load factor empty", defaults[1], Input{Flight: f, Seat: economy, LoadFactor: 0}, 0.9, "economy cabin 0% full", true},
+		{"load factor half", defaults[1], Input{Flight: f, Seat: economy, LoadFactor: 0.5}, 1, "economy cabin 50% full", true},
+		{"load factor nearly full", defaults[1], Input{Flight: f, Seat: business, LoadFactor: 0.9}, 1.25, "business cabin 90% full", true},
+		{"load factor full", defaults[1], Input{Flight: f, Seat: economy, LoadFactor: 1}, 1.5, "economy cabin 100% full", true},
+		{"load factor without buckets", LoadFactorRule{}, Input{Flight: f, Seat: economy}, 0, "", false},
+
+		{"departure today", defaults[2], Input{Flight: f, DaysToDeparture: 0}, 1.5, "0 days to departure", true},
+		{"departure in 5 days", defaults[2], Input{Flight: f, DaysToDeparture: 5}, 1.3, "5 days to departure", true},
+		{"departure in 7 days", defaults[2], Input{Flight: f, DaysToDeparture: 7}, 1.15, "7 days to departure", true},
+		{"departure in 20 days", defaults[2], Input{Flight: f, DaysToDeparture: 20}, 1, "20 days to departure", true},
+		{"departure in 60 days", defaults[2], Input{Flight: f, DaysToDeparture: 60}, 0.85, "60 days to departure", true},
+		{"departed", defaults[2], Input{Flight: f, DaysToDeparture: -1}, 0, "", false},
+		{"no departure time", defaults[2], Input{Flight: undated, DaysToDeparture: 5}, 0, "", false},
+
+		{"no demand", defaults[3], Input{Flight: f, Demand: 0}, 1, "0 seats held in the last hour", true},
+		{"demand below a step", defaults[3], Input{Flight: f, Demand: 4}, 1, "4 seats held in the last hour", true},
+		{"demand of two steps", defaults[3], Input{Flight: f, Demand: 12}, 1.1, "12 seats held in the last hour", true},
+		{"demand over the cap", defaults[3], Input{Flight: f, Demand: 100}, 1.3, "100 seats held in the last hour", true},
+		{"demand without a step size", DemandRule{}, Input{Flight: f, Demand: 100}, 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiplier, detail, ok := tt.rule.Apply(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("%s applies = %v, want %v", tt.rule.Name(), ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if diff := multiplier - tt.multiplier; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("multiplier = %v, want %v", multiplier, tt.multiplier)
+			}
+			if detail != tt.detail {
+				t.Errorf("detail = %q, want %q", detail, tt.detail)
+			}
+		})
+	}
+}
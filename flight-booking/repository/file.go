@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flight-booking/models"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultSnapshotEvery is how many journal entries File writes before it
+// folds them into a new snapshot
+const DefaultSnapshotEvery = 1000
+
+const (
+	journalFile  = "journal.log"
+	snapshotFile = "snapshot.json"
+)
+
+// entry is one line of the journal. Every entry overwrites a whole
+// record, so replaying an entry that is already in the snapshot (after
+// a crash between writing a snapshot and truncating the journal) is
+// harmless.
+type entry struct {
+	Flight   *FlightRecord     `json:"flight,omitempty"`
+	FlightID string            `json:"flight_id,omitempty"`
+	Seat     *models.SeatState `json:"seat,omitempty"`
+	Booking  *BookingRecord    `json:"booking,omitempty"`
+}
+
+// File is a Repository kept in a directory: every change is appended and
+// synced to a journal before it is applied, and the journal is folded
+// into a snapshot every SnapshotEvery entries and on Close.
+type File struct {
+	SnapshotEvery int
+
+	dir string
+	mem *Memory
+
+	mu      sync.Mutex
+	journal *os.File
+	entries int
+	// broken is set when a failed write could not be rolled back
+	broken error
+}
+
+// OpenFile loads the repository in dir, creating it if needed
+func OpenFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &File{
+		SnapshotEvery: DefaultSnapshotEvery,
+		dir:           dir,
+		mem:           NewMemory(),
+	}
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := r.replay(); err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+	return r, nil
+}
+
+func (r *File) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("reading %s: %w", snapshotFile, err)
+	}
+	r.mem.restore(snap)
+	return nil
+}
+
+// replay applies the journal. A torn last line, left by a crash in the
+// middle of a write, is cut off; damage anywhere else is an error.
+func (r *File) replay() error {
+	path := filepath.Join(r.dir, journalFile)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(data)) > 0 {
+				log.Printf("Discarding incomplete last entry of %s", path)
+				return os.Truncate(path, offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("%s line %d: %w", journalFile, line, err)
+		}
+		r.apply(e)
+		r.entries++
+		offset += int64(len(data))
+	}
+}
+
+func (r *File) apply(e entry) {
+	switch {
+	case e.Flight != nil:
+		r.mem.SaveFlight(*e.Flight)
+	case e.Seat != nil:
+		r.mem.SaveSeat(e.FlightID, *e.Seat)
+	case e.Booking != nil:
+		r.mem.SaveBooking(*e.Booking)
+	}
+}
+
+// commit appends e to the journal, syncs it and then applies it
+func (r *File) commit(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.journal == nil {
+		return errors.New("repository is closed")
+	}
+	if r.broken != nil {
+		return r.broken
+	}
+	info, err := r.journal.Stat()
+	if err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	if _, err := r.journal.Write(data); err != nil {
+		return r.rollback(info.Size(), fmt.Errorf("writing journal: %w", err))
+	}
+	if err := r.journal.Sync(); err != nil {
+		return r.rollback(info.Size(), fmt.Errorf("syncing journal: %w", err))
+	}
+	r.apply(e)
+
+	r.entries++
+	if r.SnapshotEvery > 0 && r.entries >= r.SnapshotEvery {
+		// The entry is durable in the journal, so a failed snapshot only
+		// means a longer replay
+		if err := r.snapshotLocked(); err != nil {
+			log.Printf("Snapshot failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// rollback cuts the journal back to size after a failed write, so that a
+// partly written entry (e.g. on a full disk) does not end up in the middle
+// of the journal where replay cannot skip it. If that fails too, the
+// journal takes no more writes.
+func (r *File) rollback(size int64, err error) error {
+	if terr := r.journal.Truncate(size); terr != nil {
+		r.broken = fmt.Errorf("journal unusable after %v: %w", err, terr)
+		return r.broken
+	}
+	return err
+}
+
+// snapshotLocked writes the current state to a new snapshot and empties
+// the journal
+func (r *File) snapshotLocked() error {
+	data, err := json.Marshal(r.mem.snapshot())
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, snapshotFile+".tmp")
+	if err := writeSynced(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(r.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if dir, err := os.Open(r.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	if err := r.journal.Truncate(0); err != nil {
+		return err
+	}
+	r.entries = 0
+	return nil
+}
+
+func writeSynced(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *File) SaveFlight(flight FlightRecord) error {
+	return r.commit(entry{Flight: &flight})
+}
+
+func (r *File) Flights() ([]FlightRecord, error) {
+	return r.mem.Flights()
+}
+
+func (r *File) SaveSeat(flightID string, seat models.SeatState) error {
+	return r.commit(entry{FlightID: flightID, Seat: &seat})
+}
+
+func (r *File) Seats(flightID string) ([]models.SeatState, error) {
+	return r.mem.Seats(flightID)
+}
+
+func (r *File) SaveBooking(booking BookingRecord) error {
+	return r.commit(entry{Booking: &booking})
+}
+
+func (r *File) Booking(id string) (BookingRecord, error) {
+	return r.mem.Booking(id)
+}
+
+func (r *File) Bookings() ([]BookingRecord, error) {
+	return r.mem.Bookings()
+}
+
+// Close writes a final snapshot and closes the journal
+func (r *File) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.journal == nil {
+		return nil
+	}
+	err := r.snapshotLocked()
+	if cerr := r.journal.Close(); err == nil {
+		err = cerr
+	}
+	r.journal = nil
+	return err
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package repository_test
+
+import (
+	"flight-booking/models"
+	"flight-booking/repository"
+	"os/signal"
+	"syscall"
+	"testing"
+)
+
+// TestFileRollsBackPartialWrite lets the file size limit cut a journal
+// write short, as a full disk would, and checks that the partial entry is
+// removed again
+func TestFileRollsBackPartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	r := openFile(t, dir, 0)
+	mustSave(t, r.SaveFlight(repository.NewFlightRecord(testFlight())))
+	size := journalSize(t, dir)
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Skip(err)
+	}
+	signal.Ignore(syscall.SIGXFSZ)
+	defer signal.Reset(syscall.SIGXFSZ)
+	short := limit
+	short.Cur = uint64(size) + 16
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &short); err != nil {
+		t.Skip(err)
+	}
+	err := r.SaveSeat("T100", models.SeatState{Number: "1A", Status: models.Booked, LockedBy: "BK1"})
+	if rerr := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit); rerr != nil {
+		t.Fatal(rerr)
+	}
+	if err == nil {
+		t.Fatal("SaveSeat succeeded beyond the file size limit")
+	}
+	if got := journalSize(t, dir); got != size {
+		t.Fatalf("journal is %d bytes after the failed write, want %d", got, size)
+	}
+
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1B", Status: models.Booked, LockedBy: "BK2"}))
+	reopened := openFile(t, dir, 0)
+	if seat := seatOf(t, reopened, "T100", "1A"); seat.Status != models.Available {
+		t.Fatalf("seat 1A = %+v, want the failed write to be gone", seat)
+	}
+	if seat := seatOf(t, reopened, "T100", "1B"); seat.Status != models.Booked {
+		t.Fatalf("seat 1B = %+v, want booked", seat)
+	}
+}
@@ -0,0 +1,242 @@
+package repository_test
+
+import (
+	"bytes"
+	"flight-booking/models"
+	"flight-booking/repository"
+	"flight-booking/service"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testFlight() *models.Flight {
+	return models.NewFlight("T100", "Test Air", 1000, []models.SeatSpec{
+		{Number: "1A", Cabin: models.Economy, Row: 1, Column: "A"},
+		{Number: "1B", Cabin: models.Economy, Row: 1, Column: "B"},
+	})
+}
+
+// openFile opens the repository in dir and closes it at the end of the test
+func openFile(t *testing.T, dir string, snapshotEvery int) *repository.File {
+	t.Helper()
+	r, err := repository.OpenFile(dir)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	r.SnapshotEvery = snapshotEvery
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func mustSave(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+}
+
+func journalSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, "journal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// seatOf returns the stored state of a seat, or an available seat
+func seatOf(t *testing.T, r repository.Repository, flightID, number string) models.SeatState {
+	t.Helper()
+	seats, err := r.Seats(flightID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seat := range seats {
+		if seat.Number == number {
+			return seat
+		}
+	}
+	return models.SeatState{Number: number}
+}
+
+func TestFileReplaysJournal(t *testing.T) {
+	dir := t.TempDir()
+	r := openFile(t, dir, 0)
+	mustSave(t, r.SaveFlight(repository.NewFlightRecord(testFlight())))
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1A", Status: models.Held, LockedBy: "BK1", LockExpiry: time.Now().Add(time.Minute)}))
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1A", Status: models.Booked, LockedBy: "BK1"}))
+	mustSave(t, r.SaveBooking(repository.BookingRecord{ID: "BK1", FlightID: "T100", SeatNo: "1A", Status: models.BookingConfirmed}))
+
+	// Reopened while r is still open, so nothing was folded into a snapshot
+	reopened := openFile(t, dir, 0)
+	if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); !os.IsNotExist(err) {
+		t.Fatalf("unexpected snapshot: %v", err)
+	}
+	flights, _ := reopened.Flights()
+	if len(flights) != 1 || flights[0].ID != "T100" || len(flights[0].Seats) != 2 {
+		t.Fatalf("flights = %+v", flights)
+	}
+	if seat := seatOf(t, reopened, "T100", "1A"); seat.Status != models.Booked || seat.LockedBy != "BK1" {
+		t.Fatalf("seat 1A = %+v, want booked by BK1", seat)
+	}
+	booking, err := reopened.Booking("BK1")
+	if err != nil || booking.Status != models.BookingConfirmed {
+		t.Fatalf("booking = %+v, %v", booking, err)
+	}
+}
+
+func TestFileDiscardsTornLastEntry(t *testing.T) {
+	dir := t.TempDir()
+	r := openFile(t, dir, 0)
+	mustSave(t, r.SaveFlight(repository.NewFlightRecord(testFlight())))
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1A", Status: models.Booked, LockedBy: "BK1"}))
+	size := journalSize(t, dir)
+
+	f, err := os.OpenFile(filepath.Join(dir, "journal.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"flight_id":"T100","seat":{"number":"1B","sta`)
+	f.Close()
+
+	reopened := openFile(t, dir, 0)
+	if got := journalSize(t, dir); got != size {
+		t.Fatalf("journal is %d bytes after replay, want %d", got, size)
+	}
+	if seat := seatOf(t, reopened, "T100", "1A"); seat.Status != models.Booked {
+		t.Fatalf("seat 1A = %+v, want booked", seat)
+	}
+
+	// New entries follow the cut, so the journal replays again
+	mustSave(t, reopened.SaveSeat("T100", models.SeatState{Number: "1B", Status: models.Booked, LockedBy: "BK2"}))
+	again := openFile(t, dir, 0)
+	if seat := seatOf(t, again, "T100", "1B"); seat.Status != models.Booked || seat.LockedBy != "BK2" {
+		t.Fatalf("seat 1B = %+v, want booked by BK2", seat)
+	}
+}
+
+func TestFileRejectsDamagedEntry(t *testing.T) {
+	dir := t.TempDir()
+	r := openFile(t, dir, 0)
+	mustSave(t, r.SaveFlight(repository.NewFlightRecord(testFlight())))
+	r.Close()
+
+	// A damaged entry followed by a complete one cannot be a torn write
+	f, err := os.OpenFile(filepath.Join(dir, "journal.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"seat\":\n{}\n")
+	f.Close()
+	if _, err := repository.OpenFile(dir); err == nil {
+		t.Fatal("OpenFile accepted a damaged journal")
+	}
+}
+
+func TestFileFoldsJournalIntoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	r := openFile(t, dir, 2)
+	mustSave(t, r.SaveFlight(repository.NewFlightRecord(testFlight())))
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1A", Status: models.Booked, LockedBy: "BK1"}))
+	// The second entry triggered a snapshot; this one stays in the journal
+	mustSave(t, r.SaveSeat("T100", models.SeatState{Number: "1B", Status: models.Held, LockedBy: "BK2", LockExpiry: time.Now().Add(time.Minute)}))
+
+	if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); err != nil {
+		t.Fatalf("no snapshot: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "journal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Fatalf("journal has %d entries after the snapshot, want 1", lines)
+	}
+
+	reopened := openFile(t, dir, 2)
+	if seat := seatOf(t, reopened, "T100", "1A"); seat.Status != models.Booked {
+		t.Fatalf("seat 1A from snapshot = %+v, want booked", seat)
+	}
+	if seat := seatOf(t, reopened, "T100", "1B"); seat.Status != models.Held || seat.LockedBy != "BK2" {
+		t.Fatalf("seat 1B from journal = %+v, want held by BK2", seat)
+	}
+
+	reopened.Close()
+	r.Close()
+	if size := journalSize(t, dir); size != 0 {
+		t.Fatalf("journal is %d bytes after Close, want 0", size)
+	}
+	closed := openFile(t, dir, 2)
+	if seat := seatOf(t, closed, "T100", "1B"); seat.Status != models.Held {
+		t.Fatalf("seat 1B after Close = %+v, want held", seat)
+	}
+}
+
+// TestSettleAfterRestart restarts the booking service with a booking
+// whose payment was in progress and checks that its seat decides it
+func TestSettleAfterRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		// seat puts the seat of booking BK1 in its state before the restart
+		seat func(f *models.Flight) error
+		want models.BookingStatus
+	}{
+		{"confirmed", func(f *models.Flight) error {
+			if _, err := f.HoldSeat("1A", "BK1"); err != nil {
+				return err
+			}
+			return f.ConfirmSeat("1A", "BK1")
+		}, models.BookingConfirmed},
+		{"still held", func(f *models.Flight) error {
+			_, err := f.HoldSeat("1A", "BK1")
+			return err
+		}, models.BookingHeld},
+		{"released", func(f *models.Flight) error { return nil }, models.BookingExpired},
+		{"held by another booking", func(f *models.Flight) error {
+			_, err := f.HoldSeat("1A", "BK2")
+			return err
+		}, models.BookingExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := openFile(t, dir, 0)
+			flights, err := service.LoadFlights(r, func() []*models.Flight {
+				return []*models.Flight{testFlight()}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mustSave(t, tt.seat(flights[0]))
+			mustSave(t, r.SaveBooking(repository.BookingRecord{
+				ID: "BK1", FlightID: "T100", SeatNo: "1A", UserName: "alice",
+				Status: models.BookingPaying, Amount: 1000, CreatedAt: time.Now(),
+			}))
+			r.Close()
+
+			restarted := openFile(t, dir, 0)
+			flights, err = service.LoadFlights(restarted, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store, err := service.NewBookingStore(restarted, flights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			booking, err := store.Get("BK1")
+			if err != nil || booking.Status != tt.want {
+				t.Fatalf("booking = %s, %v; want %s", booking.Status, err, tt.want)
+			}
+			if tt.want == models.BookingHeld && booking.HoldExpiry.IsZero() {
+				t.Fatal("settled hold has no expiry")
+			}
+
+			// The decision is saved, not made again on the next restart
+			record, err := restarted.Booking("BK1")
+			if err != nil || record.Status != tt.want {
+				t.Fatalf("stored booking = %s, %v; want %s", record.Status, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"flight-booking/models"
+	"sort"
+	"sync"
+)
+
+// Memory is a Repository that keeps everything in process
+type Memory struct {
+	mu       sync.RWMutex
+	flights  map[string]FlightRecord
+	order    []string
+	seats    map[string]map[string]models.SeatState
+	bookings map[string]BookingRecord
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		flights:  make(map[string]FlightRecord),
+		seats:    make(map[string]map[string]models.SeatState),
+		bookings: make(map[string]BookingRecord),
+	}
+}
+
+func (m *Memory) SaveFlight(flight FlightRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.flights[flight.ID]; !exists {
+		m.order = append(m.order, flight.ID)
+	}
+	flight.Seats = append([]models.SeatSpec(nil), flight.Seats...)
+	m.flights[flight.ID] = flight
+	return nil
+}
+
+func (m *Memory) Flights() ([]FlightRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	flights := make([]FlightRecord, len(m.order))
+	for i, id := range m.order {
+		flights[i] = m.flights[id]
+	}
+	return flights, nil
+}
+
+func (m *Memory) SaveSeat(flightID string, seat models.SeatState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seats, ok := m.seats[flightID]
+	if !ok {
+		seats = make(map[string]models.SeatState)
+		m.seats[flightID] = seats
+	}
+	seats[seat.Number] = seat
+	return nil
+}
+
+func (m *Memory) Seats(flightID string) ([]models.SeatState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seats := make([]models.SeatState, 0, len(m.seats[flightID]))
+	for _, seat := range m.seats[flightID] {
+		seats = append(seats, seat)
+	}
+	sort.Slice(seats, func(i, j int) bool { return seats[i].Number < seats[j].Number })
+	return seats, nil
+}
+
+func (m *Memory) SaveBooking(booking BookingRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bookings[booking.ID] = booking
+	return nil
+}
+
+func (m *Memory) Booking(id string) (BookingRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	booking, ok := m.bookings[id]
+	if !ok {
+		return BookingRecord{}, ErrNotFound
+	}
+	return booking, nil
+}
+
+func (m *Memory) Bookings() ([]BookingRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bookings := make([]BookingRecord, 0, len(m.bookings))
+	for _, booking := range m.bookings {
+		bookings = append(bookings, booking)
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].CreatedAt.Before(bookings[j].CreatedAt) })
+	return bookings, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// snapshot is the full state of a Memory, as written by File
+type snapshot struct {
+	Flights  []FlightRecord                `json:"flights"`
+	Seats    map[string][]models.SeatState `json:"seats"`
+	Bookings []BookingRecord               `json:"bookings"`
+}
+
+func (m *Memory) snapshot() snapshot {
+	flights, _ := m.Flights()
+	bookings, _ := m.Bookings()
+	snap := snapshot{
+		Flights:  flights,
+		Seats:    make(map[string][]models.SeatState),
+		Bookings: bookings,
+	}
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.seats))
+	for id := range m.seats {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
+	for _, id := range ids {
+		snap.Seats[id], _ = m.Seats(id)
+	}
+	return snap
+}
+
+func (m *Memory) restore(snap snapshot) {
+	for _, flight := range snap.Flights {
+		m.SaveFlight(flight)
+	}
+	for flightID, seats := range snap.Seats {
+		for _, seat := range seats {
+			m.SaveSeat(flightID, seat)
+		}
+	}
+	for _, booking := range snap.Bookings {
+		m.SaveBooking(booking)
+	}
+}
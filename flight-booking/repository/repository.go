@@ -0,0 +1,105 @@
+// Package repository stores flights, seat states and bookings so the
+// booking service can be restarted without losing them. Memory keeps
+// everything in process; File adds an append-only journal and periodic
+// snapshots on disk.
+package repository
+
+import (
+	"errors"
+	"flight-booking/models"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// FlightRecord is the stored form of a flight
+type FlightRecord struct {
+	ID          string                       `json:"id"`
+	Name        string                       `json:"name"`
+	Price       float64                      `json:"price"`
+	Fares       map[models.SeatClass]float64 `json:"fares,omitempty"`
+	Origin      string                       `json:"origin,omitempty"`
+	Destination string                       `json:"destination,omitempty"`
+	Departure   time.Time                    `json:"departure"`
+	Arrival     time.Time                    `json:"arrival"`
+	Aircraft    string                       `json:"aircraft,omitempty"`
+	Seats       []models.SeatSpec            `json:"seats"`
+}
+
+// BookingRecord is the stored form of a booking
+type BookingRecord struct {
+	ID            string               `json:"id"`
+	FlightID      string               `json:"flight_id"`
+	SeatNo        string               `json:"seat"`
+	UserName      string               `json:"user"`
+	Status        models.BookingStatus `json:"status"`
+	Amount        float64              `json:"amount"`
+	PaymentMethod string               `json:"payment_method,omitempty"`
+	HoldExpiry    time.Time            `json:"hold_expiry,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	// Quote is the fare charged and its audit
+	Quote *models.FareQuote `json:"quote,omitempty"`
+}
+
+type FlightRepository interface {
+	SaveFlight(flight FlightRecord) error
+	// Flights returns all flights in the order they were first saved
+	Flights() ([]FlightRecord, error)
+}
+
+// SeatRepository stores the seats that changed; seats without a record
+// are available. It is the models.SeatJournal of restored flights.
+type SeatRepository interface {
+	models.SeatJournal
+	Seats(flightID string) ([]models.SeatState, error)
+}
+
+type BookingRepository interface {
+	SaveBooking(booking BookingRecord) error
+	Booking(id string) (BookingRecord, error)
+	Bookings() ([]BookingRecord, error)
+}
+
+type Repository interface {
+	FlightRepository
+	SeatRepository
+	BookingRepository
+	Close() error
+}
+
+// NewFlightRecord describes flight for SaveFlight
+func NewFlightRecord(flight *models.Flight) FlightRecord {
+	return FlightRecord{
+		ID:          flight.ID,
+		Name:        flight.Name,
+		Price:       flight.Price,
+		Fares:       flight.Fares,
+		Origin:      flight.Origin,
+		Destination: flight.Destination,
+		Departure:   flight.Departure,
+		Arrival:     flight.Arrival,
+		Aircraft:    flight.Aircraft,
+		Seats:       flight.Layout(),
+	}
+}
+
+// NewBookingRecord describes booking for SaveBooking
+func NewBookingRecord(booking models.Booking) BookingRecord {
+	return BookingRecord{
+		ID:            booking.ID,
+		FlightID:      booking.Flight.ID,
+		SeatNo:        booking.SeatNo,
+		UserName:      booking.UserName,
+		Status:        booking.Status,
+		Amount:        booking.Amount,
+		PaymentMethod: booking.PaymentMethod,
+		HoldExpiry:    booking.HoldExpiry,
+		CreatedAt:     booking.CreatedAt,
+		Quote:         booking.Quote,
+	}
+}
+
+var (
+	_ Repository = (*Memory)(nil)
+	_ Repository = (*File)(nil)
+)
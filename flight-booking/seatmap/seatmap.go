@@ -0,0 +1,133 @@
+// Package seatmap lays out the seats of a flight row by row for the
+// REST API, the dashboard and the command line.
+package seatmap
+
+import (
+	"flight-booking/models"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Seat struct {
+	Number       string           `json:"number"`
+	Column       string           `json:"column"`
+	Status       string           `json:"status"`
+	Class        models.SeatClass `json:"class"`
+	Price        float64          `json:"price"`
+	Window       bool             `json:"window,omitempty"`
+	Aisle        bool             `json:"aisle,omitempty"`
+	afterAisle   bool
+	ExtraLegroom bool `json:"extra_legroom,omitempty"`
+	Blocked      bool `json:"blocked,omitempty"`
+}
+
+type Row struct {
+	Number int          `json:"number"`
+	Cabin  models.Cabin `json:"cabin"`
+	Exit   bool         `json:"exit,omitempty"`
+	Seats  []Seat       `json:"seats"`
+}
+
+type Map struct {
+	FlightID string `json:"flight_id"`
+	Aircraft string `json:"aircraft"`
+	Rows     []Row  `json:"rows"`
+}
+
+// New takes the current seat map of f with the seats priced by price, or
+// by the fares of the flight if price is nil
+func New(f *models.Flight, price func(seat models.SeatSpec) float64) Map {
+	if price == nil {
+		price = func(seat models.SeatSpec) float64 { return f.Fare(seat.Class()) }
+	}
+	m := Map{FlightID: f.ID, Aircraft: f.Aircraft}
+	// Layout and SeatMap are both in seat number order
+	specs, states := f.Layout(), f.SeatMap()
+	for i, spec := range specs {
+		if len(m.Rows) == 0 || m.Rows[len(m.Rows)-1].Number != spec.Row {
+			m.Rows = append(m.Rows, Row{Number: spec.Row, Cabin: spec.Cabin})
+		}
+		row := &m.Rows[len(m.Rows)-1]
+		row.Exit = row.Exit || spec.ExitRow
+
+		status := states[i].Status.String()
+		if spec.Blocked {
+			status = "blocked"
+		}
+		row.Seats = append(row.Seats, Seat{
+			Number:       spec.Number,
+			Column:       spec.Column,
+			Status:       status,
+			Class:        spec.Class(),
+			Price:        price(spec),
+			Window:       spec.Window,
+			Aisle:        spec.Aisle,
+			afterAisle:   spec.AfterAisle,
+			ExtraLegroom: spec.ExtraLegroom,
+			Blocked:      spec.Blocked,
+		})
+	}
+	return m
+}
+
+// Legend explains the seat symbols of WriteASCII
+const Legend = ". available  + extra legroom  h held  X booked  # blocked"
+
+// WriteASCII draws the map with one line per row and a gap for each
+// aisle, starting every cabin with its column letters:
+//
+//	business  AC DF
+//	       1  .. hX
+//	economy   ABC DEF
+//	       3  +++ +++
+//	      12  +++ +++  exit
+func (m Map) WriteASCII(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n", m.FlightID, m.Aircraft)
+	header := ""
+	for _, row := range m.Rows {
+		columns, cells := row.draw()
+		if next := string(row.Cabin) + columns; next != header {
+			header = next
+			fmt.Fprintf(&b, "\n%-10s%s\n", row.Cabin, columns)
+		}
+		fmt.Fprintf(&b, "%8d  %s", row.Number, cells)
+		if row.Exit {
+			b.WriteString("  exit")
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "\n%s\n", Legend)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// draw returns the column letters and seat symbols of the row, with a
+// space for each aisle
+func (r Row) draw() (string, string) {
+	var columns, cells strings.Builder
+	for i, seat := range r.Seats {
+		if i > 0 && seat.afterAisle {
+			columns.WriteByte(' ')
+			cells.WriteByte(' ')
+		}
+		columns.WriteString(seat.Column)
+		cells.WriteByte(seat.symbol())
+	}
+	return columns.String(), cells.String()
+}
+
+func (s Seat) symbol() byte {
+	switch {
+	case s.Blocked:
+		return '#'
+	case s.Status == "booked":
+		return 'X'
+	case s.Status == "held":
+		return 'h'
+	case s.ExtraLegroom:
+		return '+'
+	}
+	return '.'
+}
@@ -0,0 +1,115 @@
+package seatmap
+
+import (
+	"flight-booking/models"
+	"strings"
+	"testing"
+)
+
+// testFlight seats a business row of AC DF and three economy rows of
+// ABC DEF; row 3 is an exit row and 4F is blocked
+func testFlight() *models.Flight {
+	layout := models.Layout{
+		Model: "T1",
+		Cabins: []models.CabinLayout{
+			{Cabin: models.Business, FirstRow: 1, LastRow: 1, Columns: "AC DF"},
+			{Cabin: models.Economy, FirstRow: 2, LastRow: 4, Columns: "ABC DEF"},
+		},
+		ExitRows: []int{3},
+		Blocked:  []string{"4F"},
+	}
+	f := models.NewFlight("T100", "Test Air", 1000, layout.Seats())
+	f.Aircraft = layout.Model
+	f.Fares = map[models.SeatClass]float64{
+		models.ClassEconomy:     1000,
+		models.ClassEconomyPlus: 1200,
+		models.ClassBusiness:    3500,
+	}
+	return f
+}
+
+func TestNew(t *testing.T) {
+	f := testFlight()
+	if _, err := f.HoldSeat("1C", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.HoldSeat("2A", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ConfirmSeat("2A", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	m := New(f, nil)
+	if m.FlightID != "T100" || m.Aircraft != "T1" || len(m.Rows) != 4 {
+		t.Fatalf("map = %s %s with %d rows", m.FlightID, m.Aircraft, len(m.Rows))
+	}
+
+	seats := make(map[string]Seat)
+	for _, row := range m.Rows {
+		for _, seat := range row.Seats {
+			seats[seat.Number] = seat
+		}
+	}
+	tests := []struct {
+		seat   string
+		status string
+		class  models.SeatClass
+		price  float64
+	}{
+		{"1A", "available", models.ClassBusiness, 3500},
+		{"1C", "held", models.ClassBusiness, 3500},
+		{"2A", "booked", models.ClassEconomy, 1000},
+		{"3D", "available", models.ClassEconomyPlus, 1200},
+		{"4F", "blocked", models.ClassEconomy, 1000},
+	}
+	for _, tt := range tests {
+		seat := seats[tt.seat]
+		if seat.Status != tt.status || seat.Class != tt.class || seat.Price != tt.price {
+			t.Errorf("seat %s = %s %s %v, want %s %s %v", tt.seat,
+				seat.Status, seat.Class, seat.Price, tt.status, tt.class, tt.price)
+		}
+	}
+	if row := m.Rows[2]; !row.Exit || row.Cabin != models.Economy || row.Number != 3 {
+		t.Errorf("row 3 = %+v, want an economy exit row", row)
+	}
+	if seat := seats["1C"]; !seat.Aisle || seat.Window {
+		t.Errorf("seat 1C = %+v, want an aisle seat", seat)
+	}
+
+	priced := New(f, func(seat models.SeatSpec) float64 { return float64(seat.Row) })
+	if got := priced.Rows[3].Seats[0].Price; got != 4 {
+		t.Errorf("seat 4A priced %v, want 4", got)
+	}
+}
+
+func TestWriteASCII(t *testing.T) {
+	f := testFlight()
+	if _, err := f.HoldSeat("1C", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.HoldSeat("2A", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ConfirmSeat("2A", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := New(f, nil).WriteASCII(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `T100  T1
+
+business  AC DF
+       1  .h ..
+
+economy   ABC DEF
+       2  X.. ...
+       3  +++ +++  exit
+       4  ... ..#
+
+` + Legend + "\n"
+	if b.String() != want {
+		t.Errorf("WriteASCII =\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flight-booking/models"
+	"flight-booking/repository"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrBookingNotFound = errors.New("booking not found")
+
+// BookingStore keeps bookings by ID and saves every change to its
+// repository before making it visible
+type BookingStore struct {
+	repo repository.BookingRepository
+
+	mu       sync.Mutex
+	bookings map[string]*models.Booking
+}
+
+// NewBookingStore loads the bookings of repo for flights. Bookings whose
+// payment was interrupted by a restart are settled from their seat: the
+// seat is the record of a confirmed ticket.
+func NewBookingStore(repo repository.BookingRepository, flights []*models.Flight) (*BookingStore, error) {
+	s := &BookingStore{
+		repo:     repo,
+		bookings: make(map[string]*models.Booking),
+	}
+	byID := make(map[string]*models.Flight, len(flights))
+	for _, flight := range flights {
+		byID[flight.ID] = flight
+	}
+
+	records, err := repo.Bookings()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		flight, ok := byID[record.FlightID]
+		if !ok {
+			return nil, fmt.Errorf("booking %s: unknown flight %s", record.ID, record.FlightID)
+		}
+		booking := &models.Booking{
+			ID:            record.ID,
+			UserName:      record.UserName,
+			Flight:        flight,
+			SeatNo:        record.SeatNo,
+			Status:        record.Status,
+			Amount:        record.Amount,
+			PaymentMethod: record.PaymentMethod,
+			HoldExpiry:    record.HoldExpiry,
+			CreatedAt:     record.CreatedAt,
+			Quote:         record.Quote,
+		}
+		if booking.Status == models.BookingPaying {
+			settle(booking)
+			if err := repo.SaveBooking(repository.NewBookingRecord(*booking)); err != nil {
+				return nil, err
+			}
+		}
+		s.bookings[booking.ID] = booking
+	}
+	return s, nil
+}
+
+// settle decides a booking whose payment outcome was lost
+func settle(booking *models.Booking) {
+	for _, seat := range booking.Flight.SeatMap() {
+		if seat.Number != booking.SeatNo || seat.LockedBy != booking.Holder() {
+			continue
+		}
+		switch seat.Status {
+		case models.Booked:
+			booking.Status = models.BookingConfirmed
+			return
+		case models.Held:
+			booking.Status = models.BookingHeld
+			booking.HoldExpiry = seat.LockExpiry
+			return
+		}
+	}
+	booking.Status = models.BookingExpired
+}
+
+// NewID returns an unused booking ID, for a booking whose seat must be
+// held before it is created
+func (s *BookingStore) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newIDLocked()
+}
+
+func (s *BookingStore) newIDLocked() string {
+	for {
+		id := newBookingID()
+		if _, taken := s.bookings[id]; !taken {
+			return id
+		}
+	}
+}
+
+// Create stores booking, assigning it an ID unless it has one from NewID
+func (s *BookingStore) Create(booking models.Booking) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if booking.ID == "" {
+		booking.ID = s.newIDLocked()
+	} else if _, taken := s.bookings[booking.ID]; taken {
+		return models.Booking{}, fmt.Errorf("booking %s already exists", booking.ID)
+	}
+	if booking.CreatedAt.IsZero() {
+		booking.CreatedAt = time.Now()
+	}
+	if err := s.repo.SaveBooking(repository.NewBookingRecord(booking)); err != nil {
+		return models.Booking{}, fmt.Errorf("saving booking: %w", err)
+	}
+	s.bookings[booking.ID] = &booking
+	return booking, nil
+}
+
+// Get returns a copy of a booking. Held bookings whose hold lapsed are
+// reported as expired.
+func (s *BookingStore) Get(id string) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[id]
+	if !ok {
+		return models.Booking{}, ErrBookingNotFound
+	}
+	expire(booking, time.Now())
+	return *booking, nil
+}
+
+// Update applies fn to a booking under the store's lock; the booking is
+// only changed if fn returns nil
+func (s *BookingStore) Update(id string, fn func(booking *models.Booking) error) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[id]
+	if !ok {
+		return models.Booking{}, ErrBookingNotFound
+	}
+	expire(booking, time.Now())
+	updated := *booking
+	if err := fn(&updated); err != nil {
+		return *booking, err
+	}
+	if updated != *booking {
+		if err := s.repo.SaveBooking(repository.NewBookingRecord(updated)); err != nil {
+			return *booking, fmt.Errorf("saving booking: %w", err)
+		}
+	}
+	*booking = updated
+	return updated, nil
+}
+
+func expire(booking *models.Booking, now time.Time) {
+	if booking.Status == models.BookingHeld && !now.Before(booking.HoldExpiry) {
+		booking.Status = models.BookingExpired
+	}
+}
+
+func newBookingID() string {
+	var b [5]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("no randomness: " + err.Error())
+	}
+	return "BK" + hex.EncodeToString(b[:])
+}
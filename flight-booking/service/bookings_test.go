@@ -0,0 +1,165 @@
+package service_test
+
+import (
+	"errors"
+	"flight-booking/models"
+	"flight-booking/repository"
+	"flight-booking/service"
+	"testing"
+	"time"
+)
+
+func testFlight() *models.Flight {
+	return models.NewFlight("T100", "Test Air", 1000, []models.SeatSpec{
+		{Number: "1A", Cabin: models.Economy, Row: 1, Column: "A"},
+		{Number: "1B", Cabin: models.Economy, Row: 1, Column: "B"},
+	})
+}
+
+var errDiskFull = errors.New("disk full")
+
+// failingBookings is a repository whose booking saves fail while fail is set
+type failingBookings struct {
+	*repository.Memory
+	fail bool
+}
+
+func (r *failingBookings) SaveBooking(booking repository.BookingRecord) error {
+	if r.fail {
+		return errDiskFull
+	}
+	return r.Memory.SaveBooking(booking)
+}
+
+func newStore(t *testing.T) (*service.BookingStore, *failingBookings, *models.Flight) {
+	t.Helper()
+	repo := &failingBookings{Memory: repository.NewMemory()}
+	flight := testFlight()
+	store, err := service.NewBookingStore(repo, []*models.Flight{flight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, repo, flight
+}
+
+func TestBookingStoreCreate(t *testing.T) {
+	store, repo, flight := newStore(t)
+
+	id := store.NewID()
+	created, err := store.Create(models.Booking{ID: id, UserName: "alice", Flight: flight, SeatNo: "1A", Status: models.BookingHeld})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != id || created.CreatedAt.IsZero() {
+		t.Fatalf("created = %+v, want ID %s and a creation time", created, id)
+	}
+	if record, err := repo.Booking(id); err != nil || record.UserName != "alice" {
+		t.Fatalf("stored booking = %+v, %v", record, err)
+	}
+	if _, err := store.Create(models.Booking{ID: id, UserName: "bob", Flight: flight, SeatNo: "1B"}); err == nil {
+		t.Fatal("Create reused a taken ID")
+	}
+
+	generated, err := store.Create(models.Booking{UserName: "bob", Flight: flight, SeatNo: "1B"})
+	if err != nil || generated.ID == "" || generated.ID == id {
+		t.Fatalf("Create without an ID = %q, %v", generated.ID, err)
+	}
+
+	// A booking that cannot be saved is not kept
+	repo.fail = true
+	failed := store.NewID()
+	if _, err := store.Create(models.Booking{ID: failed, UserName: "carol", Flight: flight, SeatNo: "1B"}); !errors.Is(err, errDiskFull) {
+		t.Fatalf("Create with a failing repository: err = %v", err)
+	}
+	if _, err := store.Get(failed); !errors.Is(err, service.ErrBookingNotFound) {
+		t.Fatalf("Get of an unsaved booking: err = %v", err)
+	}
+}
+
+func TestBookingStoreUpdate(t *testing.T) {
+	errRefused := errors.New("refused")
+	tests := []struct {
+		name string
+		fn   func(b *models.Booking) error
+		fail bool
+		// want is the status after the update, which is unchanged on error
+		want    models.BookingStatus
+		wantErr error
+	}{
+		{"applied", func(b *models.Booking) error {
+			b.Status = models.BookingConfirmed
+			return nil
+		}, false, models.BookingConfirmed, nil},
+		{"refused by fn", func(b *models.Booking) error {
+			b.Status = models.BookingConfirmed
+			return errRefused
+		}, false, models.BookingHeld, errRefused},
+		{"not saved", func(b *models.Booking) error {
+			b.Status = models.BookingConfirmed
+			return nil
+		}, true, models.BookingHeld, errDiskFull},
+		{"unchanged needs no save", func(b *models.Booking) error { return nil }, true, models.BookingHeld, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, repo, flight := newStore(t)
+			booking, err := store.Create(models.Booking{
+				UserName: "alice", Flight: flight, SeatNo: "1A",
+				Status: models.BookingHeld, HoldExpiry: time.Now().Add(time.Minute),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo.fail = tt.fail
+			updated, err := store.Update(booking.ID, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update: err = %v, want %v", err, tt.wantErr)
+			}
+			if updated.Status != tt.want {
+				t.Errorf("Update returned %s, want %s", updated.Status, tt.want)
+			}
+			if got, _ := store.Get(booking.ID); got.Status != tt.want {
+				t.Errorf("stored booking is %s, want %s", got.Status, tt.want)
+			}
+		})
+	}
+
+	store, _, _ := newStore(t)
+	if _, err := store.Update("BK0", func(b *models.Booking) error { return nil }); !errors.Is(err, service.ErrBookingNotFound) {
+		t.Errorf("Update of an unknown booking: err = %v", err)
+	}
+}
+
+func TestBookingStoreExpiresHolds(t *testing.T) {
+	store, _, flight := newStore(t)
+	booking, err := store.Create(models.Booking{
+		UserName: "alice", Flight: flight, SeatNo: "1A",
+		Status: models.BookingHeld, HoldExpiry: time.Now().Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get(booking.ID); got.Status != models.BookingExpired {
+		t.Fatalf("lapsed hold is %s, want expired", got.Status)
+	}
+	// Update sees the expired booking too
+	if _, err := store.Update(booking.ID, func(b *models.Booking) error {
+		if b.Status != models.BookingExpired {
+			t.Errorf("Update saw %s, want expired", b.Status)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewBookingStoreUnknownFlight(t *testing.T) {
+	repo := repository.NewMemory()
+	if err := repo.SaveBooking(repository.BookingRecord{ID: "BK1", FlightID: "X999", SeatNo: "1A", Status: models.BookingConfirmed}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.NewBookingStore(repo, []*models.Flight{testFlight()}); err == nil {
+		t.Fatal("NewBookingStore loaded a booking of an unknown flight")
+	}
+}
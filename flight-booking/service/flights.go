@@ -0,0 +1,50 @@
+package service
+
+import (
+	"flight-booking/models"
+	"flight-booking/repository"
+	"fmt"
+)
+
+// LoadFlights restores the flights of repo with their seat states, or
+// saves the flights made by defaults if the repository has none. Seat
+// transitions of the returned flights are journaled to repo.
+func LoadFlights(repo repository.Repository, defaults func() []*models.Flight) ([]*models.Flight, error) {
+	records, err := repo.Flights()
+	if err != nil {
+		return nil, err
+	}
+
+	var flights []*models.Flight
+	if len(records) == 0 {
+		flights = defaults()
+		for _, flight := range flights {
+			if err := repo.SaveFlight(repository.NewFlightRecord(flight)); err != nil {
+				return nil, fmt.Errorf("saving flight %s: %w", flight.ID, err)
+			}
+		}
+	} else {
+		for _, record := range records {
+			flight := models.NewFlight(record.ID, record.Name, record.Price, record.Seats)
+			flight.Fares = record.Fares
+			flight.Origin, flight.Destination = record.Origin, record.Destination
+			flight.Departure, flight.Arrival = record.Departure, record.Arrival
+			flight.Aircraft = record.Aircraft
+			seats, err := repo.Seats(record.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, seat := range seats {
+				if err := flight.RestoreSeat(seat); err != nil {
+					return nil, err
+				}
+			}
+			flights = append(flights, flight)
+		}
+	}
+
+	for _, flight := range flights {
+		flight.SetJournal(repo)
+	}
+	return flights, nil
+}
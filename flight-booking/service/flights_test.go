@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"flight-booking/models"
+	"flight-booking/repository"
+	"flight-booking/service"
+	"testing"
+	"time"
+)
+
+func TestLoadFlights(t *testing.T) {
+	repo := repository.NewMemory()
+	departure := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+	defaults := func() []*models.Flight {
+		f := testFlight()
+		f.Origin, f.Destination, f.Departure, f.Aircraft = "DEL", "BOM", departure, "A320"
+		f.Fares = map[models.SeatClass]float64{models.ClassEconomy: 1000, models.ClassBusiness: 3500}
+		return []*models.Flight{f}
+	}
+
+	flights, err := service.LoadFlights(repo, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := repo.Flights(); len(records) != 1 || records[0].ID != "T100" {
+		t.Fatalf("saved flights = %+v, want the default T100", records)
+	}
+	// Seat transitions of loaded flights are journaled
+	if _, err := flights[0].HoldSeat("1A", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if seats, _ := repo.Seats("T100"); len(seats) != 1 || seats[0].LockedBy != "alice" {
+		t.Fatalf("journaled seats = %+v, want 1A held by alice", seats)
+	}
+
+	restored, err := service.LoadFlights(repo, func() []*models.Flight {
+		t.Fatal("defaults made for a repository that has flights")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := restored[0]
+	if f.Origin != "DEL" || f.Destination != "BOM" || !f.Departure.Equal(departure) || f.Aircraft != "A320" {
+		t.Errorf("restored route = %s-%s at %v on %s", f.Origin, f.Destination, f.Departure, f.Aircraft)
+	}
+	if f.Fare(models.ClassBusiness) != 3500 {
+		t.Errorf("restored business fare = %v, want 3500", f.Fare(models.ClassBusiness))
+	}
+	if status, _ := f.SeatStatus("1A"); status != models.Held {
+		t.Errorf("restored seat 1A is %s, want held", status)
+	}
+	if err := f.ConfirmSeat("1A", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if seats, _ := repo.Seats("T100"); seats[0].Status != models.Booked {
+		t.Errorf("journaled seat after restore = %+v, want booked", seats[0])
+	}
+}
+
+func TestLoadFlightsUnknownSeat(t *testing.T) {
+	repo := repository.NewMemory()
+	if err := repo.SaveFlight(repository.NewFlightRecord(testFlight())); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveSeat("T100", models.SeatState{Number: "9Z", Status: models.Booked}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.LoadFlights(repo, nil); err == nil {
+		t.Fatal("LoadFlights restored a seat the flight does not have")
+	}
+}
@@ -11,6 +11,8 @@ type Metrics struct {
 	Failed        int64
 	Timeout       int64
 	TotalLatency  int64 // nanoseconds
+	// RefundsDue counts payments taken for seats that were not confirmed
+	RefundsDue int64
 }
 
 var GlobalMetrics = &Metrics{}
@@ -30,6 +32,11 @@ func (m *Metrics) Record(duration time.Duration, status string) {
 	}
 }
 
+// RecordRefundDue counts a payment that must be refunded
+func (m *Metrics) RecordRefundDue() {
+	atomic.AddInt64(&m.RefundsDue, 1)
+}
+
 // Latency = how long one request takes to complete.
 func (m *Metrics) AverageLatency() time.Duration {
 	total := atomic.LoadInt64(&m.TotalRequests)
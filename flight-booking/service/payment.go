@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"flight-booking/models"
+	"flight-booking/strategy"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PaymentTimeout is how long a booking waits for the payment provider
+const PaymentTimeout = 8 * time.Second
+
+var (
+	ErrPaymentFailed  = errors.New("payment failed")
+	ErrPaymentTimeout = errors.New("payment timeout")
+	// ErrRefundDue means a payment was taken for a seat that could not
+	// be confirmed afterwards
+	ErrRefundDue = errors.New("payment taken but seat not confirmed, refund due")
+)
+
+// Charge runs a payment and waits for it at most timeout. A payment that
+// succeeds after the timeout is recorded as due for refund.
+func Charge(payment strategy.PaymentStrategy, amount float64, timeout time.Duration) error {
+	// buffered so the payment goroutine can finish after a timeout
+	paymentDone := make(chan bool, 1)
+
+	go func() {
+		paymentDone <- payment.Pay(amount)
+	}()
+
+	select {
+	case success := <-paymentDone:
+		if !success {
+			return ErrPaymentFailed
+		}
+		return nil
+	case <-time.After(timeout):
+		go func() {
+			if <-paymentDone {
+				GlobalMetrics.RecordRefundDue()
+				log.Printf("Payment of %.2f succeeded after timing out; refund due", amount)
+			}
+		}()
+		return ErrPaymentTimeout
+	}
+}
+
+// ConfirmPaid confirms a seat that holder has just paid amount for. If
+// the seat cannot be confirmed, e.g. because the hold lapsed, the payment
+// is recorded as due for refund and the error wraps ErrRefundDue.
+func ConfirmPaid(flight *models.Flight, seatNo, holder string, amount float64) error {
+	err := flight.ConfirmSeat(seatNo, holder)
+	if err == nil {
+		return nil
+	}
+	GlobalMetrics.RecordRefundDue()
+	log.Printf("Payment of %.2f for seat %s on %s by %s taken but not confirmed (%v); refund due",
+		amount, seatNo, flight.ID, holder, err)
+	return fmt.Errorf("%w: %w", ErrRefundDue, err)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"flight-booking/models"
+	"log"
+	"time"
+)
+
+// HoldReapInterval is how often StartHoldReaper looks for lapsed holds
+const HoldReapInterval = 5 * time.Second
+
+// StartHoldReaper returns seats whose hold expired to Available every
+// interval, so a crash between holding and confirming cannot block a
+// seat for good. One reaper walks all flights on a single ticker. Call
+// the returned function to stop it.
+func StartHoldReaper(flights []*models.Flight, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				for _, flight := range flights {
+					if released := flight.ReleaseExpired(now); len(released) > 0 {
+						log.Printf("Released %d expired hold(s) on flight %s: %v", len(released), flight.ID, released)
+					}
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
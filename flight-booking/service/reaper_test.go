@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"flight-booking/models"
+	"flight-booking/service"
+	"testing"
+	"time"
+)
+
+// holdAll holds seat 1A of every flight for ttl
+func holdAll(t *testing.T, flights []*models.Flight, ttl time.Duration) {
+	t.Helper()
+	for _, f := range flights {
+		f.HoldTTL = ttl
+		if _, err := f.HoldSeat("1A", "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHoldReaper(t *testing.T) {
+	var flights []*models.Flight
+	for _, id := range []string{"T100", "T200", "T300"} {
+		f := testFlight()
+		f.ID = id
+		flights = append(flights, f)
+	}
+	if _, err := flights[1].HoldSeat("1B", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	holdAll(t, flights, 20*time.Millisecond)
+
+	// One reaper releases the lapsed holds of every flight, so none are
+	// left for ReleaseExpired to find
+	stop := service.StartHoldReaper(flights, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	for _, f := range flights {
+		if left := f.ReleaseExpired(time.Now()); left != nil {
+			t.Errorf("reaper left lapsed holds %v on %s", left, f.ID)
+		}
+	}
+	if status, _ := flights[1].SeatStatus("1B"); status != models.Held {
+		t.Errorf("live hold on T200 1B is %s, want held", status)
+	}
+
+	// A stopped reaper leaves them
+	stop()
+	holdAll(t, flights, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	for _, f := range flights {
+		if left := f.ReleaseExpired(time.Now()); len(left) != 1 || left[0] != "1A" {
+			t.Errorf("lapsed holds on %s after stop = %v, want [1A]", f.ID, left)
+		}
+	}
+}
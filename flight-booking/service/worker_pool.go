@@ -11,6 +11,23 @@ import (
 type BookingRequest struct {
 	Booking models.Booking
 	Payment strategy.PaymentStrategy
+	// Held means the seat is already held by Booking.Holder(), so the
+	// worker only pays and confirms, and keeps the hold if payment fails
+	Held bool
+	// Result, if set, receives the outcome of the request
+	Result chan<- BookingResult
+}
+
+// BookingResult is the outcome of a booking request
+type BookingResult struct {
+	// Status is "success", "failed" or "timeout", as recorded in metrics
+	Status string
+	// Err says why the booking failed: a models seat error,
+	// ErrPaymentFailed, ErrPaymentTimeout, or ErrRefundDue wrapping the
+	// seat error when the seat was lost after paying
+	Err error
+	// HoldExpiry is when the kept hold of a Held request lapses
+	HoldExpiry time.Time
 }
 
 func StartWorkerPool(requests chan BookingRequest, workerCount int, wg *sync.WaitGroup) {
@@ -26,67 +43,63 @@ func worker(id int, requests chan BookingRequest, wg *sync.WaitGroup) {
 
 	for req := range requests {
 		start := time.Now() // ⏱️ START TIMER
-		status := ""
 		fmt.Println("worker", id, "processing booking for", req.Booking.UserName)
-		seatNo := req.Booking.SeatNo
-
-		// 1️⃣ Hold Seat
-		if !req.Booking.Flight.HoldSeat(seatNo) {
 
-			status = "failed"
-			duration := time.Since(start)
-			GlobalMetrics.Record(duration, status)
+		result := process(req)
 
-			fmt.Println("seat not available for", req.Booking.UserName)
-			continue
+		duration := time.Since(start)
+		// 📊 Record metrics
+		GlobalMetrics.Record(duration, result.Status)
+		if req.Result != nil {
+			req.Result <- result
 		}
+	}
 
-		// 2️⃣ Create channel for payment result
-		paymentDone := make(chan bool)
-
-		go func() {
-			success := req.Payment.Pay(req.Booking.Flight.Price)
-			paymentDone <- success
-		}()
-
-		// 3️⃣ Wait for payment OR timeout
-		select {
-		// after succesful payment the seat will confirm, otherwise failed
-		case success := <-paymentDone:
-			if success {
-				status = "success"
-				req.Booking.Flight.ConfirmSeat(seatNo)
-				fmt.Println("Ticket confirmed for", req.Booking.UserName)
-			} else {
-				status = "failed"
-				req.Booking.Flight.ReleaseSeat(seatNo)
-				fmt.Println("Payment failed for", req.Booking.UserName)
-			}
-			// it start counting immediately when the select statement executed.
-
-			// So timeline:
-
-			// select starts running
-
-			// Two things are now being waited on:
-
-			// paymentDone channel
-			// 8-second timer
-
-			// Whichever happens first wins:
-
-			// Payment finishes → first case runs
-			// 8 seconds pass → timeout case runs
+}
 
-		case <-time.After(8 * time.Second):
+// process holds (unless already held), pays for and confirms one seat
+func process(req BookingRequest) BookingResult {
+	flight := req.Booking.Flight
+	seatNo := req.Booking.SeatNo
+	holder := req.Booking.Holder()
+
+	// 1️⃣ Hold Seat, or make sure the existing hold outlasts the payment
+	var err error
+	var expiry time.Time
+	if req.Held {
+		expiry, err = flight.ExtendHold(seatNo, holder)
+	} else {
+		_, err = flight.HoldSeat(seatNo, holder)
+	}
+	if err != nil {
+		fmt.Println("seat not available for", req.Booking.UserName)
+		return BookingResult{Status: "failed", Err: err}
+	}
 
-			req.Booking.Flight.ReleaseSeat(seatNo)
-			status = "timeout"
+	// 2️⃣ Pay, waiting at most PaymentTimeout
+	// after succesful payment the seat will confirm, otherwise failed
+	amount := req.Booking.Amount
+	if amount == 0 {
+		amount, _ = flight.SeatPrice(seatNo)
+	}
+	if err := Charge(req.Payment, amount, PaymentTimeout); err != nil {
+		if !req.Held {
+			flight.ReleaseSeat(seatNo, holder)
+		}
+		if err == ErrPaymentTimeout {
 			fmt.Println("Payment timeout! Booking failed for", req.Booking.UserName)
+			return BookingResult{Status: "timeout", Err: err, HoldExpiry: expiry}
 		}
-		duration := time.Since(start)
-		// 📊 Record metrics
-		GlobalMetrics.Record(duration, status)
+		fmt.Println("Payment failed for", req.Booking.UserName)
+		return BookingResult{Status: "failed", Err: err, HoldExpiry: expiry}
 	}
 
+	// 3️⃣ Confirm
+	if err := ConfirmPaid(flight, seatNo, holder, amount); err != nil {
+		// the hold lapsed while paying
+		fmt.Println("Could not confirm seat for", req.Booking.UserName+":", err)
+		return BookingResult{Status: "failed", Err: err}
+	}
+	fmt.Println("Ticket confirmed for", req.Booking.UserName)
+	return BookingResult{Status: "success"}
 }
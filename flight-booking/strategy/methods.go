@@ -0,0 +1,20 @@
+package strategy
+
+import "strings"
+
+// ByName returns the payment strategy for a method name such as "upi"
+// or "card"
+func ByName(method string) (PaymentStrategy, bool) {
+	switch strings.ToLower(strings.TrimSpace(method)) {
+	case "upi":
+		return &UPI{}, true
+	case "card":
+		return &Card{}, true
+	}
+	return nil, false
+}
+
+// Methods lists the names accepted by ByName
+func Methods() []string {
+	return []string{"upi", "card"}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"tcp-adapter/pkg/client"
+	"tcp-adapter/pkg/filetransfer"
+	"tcp-adapter/pkg/protocol"
+)
+
+// send delivers msg to the server, running client-side commands such as
+// UPLOAD and DOWNLOAD locally
+func send(c *client.Client, msg *protocol.Message) (*protocol.Message, error) {
+	switch msg.Command {
+	case "UPLOAD":
+		return upload(c, msg.Payload)
+	case "DOWNLOAD":
+		return download(c, msg.Payload)
+	}
+	return c.Send(msg)
+}
+
+// upload handles "UPLOAD <local> [remote]"
+func upload(c *client.Client, payload string) (*protocol.Message, error) {
+	args := strings.Fields(payload)
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errors.New("usage: UPLOAD <local-file> [remote-name]")
+	}
+	remote := filepath.Base(args[0])
+	if len(args) == 2 {
+		remote = args[1]
+	}
+
+	size, digest, err := filetransfer.Upload(c, args[0], remote, filetransfer.DefaultChunkSize)
+	if err != nil {
+		return transferResult(err)
+	}
+	return protocol.NewMessage("UPLOAD_OK", fmt.Sprintf("%s %d %s", remote, size, digest)), nil
+}
+
+// download handles "DOWNLOAD <remote> [local]"
+func download(c *client.Client, payload string) (*protocol.Message, error) {
+	args := strings.Fields(payload)
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errors.New("usage: DOWNLOAD <remote-name> [local-file]")
+	}
+	local := filepath.Base(args[0])
+	if len(args) == 2 {
+		local = args[1]
+	}
+
+	size, digest, err := filetransfer.Download(c, args[0], local)
+	if err != nil {
+		return transferResult(err)
+	}
+	return protocol.NewMessage("DOWNLOAD_OK", fmt.Sprintf("%s %d %s", local, size, digest)), nil
+}
+
+// transferResult reports server errors as error frames so scripts and
+// JSON output treat them like any other failed command
+func transferResult(err error) (*protocol.Message, error) {
+	var protoErr *protocol.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.ToMessage(), nil
+	}
+	return nil, err
+}
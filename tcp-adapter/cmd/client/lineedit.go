@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is the number of lines kept in the history file
+const maxHistory = 500
+
+// errInterrupted is returned when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal with cursor movement and
+// history navigation (up/down arrows), persisting history across runs
+type lineEditor struct {
+	in          *os.File
+	reader      *bufio.Reader
+	history     []string
+	historyFile string
+}
+
+// newLineEditor creates an editor reading from stdin and loads the history file
+func newLineEditor(historyFile string) *lineEditor {
+	e := &lineEditor{
+		in:          os.Stdin,
+		reader:      bufio.NewReader(os.Stdin),
+		historyFile: historyFile,
+	}
+	e.loadHistory()
+	return e
+}
+
+// defaultHistoryFile returns ~/.tcp_adapter_history, or "" if there is no home
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".tcp_adapter_history")
+}
+
+// ReadLine shows prompt and returns the line entered by the user.
+// If the terminal cannot be put in raw mode it reads a plain line instead.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	fd := int(e.in.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		line, err := e.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restoreTerminal(fd, state)
+
+	line, err := e.edit(prompt)
+	fmt.Print("\r\n")
+	return line, err
+}
+
+// edit runs the key handling loop in raw mode
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	cursor := 0
+	// histIndex == len(history) means the line being typed
+	histIndex := len(e.history)
+	draft := ""
+
+	redraw := func() {
+		fmt.Printf("\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - cursor; back > 0 {
+			fmt.Printf("\x1b[%dD", back)
+		}
+	}
+	setLine := func(s string) {
+		buf = []rune(s)
+		cursor = len(buf)
+		redraw()
+	}
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			return string(buf), nil
+		case 3: // Ctrl-C
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+			redraw()
+		case 5: // Ctrl-E
+			cursor = len(buf)
+			redraw()
+		case 21: // Ctrl-U
+			buf = buf[cursor:]
+			cursor = 0
+			redraw()
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				buf = append(buf[:cursor-1], buf[cursor:]...)
+				cursor--
+				redraw()
+			}
+		case 27: // Escape sequence
+			key := e.readEscape()
+			switch key {
+			case "A": // Up
+				if histIndex > 0 {
+					if histIndex == len(e.history) {
+						draft = string(buf)
+					}
+					histIndex--
+					setLine(e.history[histIndex])
+				}
+			case "B": // Down
+				if histIndex < len(e.history) {
+					histIndex++
+					if histIndex == len(e.history) {
+						setLine(draft)
+					} else {
+						setLine(e.history[histIndex])
+					}
+				}
+			case "C": // Right
+				if cursor < len(buf) {
+					cursor++
+					redraw()
+				}
+			case "D": // Left
+				if cursor > 0 {
+					cursor--
+					redraw()
+				}
+			case "H", "1~": // Home
+				cursor = 0
+				redraw()
+			case "F", "4~": // End
+				cursor = len(buf)
+				redraw()
+			case "3~": // Delete
+				if cursor < len(buf) {
+					buf = append(buf[:cursor], buf[cursor+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if r >= 32 {
+				buf = append(buf[:cursor], append([]rune{r}, buf[cursor:]...)...)
+				cursor++
+				redraw()
+			}
+		}
+	}
+}
+
+// readEscape reads the rest of an ANSI escape sequence after ESC and
+// returns its final part, e.g. "A" for ESC [ A or "3~" for ESC [ 3 ~
+func (e *lineEditor) readEscape() string {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+	var seq []rune
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if (r >= 'A' && r <= 'Z') || r == '~' {
+			return string(seq)
+		}
+		if len(seq) > 4 {
+			return ""
+		}
+	}
+}
+
+// AddHistory records line, skipping blanks and immediate repeats
+func (e *lineEditor) AddHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// loadHistory reads previously saved history, ignoring a missing file
+func (e *lineEditor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(e.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		e.AddHistory(strings.TrimSpace(line))
+	}
+}
+
+// SaveHistory writes the history file
+func (e *lineEditor) SaveHistory() error {
+	if e.historyFile == "" {
+		return nil
+	}
+	data := strings.Join(e.history, "\n") + "\n"
+	return os.WriteFile(e.historyFile, []byte(data), 0600)
+}
@@ -1,45 +1,128 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net"
 	"os"
 	"strings"
+	"tcp-adapter/pkg/client"
 	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/signing"
+	"time"
+)
+
+// Exit codes for non-interactive modes
+const (
+	exitOK      = 0 // every command succeeded
+	exitFailure = 1 // error response or failed expectation
+	exitError   = 2 // usage, connection or I/O error
 )
 
 func main() {
+	addr := flag.String("addr", "localhost:8080", "server address")
+	command := flag.String("c", "", `run a single command and exit, e.g. -c "ECHO hello"`)
+	script := flag.String("script", "", "run commands from a file ('-' for stdin), checking '=>' and '=~' expectations")
+	jsonOutput := flag.Bool("json", false, "print responses as JSON lines")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout in non-interactive modes")
+	signKeys := flag.String("sign-keys", "", "key file to sign requests from (see -sign-key)")
+	signKey := flag.String("sign-key", "", "ID of the key in -sign-keys that signs every request")
+	flag.Parse()
+
+	// Commands piped on stdin run like a script instead of the REPL
+	interactive := *command == "" && *script == "" && isTerminal(os.Stdin)
+
 	// Connect to TCP server
-	conn, err := net.Dial("tcp", "localhost:8080")
+	c, err := client.DialTimeout(*addr, *timeout)
 	if err != nil {
-		log.Fatalf("Failed to connect to server: %v", err)
+		if interactive {
+			log.Fatalf("Failed to connect to server: %v", err)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	defer c.Close()
+
+	if *signKey != "" {
+		secret, err := loadSigningKey(*signKeys, *signKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		c.SetSigner(*signKey, secret)
 	}
-	defer conn.Close()
 
-	log.Println("Connected to server at localhost:8080")
+	out := newPrinter(*jsonOutput)
 
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
-	stdinReader := bufio.NewReader(os.Stdin)
+	switch {
+	case *command != "":
+		c.SetTimeout(*timeout)
+		os.Exit(runSingle(c, *command, out))
+	case *script != "":
+		c.SetTimeout(*timeout)
+		os.Exit(runScriptFile(c, *script, out))
+	case !interactive:
+		c.SetTimeout(*timeout)
+		os.Exit(runScript(c, os.Stdin, "stdin", out))
+	default:
+		log.Printf("Connected to server at %s", *addr)
+		runInteractive(c, out)
+	}
+}
 
-	// Read welcome message
-	welcomeMsg, err := protocol.Decode(reader)
+// loadSigningKey returns the secret of keyID from the key file at path
+func loadSigningKey(path, keyID string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("-sign-key needs -sign-keys")
+	}
+	keys, err := signing.LoadKeys(path)
 	if err != nil {
-		log.Fatalf("Error reading welcome message: %v", err)
+		return nil, err
 	}
-	fmt.Printf("Server: [%s] %s\n\n", welcomeMsg.Command, welcomeMsg.Payload)
+	secret, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in %s", keyID, path)
+	}
+	return secret, nil
+}
+
+// runSingle sends one command and returns the exit code
+func runSingle(c *client.Client, input string, out *printer) int {
+	request := parseInput(input)
+	response, err := send(c, request)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	out.Print(request, response)
+	if isError(response) {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runInteractive is the REPL with line editing and persistent history
+func runInteractive(c *client.Client, out *printer) {
+	fmt.Printf("Server: [%s] %s\n\n", c.Welcome.Command, c.Welcome.Payload)
+
+	// Pub/sub messages are shown as they arrive with responses
+	c.OnMessage(printResponse)
 
 	// Display available commands
 	printHelp()
 
+	editor := newLineEditor(defaultHistoryFile())
+	defer editor.SaveHistory()
+
 	// Main client loop
 	for {
-		fmt.Print("> ")
-		input, err := stdinReader.ReadString('\n')
+		input, err := editor.ReadLine("> ")
 		if err != nil {
-			log.Printf("Error reading input: %v", err)
+			if !errors.Is(err, io.EOF) && !errors.Is(err, errInterrupted) {
+				log.Printf("Error reading input: %v", err)
+			}
 			break
 		}
 
@@ -47,47 +130,53 @@ func main() {
 		if input == "" {
 			continue
 		}
+		editor.AddHistory(input)
 
-		// Parse command and payload
-		parts := strings.SplitN(input, " ", 2)
-		command := strings.ToUpper(parts[0])
-		payload := ""
-		if len(parts) > 1 {
-			payload = parts[1]
-		}
+		msg := parseInput(input)
 
 		// Special handling for local commands
-		if command == "HELP" {
+		if msg.Command == "HELP" {
 			printHelp()
 			continue
 		}
 
-		// Create and send message
-		msg := protocol.NewMessage(command, payload)
-		_, err = writer.WriteString(msg.Encode())
-		if err != nil {
-			log.Printf("Error sending message: %v", err)
-			break
-		}
-		writer.Flush()
-
-		// Read response
-		response, err := protocol.Decode(reader)
+		// Send and read response
+		response, err := send(c, msg)
 		if err != nil {
-			log.Printf("Error reading response: %v", err)
+			log.Printf("%v", err)
 			break
 		}
 
-		fmt.Printf("Server: [%s] %s\n", response.Command, response.Payload)
+		out.Print(msg, response)
 
 		// Exit if QUIT command
-		if command == "QUIT" {
+		if msg.Command == "QUIT" {
 			log.Println("Disconnecting...")
 			break
 		}
 	}
 }
 
+// parseInput turns "COMMAND payload text" into a message
+func parseInput(input string) *protocol.Message {
+	parts := strings.SplitN(input, " ", 2)
+	command := strings.ToUpper(parts[0])
+	payload := ""
+	if len(parts) > 1 {
+		payload = parts[1]
+	}
+	return protocol.NewMessage(command, payload)
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 func printHelp() {
 	fmt.Println("Available commands:")
 	fmt.Println("  ECHO <text>     - Echo back the text")
@@ -95,7 +184,14 @@ func printHelp() {
 	fmt.Println("  LOWER <text>    - Convert text to lowercase")
 	fmt.Println("  REVERSE <text>  - Reverse the text")
 	fmt.Println("  PING            - Check server status")
+	fmt.Println("  UPLOAD <file> [name]   - Upload a file (resumes interrupted uploads)")
+	fmt.Println("  DOWNLOAD <name> [file] - Download a file (resumes interrupted downloads)")
+	fmt.Println("  LIST [dir]      - List files stored on the server")
+	fmt.Println("  SUBSCRIBE <topic>         - Receive messages published to topic")
+	fmt.Println("  PUBLISH <topic>:<message> - Publish a message")
 	fmt.Println("  QUIT            - Disconnect from server")
 	fmt.Println("  HELP            - Show this help message")
 	fmt.Println()
+	fmt.Println("Use the up/down arrows to browse command history.")
+	fmt.Println()
 }
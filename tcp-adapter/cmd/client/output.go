@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"tcp-adapter/pkg/protocol"
+)
+
+// responseRecord is the JSON form of one request/response exchange
+type responseRecord struct {
+	Request string       `json:"request"`
+	Command string       `json:"command"`
+	Payload string       `json:"payload"`
+	Error   *errorRecord `json:"error,omitempty"`
+}
+
+type errorRecord struct {
+	Code      int    `json:"code"`
+	Name      string `json:"name"`
+	Retryable bool   `json:"retryable"`
+	Message   string `json:"message"`
+}
+
+// printer writes responses either for humans or as JSON lines
+type printer struct {
+	json bool
+	enc  *json.Encoder
+}
+
+func newPrinter(jsonOutput bool) *printer {
+	return &printer{
+		json: jsonOutput,
+		enc:  json.NewEncoder(os.Stdout),
+	}
+}
+
+// Print writes the response to request
+func (p *printer) Print(request, response *protocol.Message) {
+	if !p.json {
+		printResponse(response)
+		return
+	}
+
+	record := responseRecord{
+		Request: request.Command + ":" + request.Payload,
+		Command: response.Command,
+		Payload: response.Payload,
+	}
+	if protoErr, ok := protocol.ParseError(response); ok {
+		record.Error = &errorRecord{
+			Code:      int(protoErr.Code),
+			Name:      protoErr.Code.String(),
+			Retryable: protoErr.Retryable,
+			Message:   protoErr.Message,
+		}
+	}
+	p.enc.Encode(record)
+}
+
+// printResponse shows a server response, decoding error frames
+func printResponse(response *protocol.Message) {
+	if protoErr, ok := protocol.ParseError(response); ok {
+		retry := ""
+		if protoErr.Retryable {
+			retry = " (retryable)"
+		}
+		fmt.Printf("Server error %d %s: %s%s\n", protoErr.Code, protoErr.Code, protoErr.Message, retry)
+		return
+	}
+	fmt.Printf("Server: [%s] %s\n", response.Command, response.Payload)
+}
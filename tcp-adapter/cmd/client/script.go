@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"tcp-adapter/pkg/client"
+	"tcp-adapter/pkg/protocol"
+)
+
+// expectation is the response a script line asserts
+type expectation struct {
+	text    string
+	command string
+	payload *string
+	pattern *regexp.Regexp
+}
+
+// parseExpectation splits a script line into the command input and its
+// optional expectation:
+//
+//	ECHO hello => ECHO_RESPONSE:hello   exact command and payload
+//	PING => PONG                        response command only
+//	UPPER abc =~ ^UPPER_RESPONSE:A      regexp against COMMAND:PAYLOAD
+func parseExpectation(line string) (string, *expectation, error) {
+	if idx := strings.LastIndex(line, " =~ "); idx >= 0 {
+		text := strings.TrimSpace(line[idx+4:])
+		pattern, err := regexp.Compile(text)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid pattern %q: %w", text, err)
+		}
+		return strings.TrimSpace(line[:idx]), &expectation{text: "=~ " + text, pattern: pattern}, nil
+	}
+
+	if idx := strings.LastIndex(line, " => "); idx >= 0 {
+		text := strings.TrimSpace(line[idx+4:])
+		exp := &expectation{text: "=> " + text}
+		parts := strings.SplitN(text, ":", 2)
+		exp.command = parts[0]
+		if len(parts) == 2 {
+			exp.payload = &parts[1]
+		}
+		return strings.TrimSpace(line[:idx]), exp, nil
+	}
+
+	return line, nil, nil
+}
+
+// matches reports whether response satisfies the expectation
+func (e *expectation) matches(response *protocol.Message) bool {
+	if e.pattern != nil {
+		return e.pattern.MatchString(response.Command + ":" + response.Payload)
+	}
+	if !strings.EqualFold(e.command, response.Command) {
+		return false
+	}
+	return e.payload == nil || *e.payload == response.Payload
+}
+
+// runScript executes every command read from r and returns the exit code.
+// Blank lines and lines starting with '#' are skipped. A line fails if its
+// expectation does not match, or if it has none and the server answers
+// with an error frame.
+func runScript(c *client.Client, r io.Reader, name string, out *printer) int {
+	scanner := bufio.NewScanner(r)
+	lineNo, failures := 0, 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		input, exp, err := parseExpectation(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d: %v\n", name, lineNo, err)
+			return exitError
+		}
+
+		request := parseInput(input)
+		response, err := send(c, request)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d: %v\n", name, lineNo, err)
+			return exitError
+		}
+		out.Print(request, response)
+
+		switch {
+		case exp != nil && !exp.matches(response):
+			failures++
+			fmt.Fprintf(os.Stderr, "%s:%d: expected %s, got %s:%s\n",
+				name, lineNo, exp.text, response.Command, response.Payload)
+		case exp == nil && isError(response):
+			failures++
+			fmt.Fprintf(os.Stderr, "%s:%d: %s failed: %s\n", name, lineNo, request.Command, response.Payload)
+		}
+
+		if strings.EqualFold(request.Command, "QUIT") {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitError
+	}
+
+	if failures > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d failure(s)\n", name, failures)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runScriptFile runs the script at path, or stdin for "-"
+func runScriptFile(c *client.Client, path string, out *printer) int {
+	if path == "-" {
+		return runScript(c, os.Stdin, "stdin", out)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening script: %v\n", err)
+		return exitError
+	}
+	defer f.Close()
+	return runScript(c, f, path, out)
+}
+
+// isError reports whether response is an error frame
+func isError(response *protocol.Message) bool {
+	return strings.EqualFold(response.Command, protocol.ErrorCommand)
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminalState is the terminal configuration saved before entering raw mode
+type terminalState struct {
+	termios syscall.Termios
+}
+
+// makeRaw switches the terminal to raw mode so keys arrive one at a time
+// without echo, and returns the previous state for restoreTerminal
+func makeRaw(fd int) (*terminalState, error) {
+	var state terminalState
+	if err := ioctl(fd, syscall.TCGETS, &state.termios); err != nil {
+		return nil, err
+	}
+
+	raw := state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// restoreTerminal puts the terminal back into the saved state
+func restoreTerminal(fd int, state *terminalState) error {
+	return ioctl(fd, syscall.TCSETS, &state.termios)
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
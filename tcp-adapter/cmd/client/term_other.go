@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// terminalState is unused on platforms without raw mode support
+type terminalState struct{}
+
+// makeRaw is not supported here; the line editor falls back to plain input
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
+
+func restoreTerminal(fd int, state *terminalState) error {
+	return nil
+}
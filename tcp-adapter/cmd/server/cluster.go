@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"tcp-adapter/pkg/adapter"
+	"tcp-adapter/pkg/cluster"
+	"tcp-adapter/pkg/proxyproto"
+)
+
+// startCluster joins the adapter's broker to the cluster described by the
+// -cluster-* flags and registers MEMBERS and PRESENCE
+func startCluster(a *adapter.TCPAdapter, id, addr, advertise, peers, secretFile, allow string) (*cluster.Node, error) {
+	config := cluster.Config{
+		NodeID:    id,
+		Addr:      addr,
+		Advertise: advertise,
+	}
+	for _, peer := range strings.Split(peers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			config.Peers = append(config.Peers, peer)
+		}
+	}
+	if secretFile != "" {
+		secret, err := os.ReadFile(secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading secret: %w", err)
+		}
+		config.Secret = bytes.TrimSpace(secret)
+		if len(config.Secret) == 0 {
+			return nil, fmt.Errorf("secret file %s is empty", secretFile)
+		}
+	}
+	if allow != "" {
+		networks, err := proxyproto.ParseCIDRs(allow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed networks: %w", err)
+		}
+		config.AllowedPeers = networks
+	}
+
+	// Bound through the adapter so that upgrades hand it over
+	listener, err := a.Listen("cluster", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	config.Listener = listener
+
+	node, err := cluster.New(config, a.Broker())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := node.Start(); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	cluster.Register(a.Router(), node)
+	return node, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/adapter"
+)
+
+// listenerSpec is one -listener flag before TLS certificates are loaded
+type listenerSpec struct {
+	config adapter.ListenerConfig
+	tls    bool
+}
+
+// listenerFlags collects repeated -listener flags. Each value is a
+// comma-separated list of key=value settings, for example:
+//
+//	name=public,addr=:8443,tls,auth,max-conns=500
+//	name=internal,addr=127.0.0.1:9090,commands=PING|ECHO|UPPER
+type listenerFlags []listenerSpec
+
+func (f *listenerFlags) String() string {
+	names := make([]string, 0, len(*f))
+	for _, spec := range *f {
+		names = append(names, spec.config.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *listenerFlags) Set(value string) error {
+	var spec listenerSpec
+	for _, setting := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		switch key {
+		case "name":
+			spec.config.Name = val
+		case "addr":
+			spec.config.Address = val
+		case "tls":
+			spec.tls = val == "" || val == "true"
+		case "auth":
+			spec.config.RequireAuth = val == "" || val == "true"
+		case "commands":
+			spec.config.Commands = strings.Split(val, "|")
+		case "max-conns":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid max-conns %q", val)
+			}
+			spec.config.MaxConnections = n
+		case "max-malformed":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid max-malformed %q", val)
+			}
+			spec.config.MaxMalformedFrames = n
+		case "":
+		default:
+			return fmt.Errorf("unknown listener setting %q", key)
+		}
+	}
+	if spec.config.Name == "" || spec.config.Address == "" {
+		return fmt.Errorf("listener %q needs name= and addr=", value)
+	}
+	*f = append(*f, spec)
+	return nil
+}
+
+// configure adds the listeners to the adapter, loading the certificate
+// for those that use TLS
+func (f listenerFlags) configure(a *adapter.TCPAdapter, certFile, keyFile string) error {
+	var tlsConfig *tls.Config
+	for _, spec := range f {
+		if spec.tls && tlsConfig == nil {
+			if certFile == "" || keyFile == "" {
+				return fmt.Errorf("listener %s uses TLS but -tls-cert/-tls-key are not set", spec.config.Name)
+			}
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return fmt.Errorf("loading TLS certificate: %w", err)
+			}
+			tlsConfig = &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+		}
+
+		config := spec.config
+		if spec.tls {
+			config.TLSConfig = tlsConfig
+		}
+		if err := a.AddListener(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireAuth reports whether any listener requires authentication
+func (f listenerFlags) requireAuth() bool {
+	for _, spec := range f {
+		if spec.config.RequireAuth {
+			return true
+		}
+	}
+	return false
+}
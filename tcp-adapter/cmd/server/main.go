@@ -1,21 +1,216 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"tcp-adapter/pkg/acl"
 	"tcp-adapter/pkg/adapter"
+	"tcp-adapter/pkg/external"
+	"tcp-adapter/pkg/filetransfer"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/proxyproto"
+	"tcp-adapter/pkg/session"
+	"tcp-adapter/pkg/signing"
+	"tcp-adapter/pkg/tracing"
+	"tcp-adapter/pkg/websocket"
+	"time"
 )
 
 func main() {
+	wsAddr := flag.String("ws", "", "address for the WebSocket endpoint, e.g. localhost:8081 (disabled if empty)")
+	wsPath := flag.String("ws-path", "/ws", "HTTP path of the WebSocket endpoint")
+	wsOrigins := flag.String("ws-origins", "", "comma-separated browser origins allowed on the WebSocket endpoint besides its own host, e.g. https://app.example.com, or * for any")
+	interceptors := flag.String("interceptors", "recovery,logging", "comma-separated interceptor chain, outermost first (recovery, logging, timing, auth, signature)")
+	authToken := flag.String("auth-token", "", "token clients must send with AUTH before other commands; adds the auth interceptor to the chain if -interceptors leaves it out")
+	maxMalformed := flag.Int("max-malformed", handler.DefaultMaxMalformedFrames, "malformed frames tolerated per connection before closing it (-1 for unlimited)")
+	trustedProxies := flag.String("proxy-protocol", "", "comma-separated CIDRs of load balancers that send PROXY protocol headers (disabled if empty)")
+	proxyTimeout := flag.Duration("proxy-header-timeout", proxyproto.DefaultHeaderTimeout, "time allowed for a trusted proxy to send its PROXY header")
+	tlsCert := flag.String("tls-cert", "", "certificate file for listeners with tls")
+	tlsKey := flag.String("tls-key", "", "private key file for listeners with tls")
+	var listeners listenerFlags
+	flag.Var(&listeners, "listener", "named listener, repeatable: name=NAME,addr=HOST:PORT[,tls][,auth][,commands=A|B][,max-conns=N][,max-malformed=N] (default localhost:8080)")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "time an upgraded process waits for open connections before closing them")
+	fileRoot := flag.String("file-root", "", "directory for PUT/GET/LIST file transfer commands (disabled if empty)")
+	fileMaxSize := flag.Int64("file-max-size", 0, "largest file accepted by PUT, in bytes (0 for no limit)")
+	fileQuota := flag.Int64("file-quota", 0, "bytes that may be stored below -file-root (0 for no limit)")
+	writeQueue := flag.Int("write-queue", handler.DefaultWriteQueueSize, "outbound messages buffered per connection for slow clients")
+	overflow := flag.String("overflow", "disconnect", "what to do when a client's write queue is full: disconnect, drop-oldest or drop-newest")
+	execConfig := flag.String("exec-config", "", "JSON file mapping commands to external programs (disabled if empty)")
+	pubsubEnabled := flag.Bool("pubsub", false, "enable SUBSCRIBE/UNSUBSCRIBE/PUBLISH")
+	sessionGrace := flag.Duration("session-grace", 0, "keep sessions this long after a disconnect so clients can RESUME (implies -pubsub; 0 disables)")
+	clusterAddr := flag.String("cluster-addr", "", "address for connections from other cluster nodes, e.g. localhost:7001 (implies -pubsub; disabled if empty)")
+	clusterAdvertise := flag.String("cluster-advertise", "", "address other nodes should dial if -cluster-addr is not reachable as written")
+	clusterPeers := flag.String("cluster-peers", "", "comma-separated cluster addresses of other nodes; may include this node's own")
+	clusterID := flag.String("cluster-id", "", "node name in the cluster (default: the advertised address)")
+	clusterSecret := flag.String("cluster-secret-file", "", "file with a shared secret that signs cluster traffic")
+	clusterAllow := flag.String("cluster-allow", "", "comma-separated CIDRs of hosts that may connect to -cluster-addr; a non-loopback -cluster-addr needs this or -cluster-secret-file")
+	poolWorkers := flag.Int("pool-workers", 0, "execute commands on this many shared workers instead of per connection (0 disables the pool)")
+	poolQueue := flag.Int("pool-queue", handler.DefaultPoolQueueSize, "commands waiting for a pool worker before new ones get TOO_MANY_REQUESTS")
+	poolLimit := flag.String("pool-limit", "", "per-command worker limits, e.g. RESIZE=2,REPORT=1")
+	stats := flag.Bool("stats", false, "enable the STATS command reporting listener, pool, ACL and signing counters as JSON (restrict it with -acl)")
+	poolPriority := flag.String("pool-priority", "", "per-command priorities (low, normal, high), e.g. REPORT=low; AUTH, PING and QUIT are high")
+	signKeys := flag.String("sign-keys", "", "file of HMAC keys for verifying signed messages, reloaded on SIGHUP (disabled if empty)")
+	signRequire := flag.String("sign-require", "", "comma-separated commands that must be signed, or * for all (needs -sign-keys)")
+	signSkew := flag.Duration("sign-skew", signing.DefaultMaxSkew, "accepted clock difference for signed messages")
+	aclFile := flag.String("acl", "", "file of allow/deny network rules, reloaded on SIGHUP (disabled if empty)")
+	trace := flag.Bool("trace", false, "write a JSON line to stdout for every finished request span")
+	flag.Parse()
+
 	// Create TCP adapter on localhost:8080
 	tcpAdapter := adapter.NewTCPAdapter("localhost", 8080)
+	tcpAdapter.SetMaxMalformedFrames(*maxMalformed)
+	overflowPolicy, err := handler.ParseOverflowPolicy(*overflow)
+	if err != nil {
+		log.Fatalf("Invalid -overflow: %v", err)
+	}
+	tcpAdapter.SetWriteQueue(*writeQueue, overflowPolicy)
+	if listeners.requireAuth() && *authToken == "" {
+		log.Fatal("Listeners with auth need -auth-token")
+	}
+	if err := listeners.configure(tcpAdapter, *tlsCert, *tlsKey); err != nil {
+		log.Fatalf("Invalid listener configuration: %v", err)
+	}
+	if *trustedProxies != "" {
+		networks, err := proxyproto.ParseCIDRs(*trustedProxies)
+		if err != nil {
+			log.Fatalf("Invalid -proxy-protocol: %v", err)
+		}
+		tcpAdapter.EnableProxyProtocol(proxyproto.Config{
+			TrustedProxies: networks,
+			HeaderTimeout:  *proxyTimeout,
+		})
+	}
+	if *pubsubEnabled || *sessionGrace > 0 || *clusterAddr != "" {
+		tcpAdapter.EnableSessions(session.Config{Grace: *sessionGrace})
+	}
+	if *clusterAddr != "" {
+		node, err := startCluster(tcpAdapter, *clusterID, *clusterAddr, *clusterAdvertise, *clusterPeers, *clusterSecret, *clusterAllow)
+		if err != nil {
+			log.Fatalf("Cluster: %v", err)
+		}
+		defer node.Stop()
+	}
+
+	var aclStore *acl.Store
+	if *aclFile != "" {
+		aclStore, err = acl.NewStore(*aclFile)
+		if err != nil {
+			log.Fatalf("Invalid -acl: %v", err)
+		}
+		tcpAdapter.SetACL(aclStore)
+	}
+	if *trace {
+		tcpAdapter.SetTracer(tracing.NewTracer(tracing.NewJSONExporter(os.Stdout)))
+	}
+	if *wsAddr != "" {
+		var opts websocket.Options
+		if *wsOrigins != "" {
+			opts.AllowedOrigins = strings.Split(*wsOrigins, ",")
+		}
+		tcpAdapter.EnableWebSocket(*wsAddr, *wsPath, opts)
+	}
+
+	if *fileRoot != "" {
+		store, err := filetransfer.NewStore(*fileRoot)
+		if err != nil {
+			log.Fatalf("Invalid -file-root: %v", err)
+		}
+		store.MaxFileSize = *fileMaxSize
+		store.Quota = *fileQuota
+		filetransfer.Register(tcpAdapter.Router(), store, filetransfer.DefaultChunkSize)
+		log.Printf("File transfer enabled, storing files in %s", store.Root())
+	}
+
+	if *execConfig != "" {
+		configs, err := external.LoadConfig(*execConfig)
+		if err != nil {
+			log.Fatalf("Invalid -exec-config: %v", err)
+		}
+		handlers, err := external.Register(tcpAdapter.Router(), configs)
+		if err != nil {
+			log.Fatalf("Invalid -exec-config: %v", err)
+		}
+		defer external.Close(handlers)
+		log.Printf("Registered %d external command(s) from %s", len(handlers), *execConfig)
+	}
+
+	var keyring *signing.Keyring
+	if *signKeys != "" {
+		keyring, err = signing.NewKeyring(*signKeys)
+		if err != nil {
+			log.Fatalf("Invalid -sign-keys: %v", err)
+		}
+	} else if *signRequire != "" {
+		log.Fatal("-sign-require needs -sign-keys")
+	}
+
+	// Build the interceptor chain in the configured order
+	registry := handler.NewInterceptorRegistry()
+	chainNames := strings.Split(*interceptors, ",")
+	if *authToken != "" {
+		tcpAdapter.Router().Handle("AUTH", handler.AuthCommand(*authToken))
+		registry.Register("auth", handler.RequireAuth())
+		// A token without the interceptor would leave commands open
+		if !containsName(chainNames, "auth") {
+			chainNames = append(chainNames, "auth")
+		}
+	}
+	var verifier *signing.Verifier
+	if keyring != nil {
+		verifier = signing.NewVerifier(signing.Config{
+			Keys:     keyring,
+			Required: strings.Split(*signRequire, ","),
+			MaxSkew:  *signSkew,
+		})
+		registry.Register("signature", verifier.Interceptor())
+		// Verification must not be skipped by leaving it out of the chain
+		if !containsName(chainNames, "signature") {
+			chainNames = append(chainNames, "signature")
+		}
+	}
+	chain, err := registry.Build(chainNames)
+	if err != nil {
+		log.Fatalf("Invalid interceptor configuration: %v", err)
+	}
+	tcpAdapter.Router().Use(chain...)
+
+	// The pool goes last so the configured interceptors run on the
+	// connection and only the command itself waits for a worker
+	var pool *handler.Pool
+	if *poolWorkers > 0 {
+		config, err := poolConfig(*poolWorkers, *poolQueue, *poolLimit, *poolPriority)
+		if err != nil {
+			log.Fatalf("Invalid pool configuration: %v", err)
+		}
+		pool = handler.NewPool(config)
+		defer pool.Close()
+		tcpAdapter.Router().Use(pool.Interceptor())
+		log.Printf("Executing commands on %d pool workers", config.Workers)
+	}
+	if *stats {
+		registerStats(tcpAdapter.Router(), statsSources{adapter: tcpAdapter, pool: pool, acl: aclStore, verifier: verifier})
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+	// Handle zero-downtime upgrades (SIGUSR2 on Linux)
+	upgradeChan := make(chan os.Signal, 1)
+	if len(upgradeSignals) > 0 {
+		signal.Notify(upgradeChan, upgradeSignals...)
+	}
+
+	// Reload the ACL and signing key files on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	if aclStore != nil || keyring != nil {
+		signal.Notify(reloadChan, syscall.SIGHUP)
+	}
+
 	// Start server in a goroutine
 	go func() {
 		if err := tcpAdapter.Start(); err != nil {
@@ -23,13 +218,52 @@ func main() {
 		}
 	}()
 
-	// Wait for interrupt signal
-	<-sigChan
-	log.Println("\nReceived shutdown signal")
-	
-	if err := tcpAdapter.Stop(); err != nil {
-		log.Printf("Error stopping server: %v", err)
+	for {
+		select {
+		case <-reloadChan:
+			if aclStore != nil {
+				if err := aclStore.Reload(); err != nil {
+					log.Printf("ACL reload failed, keeping previous rules: %v", err)
+				}
+			}
+			if keyring != nil {
+				if err := keyring.Reload(); err != nil {
+					log.Printf("Signing key reload failed, keeping previous keys: %v", err)
+				}
+			}
+
+		case <-upgradeChan:
+			log.Println("Received upgrade signal")
+			if err := tcpAdapter.Upgrade(); err != nil {
+				log.Printf("Upgrade failed, still serving: %v", err)
+				continue
+			}
+			// The new process owns the sockets; finish current work and exit
+			if err := tcpAdapter.Drain(*drainTimeout); err != nil {
+				log.Printf("Drain: %v", err)
+			}
+			log.Println("Old process exiting after upgrade")
+			return
+
+		case <-sigChan:
+			log.Println("\nReceived shutdown signal")
+
+			if err := tcpAdapter.Stop(); err != nil {
+				log.Printf("Error stopping server: %v", err)
+			}
+
+			log.Println("Server stopped gracefully")
+			return
+		}
+	}
+}
+
+// containsName reports whether names lists name, ignoring case and spaces
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
 	}
-	
-	log.Println("Server stopped gracefully")
+	return false
 }
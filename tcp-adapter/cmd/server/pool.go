@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/handler"
+)
+
+// parseCommandSettings parses "COMMAND=VALUE,..." lists
+func parseCommandSettings(spec string) (map[string]string, error) {
+	settings := make(map[string]string)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		command, value, ok := strings.Cut(item, "=")
+		if !ok || command == "" || value == "" {
+			return nil, fmt.Errorf("expected COMMAND=VALUE, got %q", item)
+		}
+		settings[strings.ToUpper(command)] = value
+	}
+	return settings, nil
+}
+
+// poolConfig builds the execution pool settings from the -pool-* flags
+func poolConfig(workers, queue int, limitSpec, prioritySpec string) (handler.PoolConfig, error) {
+	config := handler.PoolConfig{
+		Workers:    workers,
+		QueueSize:  queue,
+		Limits:     make(map[string]int),
+		Priorities: make(map[string]handler.Priority),
+	}
+
+	limits, err := parseCommandSettings(limitSpec)
+	if err != nil {
+		return config, fmt.Errorf("-pool-limit: %w", err)
+	}
+	for command, value := range limits {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return config, fmt.Errorf("-pool-limit: %s: limit must be a positive number", command)
+		}
+		config.Limits[command] = n
+	}
+
+	priorities, err := parseCommandSettings(prioritySpec)
+	if err != nil {
+		return config, fmt.Errorf("-pool-priority: %w", err)
+	}
+	for command, value := range priorities {
+		priority, err := handler.ParsePriority(value)
+		if err != nil {
+			return config, fmt.Errorf("-pool-priority: %s: %w", command, err)
+		}
+		config.Priorities[command] = priority
+	}
+	return config, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"tcp-adapter/pkg/acl"
+	"tcp-adapter/pkg/adapter"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/signing"
+)
+
+// statsSources are the components whose counters STATS reports; nil
+// ones are disabled and left out
+type statsSources struct {
+	adapter  *adapter.TCPAdapter
+	pool     *handler.Pool
+	acl      *acl.Store
+	verifier *signing.Verifier
+}
+
+type statsReport struct {
+	Listeners []adapter.ListenerStats `json:"listeners"`
+	Pool      *poolReport             `json:"pool,omitempty"`
+	ACL       *aclReport              `json:"acl,omitempty"`
+	Signing   *signingReport          `json:"signing,omitempty"`
+}
+
+type poolReport struct {
+	Workers   int    `json:"workers"`
+	Busy      int    `json:"busy"`
+	Queued    int    `json:"queued"`
+	Submitted int64  `json:"submitted"`
+	Completed int64  `json:"completed"`
+	Rejected  int64  `json:"rejected"`
+	AvgWait   string `json:"avg_wait"`
+	MaxWait   string `json:"max_wait"`
+}
+
+type aclReport struct {
+	DeniedConnections int64 `json:"denied_connections"`
+	DeniedCommands    int64 `json:"denied_commands"`
+}
+
+type signingReport struct {
+	Verified int64 `json:"verified"`
+	Rejected int64 `json:"rejected"`
+}
+
+// report collects the current counters
+func (s statsSources) report() statsReport {
+	r := statsReport{Listeners: s.adapter.Stats()}
+	if s.pool != nil {
+		stats := s.pool.Stats()
+		r.Pool = &poolReport{
+			Workers:   stats.Workers,
+			Busy:      stats.Busy,
+			Queued:    stats.Queued,
+			Submitted: stats.Submitted,
+			Completed: stats.Completed,
+			Rejected:  stats.Rejected,
+			AvgWait:   stats.AvgWait().String(),
+			MaxWait:   stats.MaxWait.String(),
+		}
+	}
+	if s.acl != nil {
+		conns, commands := s.acl.Denied()
+		r.ACL = &aclReport{DeniedConnections: conns, DeniedCommands: commands}
+	}
+	if s.verifier != nil {
+		verified, rejected := s.verifier.Stats()
+		r.Signing = &signingReport{Verified: verified, Rejected: rejected}
+	}
+	return r
+}
+
+// registerStats adds the STATS command, answering STATS:<JSON report>
+func registerStats(router *handler.Router, sources statsSources) {
+	router.Handle("STATS", handler.JSON("STATS", func(ctx *handler.Context, req *struct{}) (*statsReport, error) {
+		report := sources.report()
+		return &report, nil
+	}))
+}
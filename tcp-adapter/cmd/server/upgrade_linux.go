@@ -0,0 +1,11 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals trigger a zero-downtime restart with socket handoff
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// upgradeSignals is empty: socket handoff is only supported on Linux
+var upgradeSignals []os.Signal
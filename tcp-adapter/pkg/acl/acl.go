@@ -0,0 +1,250 @@
+// Package acl restricts which networks may connect to the adapter and
+// which commands they may run.
+//
+// Rules are read from a text file, one per line:
+//
+//	# action  network        [listener=NAME,...] [command=CMD,...]
+//	allow     10.0.0.0/8     command=ADMIN,SHUTDOWN
+//	deny      0.0.0.0/0      command=ADMIN,SHUTDOWN
+//	deny      203.0.113.0/24 listener=public
+//
+// Rules without command= are checked when a connection is accepted;
+// rules with command= are checked each time one of those commands runs.
+// In both cases the first matching rule wins and anything no rule
+// matches is allowed, so end the file with `deny 0.0.0.0/0` and
+// `deny ::/0` for allowlist behaviour. An address that is not an IP is
+// denied by the first deny rule that could apply to it.
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Action is what a matching rule does
+type Action int
+
+const (
+	Allow Action = iota
+	Deny
+)
+
+// String returns "allow" or "deny"
+func (a Action) String() string {
+	if a == Deny {
+		return "deny"
+	}
+	return "allow"
+}
+
+// Rule is one line of an ACL file
+type Rule struct {
+	Action  Action
+	Network *net.IPNet
+	// Listeners limits the rule to these listeners; empty means all
+	Listeners []string
+	// Commands makes the rule apply to these commands instead of to
+	// new connections
+	Commands []string
+	// Line is the line number in the source file, for log messages
+	Line int
+}
+
+// String formats the rule as it would appear in a file
+func (r Rule) String() string {
+	s := r.Action.String() + " " + r.Network.String()
+	if len(r.Listeners) > 0 {
+		s += " listener=" + strings.Join(r.Listeners, ",")
+	}
+	if len(r.Commands) > 0 {
+		s += " command=" + strings.Join(r.Commands, ",")
+	}
+	return s
+}
+
+// matches reports whether the rule applies to ip on listener. An ip of
+// nil, for an address that is not an IP, matches every deny rule of the
+// listener, so it cannot slip past a network it might belong to.
+func (r Rule) matches(listener string, ip net.IP) bool {
+	if len(r.Listeners) > 0 && !contains(r.Listeners, listener) {
+		return false
+	}
+	if ip == nil {
+		return r.Action == Deny
+	}
+	return r.Network.Contains(ip)
+}
+
+// ACL is an immutable, ordered list of rules
+type ACL struct {
+	conn    []Rule
+	command []Rule
+}
+
+// New builds an ACL from rules in evaluation order
+func New(rules []Rule) *ACL {
+	a := &ACL{}
+	for _, rule := range rules {
+		if len(rule.Commands) > 0 {
+			a.command = append(a.command, rule)
+		} else {
+			a.conn = append(a.conn, rule)
+		}
+	}
+	return a
+}
+
+// Len returns the number of rules
+func (a *ACL) Len() int {
+	return len(a.conn) + len(a.command)
+}
+
+// CheckConn decides whether ip may connect to listener. It returns the
+// deciding rule, or nil if no rule matched and the connection is allowed.
+func (a *ACL) CheckConn(listener string, ip net.IP) (bool, *Rule) {
+	for i := range a.conn {
+		if a.conn[i].matches(listener, ip) {
+			return a.conn[i].Action == Allow, &a.conn[i]
+		}
+	}
+	return true, nil
+}
+
+// CheckCommand decides whether ip may run command on listener
+func (a *ACL) CheckCommand(listener string, ip net.IP, command string) (bool, *Rule) {
+	command = strings.ToUpper(command)
+	for i := range a.command {
+		rule := &a.command[i]
+		if contains(rule.Commands, command) && rule.matches(listener, ip) {
+			return rule.Action == Allow, rule
+		}
+	}
+	return true, nil
+}
+
+// Parse reads rules in the format described in the package documentation
+func Parse(r io.Reader) (*ACL, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		rule, err := parseRule(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rule.Line = line
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(rules), nil
+}
+
+// LoadFile parses the ACL file at path
+func LoadFile(path string) (*ACL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+// parseRule parses the fields of a single rule line
+func parseRule(fields []string) (Rule, error) {
+	var rule Rule
+	if len(fields) < 2 {
+		return rule, fmt.Errorf("expected ACTION NETWORK, got %q", strings.Join(fields, " "))
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "allow":
+		rule.Action = Allow
+	case "deny":
+		rule.Action = Deny
+	default:
+		return rule, fmt.Errorf("unknown action %q (want allow or deny)", fields[0])
+	}
+
+	network, err := parseNetwork(fields[1])
+	if err != nil {
+		return rule, err
+	}
+	rule.Network = network
+
+	for _, option := range fields[2:] {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("invalid option %q", option)
+		}
+		values := strings.Split(value, ",")
+		switch strings.ToLower(key) {
+		case "listener":
+			rule.Listeners = append(rule.Listeners, values...)
+		case "command":
+			for _, command := range values {
+				rule.Commands = append(rule.Commands, strings.ToUpper(command))
+			}
+		default:
+			return rule, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return rule, nil
+}
+
+// parseNetwork accepts a CIDR or a single address
+func parseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", value)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// HostIP extracts the IP from a host:port address, or returns nil
+func HostIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+)
+
+const testRules = `
+allow 10.0.0.0/8     command=ADMIN
+deny  0.0.0.0/0      command=ADMIN
+deny  203.0.113.0/24 listener=public
+deny  192.0.2.7
+`
+
+func TestCheck(t *testing.T) {
+	a, err := Parse(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		listener string
+		addr     string
+		command  string
+		want     bool
+		wantLine int
+	}{
+		{"unmatched connection", "public", "198.51.100.1:5000", "", true, 0},
+		{"denied network", "public", "203.0.113.9:5000", "", false, 4},
+		{"other listener", "internal", "203.0.113.9:5000", "", true, 0},
+		{"single address", "internal", "192.0.2.7:5000", "", false, 5},
+		{"address without port", "internal", "192.0.2.7", "", false, 5},
+		{"allowed command", "public", "10.1.2.3:5000", "ADMIN", true, 2},
+		{"denied command", "public", "198.51.100.1:5000", "admin", false, 3},
+		{"unlisted command", "public", "198.51.100.1:5000", "PING", true, 0},
+		{"not an IP, deny rule applies", "public", "pipe", "", false, 4},
+		{"not an IP, no deny rule applies", "other", "pipe", "PING", true, 0},
+		{"not an IP, command", "other", "pipe", "ADMIN", false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got  bool
+				rule *Rule
+			)
+			if tt.command == "" {
+				got, rule = a.CheckConn(tt.listener, HostIP(tt.addr))
+			} else {
+				got, rule = a.CheckCommand(tt.listener, HostIP(tt.addr), tt.command)
+			}
+			line := 0
+			if rule != nil {
+				line = rule.Line
+			}
+			if got != tt.want || line != tt.wantLine {
+				t.Errorf("allowed = %v by line %d, want %v by line %d", got, line, tt.want, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"network", "allow 10.0.0.0/8", []string{"allow 10.0.0.0/8"}, ""},
+		{"single IPv4 address", "deny 192.0.2.7", []string{"deny 192.0.2.7/32"}, ""},
+		{"single IPv6 address", "deny 2001:db8::1", []string{"deny 2001:db8::1/128"}, ""},
+		{"host bits are cleared", "deny 10.1.2.3/8", []string{"deny 10.0.0.0/8"}, ""},
+		{"action is case-insensitive", "DENY ::/0", []string{"deny ::/0"}, ""},
+		{"options", "allow 10.0.0.0/8 listener=admin,internal command=stats,Shutdown",
+			[]string{"allow 10.0.0.0/8 listener=admin,internal command=STATS,SHUTDOWN"}, ""},
+		{"repeated option", "deny 0.0.0.0/0 command=A command=B", []string{"deny 0.0.0.0/0 command=A,B"}, ""},
+		{"comments and blank lines", "# header\n\n  allow 10.0.0.0/8 # trusted\n", []string{"allow 10.0.0.0/8"}, ""},
+		{"missing network", "allow", nil, "line 1: expected ACTION NETWORK"},
+		{"unknown action", "\npermit 10.0.0.0/8", nil, `line 2: unknown action "permit"`},
+		{"bad network", "allow 10.0.0.0/33", nil, `invalid network "10.0.0.0/33"`},
+		{"bad address", "allow example.com", nil, `invalid address "example.com"`},
+		{"option without value", "allow 10.0.0.0/8 command=", nil, `invalid option "command="`},
+		{"unknown option", "allow 10.0.0.0/8 port=22", nil, `unknown option "port"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Parse(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got []string
+			for _, rules := range [][]Rule{a.conn, a.command} {
+				for _, rule := range rules {
+					got = append(got, rule.String())
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") || a.Len() != len(tt.want) {
+				t.Errorf("Parse = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package acl
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+)
+
+// Store holds the active ACL, swaps it atomically on Reload and counts
+// denied attempts. A nil *Store allows everything.
+type Store struct {
+	path string
+
+	mu  sync.RWMutex
+	acl *ACL
+
+	deniedConns    int64
+	deniedCommands int64
+}
+
+// NewStore loads the ACL file at path
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewStoreFromACL wraps an ACL that is not backed by a file
+func NewStoreFromACL(a *ACL) *Store {
+	return &Store{acl: a}
+}
+
+// Reload re-reads the ACL file. On error the previous rules stay active.
+func (s *Store) Reload() error {
+	if s.path == "" {
+		return fmt.Errorf("acl: store has no file to reload")
+	}
+	a, err := LoadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("acl: %w", err)
+	}
+	s.Set(a)
+	log.Printf("Loaded %d ACL rule(s) from %s", a.Len(), s.path)
+	return nil
+}
+
+// Set replaces the active ACL
+func (s *Store) Set(a *ACL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acl = a
+}
+
+// current returns the active ACL
+func (s *Store) current() *ACL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl
+}
+
+// AllowConn reports whether a connection from remoteAddr may be served on
+// listener, logging and counting it if not
+func (s *Store) AllowConn(listener, remoteAddr string) bool {
+	if s == nil {
+		return true
+	}
+	ip := HostIP(remoteAddr)
+	allowed, rule := s.current().CheckConn(listener, ip)
+	if !allowed {
+		atomic.AddInt64(&s.deniedConns, 1)
+		log.Printf("ACL denied connection from %s on %s (rule %d: %s)%s", remoteAddr, listener, rule.Line, rule, notIP(ip))
+	}
+	return allowed
+}
+
+// AllowCommand reports whether remoteAddr may run command on listener,
+// logging and counting it if not
+func (s *Store) AllowCommand(listener, remoteAddr, command string) bool {
+	if s == nil {
+		return true
+	}
+	ip := HostIP(remoteAddr)
+	allowed, rule := s.current().CheckCommand(listener, ip, command)
+	if !allowed {
+		atomic.AddInt64(&s.deniedCommands, 1)
+		log.Printf("ACL denied %s from %s on %s (rule %d: %s)%s", command, remoteAddr, listener, rule.Line, rule, notIP(ip))
+	}
+	return allowed
+}
+
+// notIP explains denials of addresses that are not IPs, which no rule
+// can match
+func notIP(ip net.IP) string {
+	if ip == nil {
+		return ", address is not an IP"
+	}
+	return ""
+}
+
+// Denied returns how many connections and commands have been denied
+func (s *Store) Denied() (conns, commands int64) {
+	return atomic.LoadInt64(&s.deniedConns), atomic.LoadInt64(&s.deniedCommands)
+}
+
+// Interceptor enforces the per-command rules with FORBIDDEN errors
+func (s *Store) Interceptor() handler.Interceptor {
+	return func(ctx *handler.Context, msg *protocol.Message, next handler.CommandFunc) *protocol.Message {
+		if !s.AllowCommand(ctx.Listener, ctx.RemoteAddr, msg.Command) {
+			return protocol.Errorf(protocol.CodeForbidden, "Command %s is not allowed from %s", msg.Command, ctx.RemoteAddr).ToMessage()
+		}
+		return next(ctx, msg)
+	}
+}
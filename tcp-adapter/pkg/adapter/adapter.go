@@ -1,72 +1,430 @@
 package adapter
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"tcp-adapter/pkg/acl"
 	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/proxyproto"
+	"tcp-adapter/pkg/pubsub"
+	"tcp-adapter/pkg/session"
+	"tcp-adapter/pkg/tracing"
+	"tcp-adapter/pkg/websocket"
+	"time"
 )
 
 // TCPAdapter represents a TCP server adapter
 type TCPAdapter struct {
-	host     string
-	port     int
+	host      string
+	port      int
+	mu        sync.Mutex
+	listeners []*managedListener
+	conns     *connRegistry
+	router    *handler.Router
+	options   handler.Options
+
+	proxyProtocol *proxyproto.Config
+	acl           *acl.Store
+	sessions      *session.Manager
+
+	wsAddress  string
+	wsPath     string
+	wsListener *managedListener
+	wsOptions  websocket.Options
+	httpServer *http.Server
+
+	// aux are sockets bound with Listen for other parts of the process
+	aux []auxListener
+	// inherited holds the sockets handed over by a previous process
+	// until they are claimed
+	inheritOnce sync.Once
+	inherited   map[string]net.Listener
+	inheritErr  error
+}
+
+// auxListener is a socket bound with Listen
+type auxListener struct {
+	name     string
 	listener net.Listener
 }
 
-// NewTCPAdapter creates a new TCP adapter instance
+// auxPrefix keeps the handoff names of Listen sockets apart from the
+// adapter's listeners
+const auxPrefix = "@"
+
+// NewTCPAdapter creates a new TCP adapter instance. The host and port
+// become the default listener unless listeners are added with AddListener.
 func NewTCPAdapter(host string, port int) *TCPAdapter {
 	return &TCPAdapter{
-		host: host,
-		port: port,
+		host:    host,
+		port:    port,
+		conns:   newConnRegistry(),
+		router:  handler.NewRouter(),
+		options: handler.DefaultOptions(),
+	}
+}
+
+// SetMaxMalformedFrames sets how many malformed frames a connection may
+// send before it is closed; negative values disable the limit
+func (a *TCPAdapter) SetMaxMalformedFrames(n int) {
+	a.options.MaxMalformedFrames = n
+}
+
+// SetWriteQueue sets how many outbound messages each connection buffers
+// and what happens when a client reads too slowly to keep up
+func (a *TCPAdapter) SetWriteQueue(size int, policy handler.OverflowPolicy) {
+	a.options.WriteQueueSize = size
+	a.options.OverflowPolicy = policy
+}
+
+// SetTracer records spans for every request on every listener
+func (a *TCPAdapter) SetTracer(tracer *tracing.Tracer) {
+	a.options.Tracer = tracer
+}
+
+// Router returns the command router shared by all connections, used to
+// register additional commands and interceptors before Start
+func (a *TCPAdapter) Router() *handler.Router {
+	return a.router
+}
+
+// EnableWebSocket makes Start also serve a WebSocket endpoint on address.
+// Each text frame on that endpoint carries one protocol message and is
+// handled by the same connection handler as TCP clients. The endpoint is
+// reported and matched by ACL rules as the listener WebSocketListener.
+func (a *TCPAdapter) EnableWebSocket(address, path string, opts websocket.Options) {
+	if path == "" {
+		path = "/ws"
+	}
+	a.wsAddress = address
+	a.wsPath = path
+	a.wsOptions = opts
+	a.wsListener = &managedListener{
+		config: ListenerConfig{Name: WebSocketListener, Address: address},
+	}
+}
+
+// EnableProxyProtocol decodes PROXY protocol v1/v2 headers sent by the
+// trusted proxies in config, so handlers see the original client address
+// instead of the load balancer's
+func (a *TCPAdapter) EnableProxyProtocol(config proxyproto.Config) {
+	a.proxyProtocol = &config
+}
+
+// EnableSessions gives every connection a session and registers the
+// SUBSCRIBE, UNSUBSCRIBE and PUBLISH commands. With a non-zero grace
+// period, sessions survive disconnects and can be taken over with RESUME.
+func (a *TCPAdapter) EnableSessions(config session.Config) *session.Manager {
+	a.sessions = session.NewManager(config, pubsub.NewBroker())
+	a.sessions.Register(a.router)
+	a.options.OnConnect = a.sessions.Connect
+	a.OnDisconnect(a.sessions.Disconnect)
+	return a.sessions
+}
+
+// OnDisconnect adds fn to the callbacks run once a client has gone, in
+// the order they were added. Commands use it to drop per-connection
+// state such as locks held for the client.
+func (a *TCPAdapter) OnDisconnect(fn func(ctx *handler.Context)) {
+	previous := a.options.OnDisconnect
+	if previous == nil {
+		a.options.OnDisconnect = fn
+		return
+	}
+	a.options.OnDisconnect = func(ctx *handler.Context) {
+		previous(ctx)
+		fn(ctx)
+	}
+}
+
+// Broker returns the pub/sub broker, or nil if sessions are not enabled
+func (a *TCPAdapter) Broker() *pubsub.Broker {
+	if a.sessions == nil {
+		return nil
+	}
+	return a.sessions.Broker()
+}
+
+// SetACL restricts connections and commands by client network. The store
+// is consulted on every connection, so reloading it takes effect for new
+// connections and commands immediately.
+func (a *TCPAdapter) SetACL(store *acl.Store) {
+	a.acl = store
+}
+
+// wrapListener applies listener-level features such as the PROXY protocol
+func (a *TCPAdapter) wrapListener(listener net.Listener) net.Listener {
+	if a.proxyProtocol != nil {
+		return proxyproto.NewListener(listener, *a.proxyProtocol)
 	}
+	return listener
 }
 
-// Start begins listening for TCP connections
+// Start opens every configured listener and serves them until Stop
 func (a *TCPAdapter) Start() error {
-	address := fmt.Sprintf("%s:%d", a.host, a.port)
-	
-	// Create TCP listener
-	listener, err := net.Listen("tcp", address)
-	if err != nil {
-		return fmt.Errorf("failed to start listener: %w", err)
-	}
-	
-	a.listener = listener
-	log.Printf("TCP Adapter listening on %s", address)
-
-	// Accept connections
-	for {
-		conn, err := a.listener.Accept()
+	if len(a.snapshotListeners()) == 0 {
+		a.defaultListener()
+	}
+	listeners := a.snapshotListeners()
+
+	// Sockets handed over by a previous process during an upgrade
+	if err := a.loadInherited(); err != nil {
+		return err
+	}
+	closeAll := func(bound []net.Listener) {
+		for _, l := range bound {
+			l.Close()
+		}
+		a.closeInherited()
+	}
+
+	// Bind every address first so a bad configuration fails fast
+	bound := make([]net.Listener, 0, len(listeners))
+	for _, ml := range listeners {
+		listener, err := a.listen(ml.config)
+		if err != nil {
+			closeAll(bound)
+			return fmt.Errorf("failed to start listener %s: %w", ml.config.Name, err)
+		}
+		bound = append(bound, listener)
+	}
+	log.Printf("TCP Adapter listening on %s", a.GetAddress())
+
+	if a.wsAddress != "" {
+		listener, err := a.listen(a.wsListener.config)
 		if err != nil {
-			log.Printf("Error accepting connection: %v", err)
-			continue
+			closeAll(bound)
+			return fmt.Errorf("failed to start websocket listener: %w", err)
+		}
+		a.startWebSocket(listener)
+	}
+
+	// Sockets inherited for listeners that no longer exist
+	a.closeInherited()
+
+	notifyReady()
+	return a.serveAll(listeners, bound)
+}
+
+// listen returns the inherited socket for a listener, or binds a new one
+func (a *TCPAdapter) listen(config ListenerConfig) (net.Listener, error) {
+	if listener := a.takeInherited(config.Name); listener != nil {
+		log.Printf("Listener %s inherited %s from previous process", config.Name, listener.Addr())
+		return listener, nil
+	}
+	return net.Listen("tcp", config.Address)
+}
+
+// Listen binds address for another part of the process, such as the
+// cluster port, so that Upgrade hands it to the new process along with
+// the adapter's listeners. In a process started by Upgrade it returns
+// the socket inherited under name instead. Call it before Start, which
+// closes inherited sockets that nobody claimed.
+func (a *TCPAdapter) Listen(name, address string) (net.Listener, error) {
+	if err := a.loadInherited(); err != nil {
+		return nil, err
+	}
+	name = auxPrefix + name
+	listener := a.takeInherited(name)
+	if listener != nil {
+		log.Printf("Listener %s inherited %s from previous process", name, listener.Addr())
+	} else {
+		var err error
+		if listener, err = net.Listen("tcp", address); err != nil {
+			return nil, err
+		}
+	}
+	a.mu.Lock()
+	a.aux = append(a.aux, auxListener{name: name, listener: listener})
+	a.mu.Unlock()
+	return listener, nil
+}
+
+// loadInherited picks up the sockets of a previous process once
+func (a *TCPAdapter) loadInherited() error {
+	a.inheritOnce.Do(func() {
+		a.inherited, a.inheritErr = inheritListeners()
+	})
+	return a.inheritErr
+}
+
+// takeInherited claims the inherited socket named name, if any
+func (a *TCPAdapter) takeInherited(name string) net.Listener {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	listener, ok := a.inherited[name]
+	if !ok {
+		return nil
+	}
+	delete(a.inherited, name)
+	return listener
+}
+
+// closeInherited closes the inherited sockets nobody claimed
+func (a *TCPAdapter) closeInherited() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, l := range a.inherited {
+		log.Printf("Closing inherited listener %s: not configured", name)
+		l.Close()
+	}
+	a.inherited = nil
+}
+
+// serveAll serves each listener in its own goroutine and returns the
+// first error once all of them have stopped
+func (a *TCPAdapter) serveAll(listeners []*managedListener, bound []net.Listener) error {
+	errs := make(chan error, len(listeners))
+	for i, ml := range listeners {
+		go func(ml *managedListener, listener net.Listener) {
+			errs <- a.serve(ml, listener)
+		}(ml, bound[i])
+	}
+
+	var firstErr error
+	for range listeners {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
 		}
+	}
+	return firstErr
+}
+
+// Serve accepts connections for the default listener on an existing
+// net.Listener until it is closed. It lets callers (and tests) supply
+// their own listener, e.g. one bound to an ephemeral port.
+func (a *TCPAdapter) Serve(listener net.Listener) error {
+	return a.serve(a.defaultListener(), listener)
+}
 
-		// Handle each connection in a goroutine (concurrent handling)
-		go a.handleConnection(conn)
+// ServeConn handles a single already-established connection with the
+// default listener's settings and returns when it is closed. It works
+// with any net.Conn, including net.Pipe.
+func (a *TCPAdapter) ServeConn(conn net.Conn) {
+	ml := a.defaultListener()
+	if refused := a.admit(conn, ml); refused != "" {
+		rejectConnection(conn, protocol.CodeUnavailable, refused)
+		return
 	}
+	a.handleConnection(conn, ml)
 }
 
-// handleConnection processes a single TCP connection
-func (a *TCPAdapter) handleConnection(conn net.Conn) {
-	h := handler.NewConnectionHandler(conn)
+// startWebSocket starts the HTTP server that upgrades requests to WebSocket
+func (a *TCPAdapter) startWebSocket(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(a.wsPath, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, a.wsOptions)
+		if err != nil {
+			log.Printf("WebSocket upgrade from %s failed: %v", r.RemoteAddr, err)
+			return
+		}
+		// The hijacked connection is no longer tracked by the HTTP server,
+		// so it is served for as long as the client keeps it open
+		if refused := a.admit(conn, a.wsListener); refused != "" {
+			rejectConnection(conn, protocol.CodeUnavailable, refused)
+			return
+		}
+		a.handleConnection(conn, a.wsListener)
+	})
+
+	a.httpServer = &http.Server{Handler: mux}
+	a.wsListener.mu.Lock()
+	a.wsListener.listener = listener
+	a.wsListener.raw = listener
+	a.wsListener.mu.Unlock()
+	log.Printf("WebSocket endpoint listening on ws://%s%s", listener.Addr(), a.wsPath)
+
+	go func() {
+		if err := a.httpServer.Serve(a.wrapListener(listener)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("WebSocket server error: %v", err)
+		}
+	}()
+}
+
+// handleConnection processes a single connection accepted by ml, which
+// the caller has already admitted
+func (a *TCPAdapter) handleConnection(conn net.Conn, ml *managedListener) {
+	defer atomic.AddInt64(&ml.active, -1)
+	defer a.conns.remove(conn)
+	if !a.acl.AllowConn(ml.config.Name, conn.RemoteAddr().String()) {
+		atomic.AddInt64(&ml.denied, 1)
+		rejectConnection(conn, protocol.CodeForbidden, "Access denied")
+		return
+	}
+
+	atomic.AddInt64(&ml.accepted, 1)
+
+	h := handler.NewConnectionHandler(conn, a.router, a.listenerOptions(ml))
 	h.Handle()
 }
 
 // Stop gracefully shuts down the adapter
 func (a *TCPAdapter) Stop() error {
-	if a.listener != nil {
-		log.Println("Stopping TCP Adapter...")
-		return a.listener.Close()
+	if a.httpServer != nil {
+		a.httpServer.Close()
+	}
+
+	log.Println("Stopping TCP Adapter...")
+	var firstErr error
+	for _, ml := range a.snapshotListeners() {
+		if err := ml.close(); err != nil && !errors.Is(err, net.ErrClosed) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Drain stops accepting new connections and waits up to timeout for the
+// open ones to finish. Connections still open after timeout are closed.
+func (a *TCPAdapter) Drain(timeout time.Duration) error {
+	a.Stop()
+	a.conns.close()
+
+	done := make(chan struct{})
+	go func() {
+		a.conns.wait()
+		close(done)
+	}()
+
+	log.Printf("Draining %d open connection(s)", a.conns.count())
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		remaining := a.conns.count()
+		a.conns.closeAll()
+		<-done
+		return fmt.Errorf("closed %d connection(s) still open after %s", remaining, timeout)
 	}
-	return nil
 }
 
-// GetAddress returns the current listening address
+// GetAddress returns the current listening address of the first listener
 func (a *TCPAdapter) GetAddress() string {
-	if a.listener != nil {
-		return a.listener.Addr().String()
+	listeners := a.snapshotListeners()
+	if len(listeners) > 0 {
+		return listeners[0].address()
 	}
 	return fmt.Sprintf("%s:%d", a.host, a.port)
 }
+
+// Addresses returns the listening address of every listener by name
+func (a *TCPAdapter) Addresses() map[string]string {
+	addresses := make(map[string]string)
+	for _, ml := range a.snapshotListeners() {
+		addresses[ml.config.Name] = ml.address()
+	}
+	return addresses
+}
+
+// snapshotListeners returns a copy of the registered listeners
+func (a *TCPAdapter) snapshotListeners() []*managedListener {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*managedListener{}, a.listeners...)
+}
@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// DefaultListenerName is used for the listener created from NewTCPAdapter's
+// host and port when no listener is added explicitly
+const DefaultListenerName = "default"
+
+// WebSocketListener names the WebSocket endpoint; it cannot be used for
+// other listeners
+const WebSocketListener = "websocket"
+
+// ListenerConfig describes one named listener managed by the adapter
+type ListenerConfig struct {
+	// Name identifies the listener in logs, stats and handler contexts
+	Name string
+	// Address is the host:port to listen on
+	Address string
+	// TLSConfig enables TLS on this listener when non-nil
+	TLSConfig *tls.Config
+	// Commands limits the listener to these commands; empty enables all.
+	// AUTH, PING, QUIT and RESUME are always available.
+	Commands []string
+	// RequireAuth rejects commands until the client has sent AUTH
+	RequireAuth bool
+	// MaxConnections caps concurrent connections; 0 means unlimited
+	MaxConnections int
+	// MaxMalformedFrames overrides the adapter-wide setting when non-zero
+	MaxMalformedFrames int
+}
+
+// managedListener is a configured listener and its connection counters
+type managedListener struct {
+	config ListenerConfig
+
+	mu       sync.Mutex
+	listener net.Listener
+	// raw is the bound socket before PROXY/TLS wrapping, handed to a new
+	// process during an upgrade
+	raw net.Listener
+
+	accepted int64
+	active   int64
+	rejected int64
+	denied   int64
+	queue    handler.QueueMetrics
+}
+
+// AddListener registers a named listener that Start will open.
+// Names must be unique and not WebSocketListener; listeners share the
+// router, connection registry and metrics of the adapter.
+func (a *TCPAdapter) AddListener(config ListenerConfig) error {
+	if config.Name == "" {
+		return errors.New("listener name is required")
+	}
+	if config.Name == WebSocketListener {
+		return fmt.Errorf("listener name %s is reserved for the WebSocket endpoint", WebSocketListener)
+	}
+	if config.Address == "" {
+		return fmt.Errorf("listener %s: address is required", config.Name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, ml := range a.listeners {
+		if ml.config.Name == config.Name {
+			return fmt.Errorf("listener %s already exists", config.Name)
+		}
+	}
+	a.listeners = append(a.listeners, &managedListener{config: config})
+	return nil
+}
+
+// ServeListener accepts connections for the named listener on an already
+// bound net.Listener, e.g. one inherited from a parent process
+func (a *TCPAdapter) ServeListener(name string, listener net.Listener) error {
+	ml := a.findListener(name)
+	if ml == nil {
+		return fmt.Errorf("unknown listener: %s", name)
+	}
+	return a.serve(ml, listener)
+}
+
+// findListener returns the listener registered under name, or nil
+func (a *TCPAdapter) findListener(name string) *managedListener {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, ml := range a.listeners {
+		if ml.config.Name == name {
+			return ml
+		}
+	}
+	return nil
+}
+
+// defaultListener returns the default listener, registering it from the
+// adapter's host and port if needed
+func (a *TCPAdapter) defaultListener() *managedListener {
+	if ml := a.findListener(DefaultListenerName); ml != nil {
+		return ml
+	}
+	a.AddListener(ListenerConfig{
+		Name:    DefaultListenerName,
+		Address: fmt.Sprintf("%s:%d", a.host, a.port),
+	})
+	return a.findListener(DefaultListenerName)
+}
+
+// serve runs the accept loop of ml on raw until the listener is closed
+func (a *TCPAdapter) serve(ml *managedListener, raw net.Listener) error {
+	listener := a.wrapListener(raw)
+	if ml.config.TLSConfig != nil {
+		listener = tls.NewListener(listener, ml.config.TLSConfig)
+	}
+
+	ml.mu.Lock()
+	ml.listener = listener
+	ml.raw = raw
+	ml.mu.Unlock()
+
+	scheme := "tcp"
+	if ml.config.TLSConfig != nil {
+		scheme = "tls"
+	}
+	log.Printf("Listener %s accepting %s connections on %s", ml.config.Name, scheme, listener.Addr())
+
+	// Accept connections
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("Error accepting connection on %s: %v", ml.config.Name, err)
+			continue
+		}
+
+		// The slot is taken before the goroutine starts, so a burst of
+		// connections cannot overshoot MaxConnections, and the connection
+		// is registered before Drain can start waiting
+		if refused := a.admit(conn, ml); refused != "" {
+			go rejectConnection(conn, protocol.CodeUnavailable, refused)
+			continue
+		}
+
+		// Handle each connection in a goroutine (concurrent handling)
+		go a.handleConnection(conn, ml)
+	}
+}
+
+// listenerOptions derives the handler options for connections on a listener
+func (a *TCPAdapter) listenerOptions(ml *managedListener) handler.Options {
+	config := ml.config
+	opts := a.options
+	opts.Listener = config.Name
+	opts.QueueMetrics = &ml.queue
+	if config.MaxMalformedFrames != 0 {
+		opts.MaxMalformedFrames = config.MaxMalformedFrames
+	}
+
+	opts.Interceptors = nil
+	if a.acl != nil {
+		opts.Interceptors = append(opts.Interceptors, a.acl.Interceptor())
+	}
+	if len(config.Commands) > 0 {
+		opts.Interceptors = append(opts.Interceptors, handler.AllowCommands(config.Commands))
+	}
+	if config.RequireAuth {
+		opts.Interceptors = append(opts.Interceptors, handler.RequireAuth())
+	}
+	return opts
+}
+
+// admit counts conn as active on ml and registers it, so Drain waits for
+// it. Otherwise it returns why conn is refused: ml is full or the adapter
+// is draining.
+func (a *TCPAdapter) admit(conn net.Conn, ml *managedListener) (refused string) {
+	if !ml.reserve() {
+		return "Too many connections"
+	}
+	if !a.conns.add(conn, ml.config.Name) {
+		atomic.AddInt64(&ml.active, -1)
+		return "Server is shutting down"
+	}
+	return ""
+}
+
+// reserve counts a new connection as active unless that would exceed
+// MaxConnections, in which case it is counted as rejected
+func (ml *managedListener) reserve() bool {
+	active := atomic.AddInt64(&ml.active, 1)
+	if max := ml.config.MaxConnections; max > 0 && active > int64(max) {
+		atomic.AddInt64(&ml.active, -1)
+		atomic.AddInt64(&ml.rejected, 1)
+		return false
+	}
+	return true
+}
+
+// close stops accepting on the listener
+func (ml *managedListener) close() error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if ml.listener == nil {
+		return nil
+	}
+	return ml.listener.Close()
+}
+
+// address returns the bound address, or the configured one before binding
+func (ml *managedListener) address() string {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if ml.listener != nil {
+		return ml.listener.Addr().String()
+	}
+	return ml.config.Address
+}
+
+// rejectConnection tells the client why it is refused and closes it
+func rejectConnection(conn net.Conn, code protocol.ErrorCode, reason string) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte(protocol.ErrorMessage(code, reason).Encode()))
+}
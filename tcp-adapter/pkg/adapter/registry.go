@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"tcp-adapter/pkg/handler"
+)
+
+// connRegistry tracks open connections across all listeners
+type connRegistry struct {
+	mu    sync.Mutex
+	conns map[net.Conn]string
+	wg    sync.WaitGroup
+	// closed refuses new connections once Drain waits for the open ones
+	closed bool
+}
+
+func newConnRegistry() *connRegistry {
+	return &connRegistry{
+		conns: make(map[net.Conn]string),
+	}
+}
+
+// add records conn as accepted by the named listener. It reports false
+// once the registry is closed, so no connection is added while wait runs.
+func (r *connRegistry) add(conn net.Conn, listener string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.conns[conn] = listener
+	r.wg.Add(1)
+	return true
+}
+
+// remove forgets a closed connection
+func (r *connRegistry) remove(conn net.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.conns[conn]; ok {
+		delete(r.conns, conn)
+		r.wg.Done()
+	}
+}
+
+// count returns the number of open connections
+func (r *connRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns)
+}
+
+// closeAll closes every open connection
+func (r *connRegistry) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
+
+// close refuses connections added from now on
+func (r *connRegistry) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// wait blocks until every connection has been removed; call close first
+func (r *connRegistry) wait() {
+	r.wg.Wait()
+}
+
+// ListenerStats is a snapshot of one listener's connection counters
+type ListenerStats struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	TLS      bool   `json:"tls"`
+	Accepted int64  `json:"accepted"`
+	Active   int64  `json:"active"`
+	Rejected int64  `json:"rejected"`
+	// Denied counts connections refused by the ACL
+	Denied int64 `json:"denied"`
+
+	// Write queue counters summed over the listener's connections
+	QueueDepth    int64 `json:"queue_depth"`
+	QueueMaxDepth int64 `json:"queue_max_depth"`
+	Sent          int64 `json:"sent"`
+	Dropped       int64 `json:"dropped"`
+	SlowConsumers int64 `json:"slow_consumers"`
+}
+
+// Stats returns the connection counters of every listener
+func (a *TCPAdapter) Stats() []ListenerStats {
+	a.mu.Lock()
+	listeners := append([]*managedListener{}, a.listeners...)
+	if a.wsListener != nil {
+		listeners = append(listeners, a.wsListener)
+	}
+	a.mu.Unlock()
+
+	stats := make([]ListenerStats, 0, len(listeners))
+	for _, ml := range listeners {
+		queue := handler.LoadQueueMetrics(&ml.queue)
+		stats = append(stats, ListenerStats{
+			Name:     ml.config.Name,
+			Address:  ml.address(),
+			TLS:      ml.config.TLSConfig != nil,
+			Accepted: atomic.LoadInt64(&ml.accepted),
+			Active:   atomic.LoadInt64(&ml.active),
+			Rejected: atomic.LoadInt64(&ml.rejected),
+			Denied:   atomic.LoadInt64(&ml.denied),
+
+			QueueDepth:    queue.Depth,
+			QueueMaxDepth: queue.MaxDepth,
+			Sent:          queue.Sent,
+			Dropped:       queue.Dropped,
+			SlowConsumers: queue.Disconnects,
+		})
+	}
+	return stats
+}
+
+// ActiveConnections returns the number of open connections on all listeners
+func (a *TCPAdapter) ActiveConnections() int {
+	return a.conns.count()
+}
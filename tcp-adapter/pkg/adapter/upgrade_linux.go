@@ -0,0 +1,195 @@
+//go:build linux
+
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables used to hand listening sockets to a new process
+const (
+	// listenFDsEnv lists the inherited listener names in file descriptor
+	// order, starting at fd 3
+	listenFDsEnv = "TCP_ADAPTER_LISTEN_FDS"
+	// readyFDEnv is the fd the new process writes to once it is accepting
+	readyFDEnv = "TCP_ADAPTER_READY_FD"
+)
+
+// UpgradeTimeout bounds how long Upgrade waits for the new process
+const UpgradeTimeout = 30 * time.Second
+
+// Upgrade starts a new copy of the running executable with the same
+// arguments, passing every listening socket as an inherited file
+// descriptor. It returns once the new process reports that it is
+// accepting connections; the caller should then Drain and exit.
+func (a *TCPAdapter) Upgrade() error {
+	names, files, err := a.listenerFiles()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("upgrade: creating ready pipe: %w", err)
+	}
+	defer readyR.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return fmt.Errorf("upgrade: locating executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		listenFDsEnv+"="+strings.Join(names, ","),
+		fmt.Sprintf("%s=%d", readyFDEnv, 3+len(files)),
+	)
+
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		return fmt.Errorf("upgrade: starting new process: %w", err)
+	}
+	readyW.Close()
+	pid := cmd.Process.Pid
+	log.Printf("Upgrade: started new process %d, waiting for it to accept", pid)
+
+	// The pipe reports readiness with a byte, or EOF if the child exits
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyR.Read(buf)
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			cmd.Wait()
+			return fmt.Errorf("upgrade: new process exited before becoming ready")
+		}
+	case <-time.After(UpgradeTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("upgrade: new process not ready after %s", UpgradeTimeout)
+	}
+
+	// The new process is not our child to wait for any more
+	cmd.Process.Release()
+	log.Printf("Upgrade: process %d is accepting connections", pid)
+	return nil
+}
+
+// listenerFiles duplicates the file descriptor of every bound listener
+// and of the sockets bound with Listen
+func (a *TCPAdapter) listenerFiles() ([]string, []*os.File, error) {
+	listeners := a.snapshotListeners()
+	if a.wsListener != nil {
+		listeners = append(listeners, a.wsListener)
+	}
+
+	var names []string
+	var files []*os.File
+	fail := func(name string, err error) ([]string, []*os.File, error) {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, nil, fmt.Errorf("upgrade: listener %s: %w", name, err)
+	}
+	for _, ml := range listeners {
+		ml.mu.Lock()
+		raw := ml.raw
+		ml.mu.Unlock()
+		if raw == nil {
+			continue
+		}
+
+		tcpListener, ok := raw.(*net.TCPListener)
+		if !ok {
+			continue
+		}
+		f, err := tcpListener.File()
+		if err != nil {
+			return fail(ml.config.Name, err)
+		}
+		names = append(names, ml.config.Name)
+		files = append(files, f)
+	}
+
+	// Sockets bound with Listen, e.g. the cluster port
+	a.mu.Lock()
+	aux := append([]auxListener(nil), a.aux...)
+	a.mu.Unlock()
+	for _, l := range aux {
+		tcpListener, ok := l.listener.(*net.TCPListener)
+		if !ok {
+			continue
+		}
+		f, err := tcpListener.File()
+		if err != nil {
+			return fail(l.name, err)
+		}
+		names = append(names, l.name)
+		files = append(files, f)
+	}
+
+	if len(files) == 0 {
+		return nil, nil, errors.New("upgrade: no listeners to hand over")
+	}
+	return names, files, nil
+}
+
+// inheritListeners returns the listeners passed by a parent process
+// during an upgrade, keyed by listener name
+func inheritListeners() (map[string]net.Listener, error) {
+	value := os.Getenv(listenFDsEnv)
+	if value == "" {
+		return nil, nil
+	}
+	os.Unsetenv(listenFDsEnv)
+
+	inherited := make(map[string]net.Listener)
+	for i, name := range strings.Split(value, ",") {
+		f := os.NewFile(uintptr(3+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inheriting listener %s: %w", name, err)
+		}
+		inherited[name] = listener
+	}
+	return inherited, nil
+}
+
+// notifyReady tells the parent process that this process is accepting
+func notifyReady() {
+	value := os.Getenv(readyFDEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(readyFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
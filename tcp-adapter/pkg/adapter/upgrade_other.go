@@ -0,0 +1,19 @@
+//go:build !linux
+
+package adapter
+
+import (
+	"errors"
+	"net"
+)
+
+// Upgrade is only supported on Linux
+func (a *TCPAdapter) Upgrade() error {
+	return errors.New("upgrade: socket handoff is only supported on linux")
+}
+
+func inheritListeners() (map[string]net.Listener, error) {
+	return nil, nil
+}
+
+func notifyReady() {}
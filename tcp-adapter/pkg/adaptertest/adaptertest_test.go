@@ -0,0 +1,109 @@
+package adaptertest_test
+
+import (
+	"fmt"
+	"tcp-adapter/pkg/adaptertest"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"testing"
+)
+
+func hello(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	return protocol.NewMessage("HELLO_RESPONSE", "hi "+msg.Payload)
+}
+
+func TestPipeClient(t *testing.T) {
+	srv := adaptertest.NewServer(t)
+	srv.Router().Handle("HELLO", hello)
+
+	c := srv.Client()
+	if c.Welcome == nil || c.Welcome.Command != "WELCOME" {
+		t.Fatalf("welcome = %+v", c.Welcome)
+	}
+	c.Expect("HELLO:bob", "hello_response:hi bob")
+	c.ExpectError("NOPE:", protocol.CodeUnknownCommand)
+	c.Run(`
+		# built-in commands
+		PING: => PONG:alive
+		UPPER:abc => UPPER_RESPONSE:ABC
+		ECHO:sent without an expectation
+		HELLO:ann => HELLO_RESPONSE:hi ann
+	`)
+
+	// Connections are independent
+	other := srv.Client()
+	other.Expect("ECHO:x", "ECHO_RESPONSE:x")
+	c.Expect("ECHO:y", "ECHO_RESPONSE:y")
+}
+
+func TestListen(t *testing.T) {
+	srv := adaptertest.NewServer(t)
+	srv.Router().Handle("HELLO", hello)
+
+	addr := srv.Listen()
+	if again := srv.Listen(); again != addr {
+		t.Fatalf("Listen = %s, then %s", addr, again)
+	}
+	for i := 0; i < 2; i++ {
+		c := adaptertest.Dial(t, addr)
+		c.Expect(fmt.Sprintf("HELLO:%d", i), fmt.Sprintf("HELLO_RESPONSE:hi %d", i))
+	}
+	// Pipe and TCP clients share the adapter
+	srv.Client().Expect("HELLO:pipe", "HELLO_RESPONSE:hi pipe")
+}
+
+func TestWriteRaw(t *testing.T) {
+	c := adaptertest.NewServer(t).Client()
+	c.WriteRaw("no separator")
+	adaptertest.AssertError(t, c.Read(), protocol.CodeMalformedFrame)
+	c.Expect("PING:", "PONG:alive")
+}
+
+// recorder collects assertion failures instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	errorFrame := protocol.ErrorMessage(protocol.CodeConflict, "taken")
+	tests := []struct {
+		name   string
+		assert func(tb testing.TB)
+		fails  bool
+	}{
+		{"same message", func(tb testing.TB) {
+			adaptertest.AssertMessage(tb, protocol.NewMessage("PONG", "alive"), protocol.NewMessage("pong", "alive"))
+		}, false},
+		{"other payload", func(tb testing.TB) {
+			adaptertest.AssertMessage(tb, protocol.NewMessage("PONG", "alive"), protocol.NewMessage("PONG", "dead"))
+		}, true},
+		{"other command", func(tb testing.TB) {
+			adaptertest.AssertMessage(tb, protocol.NewMessage("PING", "alive"), protocol.NewMessage("PONG", "alive"))
+		}, true},
+		{"expected error", func(tb testing.TB) {
+			adaptertest.AssertError(tb, errorFrame, protocol.CodeConflict)
+		}, false},
+		{"other error code", func(tb testing.TB) {
+			adaptertest.AssertError(tb, errorFrame, protocol.CodeNotFound)
+		}, true},
+		{"not an error", func(tb testing.TB) {
+			adaptertest.AssertError(tb, protocol.NewMessage("PONG", "alive"), protocol.CodeConflict)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			tt.assert(r)
+			if failed := len(r.failures) > 0; failed != tt.fails {
+				t.Fatalf("failed = %v (%q), want %v", failed, r.failures, tt.fails)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package adaptertest
+
+import (
+	"strings"
+	"tcp-adapter/pkg/protocol"
+	"testing"
+)
+
+// ParseMessage parses s in the COMMAND:PAYLOAD format, failing the test
+// if it has no separator
+func ParseMessage(tb testing.TB, s string) *protocol.Message {
+	tb.Helper()
+	command, payload, found := strings.Cut(s, ":")
+	if !found {
+		tb.Fatalf("adaptertest: %q is not in COMMAND:PAYLOAD format", s)
+	}
+	return protocol.NewMessage(command, payload)
+}
+
+// AssertMessage fails the test unless got has want's command
+// (case-insensitive) and exactly want's payload
+func AssertMessage(tb testing.TB, got, want *protocol.Message) {
+	tb.Helper()
+	if !strings.EqualFold(got.Command, want.Command) || got.Payload != want.Payload {
+		tb.Errorf("response = %s:%s, want %s:%s", got.Command, got.Payload, want.Command, want.Payload)
+	}
+}
+
+// AssertError fails the test unless got is an error frame with code
+func AssertError(tb testing.TB, got *protocol.Message, code protocol.ErrorCode) *protocol.Error {
+	tb.Helper()
+	protoErr, ok := protocol.ParseError(got)
+	if !ok {
+		tb.Errorf("response = %s:%s, want error %d %s", got.Command, got.Payload, code, code)
+		return nil
+	}
+	if protoErr.Code != code {
+		tb.Errorf("error code = %d %s, want %d %s (message: %s)",
+			protoErr.Code, protoErr.Code, code, code, protoErr.Message)
+	}
+	return protoErr
+}
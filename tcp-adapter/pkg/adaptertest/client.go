@@ -0,0 +1,128 @@
+package adaptertest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"tcp-adapter/pkg/protocol"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout bounds every read and write made by a test Client
+const DefaultTimeout = 5 * time.Second
+
+// Client is a scripted client that fails the test on any I/O error.
+// Messages are written and expected in the line format COMMAND:PAYLOAD.
+type Client struct {
+	tb     testing.TB
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Welcome is the greeting sent by the server
+	Welcome *protocol.Message
+	// Timeout bounds each read and write
+	Timeout time.Duration
+}
+
+// NewClient wraps conn, reads the welcome message and closes the
+// connection when the test finishes
+func NewClient(tb testing.TB, conn net.Conn) *Client {
+	tb.Helper()
+	c := &Client{
+		tb:      tb,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		Timeout: DefaultTimeout,
+	}
+	tb.Cleanup(func() { conn.Close() })
+
+	c.Welcome = c.Read()
+	return c
+}
+
+// Dial connects a Client to a server listening on address
+func Dial(tb testing.TB, address string) *Client {
+	tb.Helper()
+	conn, err := net.DialTimeout("tcp", address, DefaultTimeout)
+	if err != nil {
+		tb.Fatalf("adaptertest: dial %s: %v", address, err)
+	}
+	return NewClient(tb, conn)
+}
+
+// WriteRaw writes line followed by a newline, without any validation.
+// It is used to send malformed frames.
+func (c *Client) WriteRaw(line string) {
+	c.tb.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.tb.Fatalf("adaptertest: write %q: %v", line, err)
+	}
+}
+
+// Write sends msg to the server
+func (c *Client) Write(msg *protocol.Message) {
+	c.tb.Helper()
+	c.WriteRaw(strings.TrimSuffix(msg.Encode(), "\n"))
+}
+
+// Read returns the next message from the server
+func (c *Client) Read() *protocol.Message {
+	c.tb.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	msg, err := protocol.Decode(c.reader)
+	if err != nil {
+		c.tb.Fatalf("adaptertest: read: %v", err)
+	}
+	return msg
+}
+
+// Send writes a request given as COMMAND:PAYLOAD and returns the response
+func (c *Client) Send(request string) *protocol.Message {
+	c.tb.Helper()
+	c.Write(ParseMessage(c.tb, request))
+	return c.Read()
+}
+
+// Expect sends request and asserts the response, both as COMMAND:PAYLOAD.
+// The response command is compared case-insensitively.
+func (c *Client) Expect(request, want string) *protocol.Message {
+	c.tb.Helper()
+	got := c.Send(request)
+	AssertMessage(c.tb, got, ParseMessage(c.tb, want))
+	return got
+}
+
+// ExpectError sends request and asserts an error frame with code
+func (c *Client) ExpectError(request string, code protocol.ErrorCode) *protocol.Error {
+	c.tb.Helper()
+	return AssertError(c.tb, c.Send(request), code)
+}
+
+// Run executes a script with one exchange per line in the form
+//
+//	REQUEST => RESPONSE
+//
+// where both sides use the COMMAND:PAYLOAD format. Blank lines and lines
+// starting with '#' are ignored; a line without "=>" only sends.
+func (c *Client) Run(script string) {
+	c.tb.Helper()
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		request, want, found := strings.Cut(line, "=>")
+		if !found {
+			c.Send(strings.TrimSpace(request))
+			continue
+		}
+		c.Expect(strings.TrimSpace(request), strings.TrimSpace(want))
+	}
+}
+
+// Close closes the connection
+func (c *Client) Close() {
+	c.conn.Close()
+}
@@ -0,0 +1,117 @@
+// Package adaptertest provides utilities for testing code built on the TCP
+// adapter: an in-process server reachable over net.Pipe or an ephemeral
+// port, a scripted client and assertions on request/response exchanges.
+//
+// A typical test registers its command on the server's router and checks
+// the exchange without opening a real socket:
+//
+//	srv := adaptertest.NewServer(t)
+//	srv.Router().Handle("HELLO", helloCommand)
+//
+//	c := srv.Client()
+//	c.Expect("HELLO:bob", "HELLO_RESPONSE:hi bob")
+//	c.ExpectError("NOPE:", protocol.CodeUnknownCommand)
+package adaptertest
+
+import (
+	"net"
+	"sync"
+	"tcp-adapter/pkg/adapter"
+	"tcp-adapter/pkg/handler"
+	"testing"
+)
+
+// Server is an in-process adapter for tests. It is closed automatically
+// when the test finishes.
+type Server struct {
+	// Adapter is the adapter serving connections; configure it before
+	// creating clients
+	Adapter *adapter.TCPAdapter
+
+	tb       testing.TB
+	mu       sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a test server with the built-in commands registered
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+	s := &Server{
+		Adapter: adapter.NewTCPAdapter("127.0.0.1", 0),
+		tb:      tb,
+	}
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Router returns the adapter's command router
+func (s *Server) Router() *handler.Router {
+	return s.Adapter.Router()
+}
+
+// Pipe returns the client end of an in-memory connection served by the
+// adapter. No network socket is involved.
+func (s *Server) Pipe() net.Conn {
+	serverConn, clientConn := net.Pipe()
+
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn, clientConn)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.Adapter.ServeConn(serverConn)
+	}()
+	return clientConn
+}
+
+// Listen starts serving on an ephemeral loopback port and returns its
+// address. Repeated calls return the same address.
+func (s *Server) Listen() string {
+	s.tb.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.tb.Fatalf("adaptertest: failed to listen: %v", err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.Adapter.Serve(listener)
+	}()
+	return listener.Addr().String()
+}
+
+// Client connects a scripted client over net.Pipe and consumes the
+// welcome message
+func (s *Server) Client() *Client {
+	s.tb.Helper()
+	return NewClient(s.tb, s.Pipe())
+}
+
+// Close stops the listener and closes every connection
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+
+	s.Adapter.Stop()
+	s.wg.Wait()
+}
@@ -0,0 +1,258 @@
+// Package client is a minimal library for talking to a TCP adapter server
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/validate"
+	"time"
+)
+
+// Client is a connection to a TCP adapter server.
+// Requests are sent one at a time; Send is safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	mu     sync.Mutex
+
+	timeout time.Duration
+
+	// Welcome is the greeting sent by the server after connecting
+	Welcome *protocol.Message
+
+	// onMessage receives pub/sub messages pushed by the server
+	onMessage func(msg *protocol.Message)
+	lastSeq   uint64
+
+	// signKey and signSecret sign every request when set
+	signKey    string
+	signSecret []byte
+}
+
+// Dial connects to the server at address and reads its welcome message
+func Dial(address string) (*Client, error) {
+	return DialTimeout(address, 0)
+}
+
+// DialTimeout is like Dial but fails if connecting takes longer than timeout
+func DialTimeout(address string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient wraps an established connection and reads the welcome message
+func NewClient(conn net.Conn) (*Client, error) {
+	c := &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+
+	welcome, err := protocol.Decode(c.reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading welcome message: %w", err)
+	}
+	c.Welcome = welcome
+	return c, nil
+}
+
+// Send writes msg and waits for the server's response. With a signer
+// set, msg is signed first, with a fresh nonce on every call.
+func (c *Client) Send(msg *protocol.Message) (*protocol.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if c.signSecret != nil {
+		protocol.Sign(msg, c.signKey, c.signSecret, time.Now())
+	}
+
+	if _, err := c.writer.WriteString(msg.Encode()); err != nil {
+		return nil, fmt.Errorf("error sending message: %w", err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("error sending message: %w", err)
+	}
+
+	for {
+		response, err := protocol.Decode(c.reader)
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+		if !c.pushed(response) {
+			return response, nil
+		}
+	}
+}
+
+// pushed records a message the server sent on its own and hands it to
+// the message handler; it reports false for responses
+func (c *Client) pushed(msg *protocol.Message) bool {
+	if msg.Command != protocol.MessageCommand {
+		return false
+	}
+	if seq, err := strconv.ParseUint(msg.Header(protocol.HeaderSeq), 10, 64); err == nil && seq > c.lastSeq {
+		c.lastSeq = seq
+	}
+	if c.onMessage != nil {
+		c.onMessage(msg)
+	}
+	return true
+}
+
+// OnMessage sets the function called for pub/sub messages. They are read
+// while waiting for responses and by Receive.
+func (c *Client) OnMessage(fn func(msg *protocol.Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onMessage = fn
+}
+
+// Receive waits up to timeout for the next pushed message and passes it
+// to the OnMessage handler. It returns the message, or an error on timeout.
+func (c *Client) Receive(timeout time.Duration) (*protocol.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		msg, err := protocol.Decode(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		if c.pushed(msg) {
+			return msg, nil
+		}
+	}
+}
+
+// Session returns the session token from the welcome message, or "" if
+// the server does not support resumption
+func (c *Client) Session() string {
+	return c.Welcome.Header(protocol.HeaderSession)
+}
+
+// LastSeq returns the sequence number of the last pushed message received
+func (c *Client) LastSeq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+// Resume takes over the session token (from a previous connection's
+// Session) and asks for every message after lastSeq. Replayed messages
+// follow the response and reach the OnMessage handler.
+func (c *Client) Resume(token string, lastSeq uint64) error {
+	response, err := c.Do("RESUME", token+":"+strconv.FormatUint(lastSeq, 10))
+	if err != nil {
+		return err
+	}
+	if perr, ok := protocol.ParseError(response); ok {
+		return perr
+	}
+	c.mu.Lock()
+	c.lastSeq = lastSeq
+	c.mu.Unlock()
+	return nil
+}
+
+// Do sends a command with payload and returns the response
+func (c *Client) Do(command, payload string) (*protocol.Message, error) {
+	return c.Send(protocol.NewMessage(command, payload))
+}
+
+// ResponseError is returned by DoJSON when the server answers with an
+// error frame. Fields lists invalid request fields for BAD_REQUEST.
+type ResponseError struct {
+	Err    *protocol.Error
+	Fields validate.Errors
+}
+
+// Error implements the error interface
+func (e *ResponseError) Error() string {
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("%d %s: %s", e.Err.Code, e.Err.Code, e.Fields)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying protocol error
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// DoJSON sends req encoded as JSON and decodes the response payload into
+// resp, which may be nil. Error frames are returned as *ResponseError.
+func (c *Client) DoJSON(command string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding %s request: %w", command, err)
+	}
+	msg := protocol.NewMessage(command, string(body))
+	msg.SetHeader(protocol.HeaderContentType, protocol.ContentTypeJSON)
+
+	response, err := c.Send(msg)
+	if err != nil {
+		return err
+	}
+	if perr, ok := protocol.ParseError(response); ok {
+		rerr := &ResponseError{Err: perr}
+		if fields := response.Header(protocol.HeaderFields); fields != "" {
+			json.Unmarshal([]byte(fields), &rerr.Fields)
+		}
+		return rerr
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(response.Payload), resp); err != nil {
+		return fmt.Errorf("decoding %s response: %w", command, err)
+	}
+	return nil
+}
+
+// SetSigner signs all following requests with the secret of keyID. Call
+// it again with the new key to rotate; an empty keyID stops signing.
+func (c *Client) SetSigner(keyID string, secret []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.signKey = keyID
+	c.signSecret = nil
+	if keyID != "" {
+		c.signSecret = secret
+	}
+}
+
+// SetTimeout bounds how long each request may take; zero disables the limit
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.timeout = timeout
+	if timeout == 0 {
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
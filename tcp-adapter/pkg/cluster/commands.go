@@ -0,0 +1,34 @@
+package cluster
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/pubsub"
+)
+
+// Register adds the cluster commands to router:
+//
+//	MEMBERS            -> MEMBERS:<JSON list of members>
+//	PRESENCE:<topic>   -> PRESENCE:<subscribers on all nodes>
+func Register(router *handler.Router, node *Node) {
+	router.Handle("MEMBERS", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		body, err := json.Marshal(node.Members())
+		if err != nil {
+			return protocol.ErrorMessage(protocol.CodeInternal, "Failed to encode members")
+		}
+		response := protocol.NewMessage("MEMBERS", string(body))
+		response.SetHeader(protocol.HeaderContentType, protocol.ContentTypeJSON)
+		return response
+	})
+
+	router.Handle("PRESENCE", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		topic := strings.TrimSpace(msg.Payload)
+		if err := pubsub.ValidTopic(topic); err != nil {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, err.Error())
+		}
+		return protocol.NewMessage("PRESENCE", strconv.Itoa(node.Presence(topic)))
+	})
+}
@@ -0,0 +1,122 @@
+// Package cluster connects several adapter processes so that pub/sub
+// works across them: a message published on one node reaches the
+// subscribers on every node.
+//
+// Nodes listen on a separate cluster address and dial a static list of
+// peers. Every node gossips its member table (heartbeats and the
+// subscriber count of each topic) to all members it knows about, which
+// also introduces nodes that are not in each other's peer lists.
+// Members whose heartbeat stops advancing become suspect and then dead.
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Defaults for Config
+const (
+	DefaultGossipInterval = time.Second
+	DefaultSuspectAfter   = 3 * time.Second
+	DefaultDeadAfter      = 10 * time.Second
+	DefaultLinkQueueSize  = 1024
+)
+
+// Config configures a cluster node
+type Config struct {
+	// NodeID names the node; defaults to Advertise
+	NodeID string
+	// Addr is the address the node accepts peer connections on
+	Addr string
+	// Listener, if set, is already bound to Addr, e.g. a socket handed
+	// over by a previous process during an upgrade. Start binds Addr
+	// otherwise. The node closes it on Stop.
+	Listener net.Listener
+	// Advertise is the address other nodes should dial, for when Addr
+	// is not reachable as written (e.g. ":7000"); defaults to Addr
+	Advertise string
+	// Peers are the cluster addresses of other nodes. A node's own
+	// address may be included, so every node can share one list.
+	Peers []string
+	// Secret, when set, signs all cluster traffic so that only nodes
+	// knowing it can join or publish
+	Secret []byte
+	// AllowedPeers, when set, limits peer connections to these networks.
+	// A node whose Addr is not a loopback address needs a Secret or
+	// AllowedPeers, or anyone could inject messages.
+	AllowedPeers []*net.IPNet
+
+	GossipInterval time.Duration
+	// SuspectAfter and DeadAfter are how long a member's heartbeat may
+	// stall before it is considered suspect or dead. Publications are
+	// not forwarded to dead members.
+	SuspectAfter time.Duration
+	DeadAfter    time.Duration
+	// LinkQueueSize is how many frames may wait for each peer before
+	// new ones are dropped
+	LinkQueueSize int
+}
+
+// normalize validates config and fills in defaults
+func (c *Config) normalize() error {
+	if c.Addr == "" {
+		return fmt.Errorf("cluster: address is required")
+	}
+	if len(c.Secret) == 0 && len(c.AllowedPeers) == 0 && !isLoopback(c.Addr) {
+		return fmt.Errorf("cluster: %s accepts connections from other hosts; a secret or allowed peer networks are required", c.Addr)
+	}
+	if c.Advertise == "" {
+		c.Advertise = c.Addr
+	}
+	if c.NodeID == "" {
+		c.NodeID = c.Advertise
+	}
+	if c.GossipInterval <= 0 {
+		c.GossipInterval = DefaultGossipInterval
+	}
+	if c.SuspectAfter <= 0 {
+		c.SuspectAfter = DefaultSuspectAfter
+	}
+	if c.DeadAfter <= 0 {
+		c.DeadAfter = DefaultDeadAfter
+	}
+	if c.DeadAfter < c.SuspectAfter {
+		return fmt.Errorf("cluster: dead timeout %s is shorter than suspect timeout %s", c.DeadAfter, c.SuspectAfter)
+	}
+	if c.LinkQueueSize <= 0 {
+		c.LinkQueueSize = DefaultLinkQueueSize
+	}
+	return nil
+}
+
+// isLoopback reports whether addr can only be reached from this host
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// allows reports whether a peer connecting from addr may be served
+func (c *Config) allows(addr net.Addr) bool {
+	if len(c.AllowedPeers) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range c.AllowedPeers {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package cluster
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"loopback", Config{Addr: "127.0.0.1:7000"}, ""},
+		{"localhost", Config{Addr: "LocalHost:7000"}, ""},
+		{"IPv6 loopback", Config{Addr: "[::1]:7000"}, ""},
+		{"missing address", Config{}, "address is required"},
+		{"all interfaces without protection", Config{Addr: ":7000"}, "a secret or allowed peer networks are required"},
+		{"public address without protection", Config{Addr: "192.0.2.1:7000"}, "a secret or allowed peer networks are required"},
+		{"public address with a secret", Config{Addr: ":7000", Secret: []byte("s3cret")}, ""},
+		{"public address with allowed peers", Config{Addr: ":7000", AllowedPeers: []*net.IPNet{private}}, ""},
+		{"dead before suspect", Config{Addr: "127.0.0.1:7000", SuspectAfter: time.Minute, DeadAfter: time.Second}, "shorter than suspect timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.normalize()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("normalize: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("normalize = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	c := Config{Addr: "127.0.0.1:7000"}
+	if err := c.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Advertise != c.Addr || c.NodeID != c.Addr || c.GossipInterval != DefaultGossipInterval ||
+		c.SuspectAfter != DefaultSuspectAfter || c.DeadAfter != DefaultDeadAfter || c.LinkQueueSize != DefaultLinkQueueSize {
+		t.Errorf("defaults = %+v", c)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		allowed []*net.IPNet
+		addr    net.Addr
+		want    bool
+	}{
+		{"no list", nil, &net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, true},
+		{"allowed network", []*net.IPNet{private}, &net.TCPAddr{IP: net.ParseIP("10.1.2.3")}, true},
+		{"other network", []*net.IPNet{private}, &net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, false},
+		{"not TCP", []*net.IPNet{private}, &net.UnixAddr{Name: "/tmp/peer"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{AllowedPeers: tt.allowed}
+			if got := c.allows(tt.addr); got != tt.want {
+				t.Errorf("allows(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeouts and backoff of peer links
+const (
+	dialTimeout  = 2 * time.Second
+	writeTimeout = 5 * time.Second
+	minBackoff   = 100 * time.Millisecond
+	maxBackoff   = 5 * time.Second
+)
+
+// link is the outgoing connection to one peer. Frames are queued and
+// written by its own goroutine, which redials after failures, so a slow
+// or missing peer never blocks publishing.
+type link struct {
+	addr  string
+	hello func() string
+	// static links come from the configured peer list and are kept even
+	// while the peer is dead
+	static bool
+
+	queue     chan string
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	dropped int
+}
+
+func newLink(addr string, static bool, queueSize int, hello func() string) *link {
+	return &link{
+		addr:   addr,
+		hello:  hello,
+		static: static,
+		queue:  make(chan string, queueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// send queues frame, dropping it if the queue is full
+func (l *link) send(frame string) {
+	select {
+	case l.queue <- frame:
+	default:
+		l.mu.Lock()
+		l.dropped++
+		if l.dropped == 1 || l.dropped%1000 == 0 {
+			log.Printf("Cluster link to %s is backed up, %d frame(s) dropped", l.addr, l.dropped)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// close stops the link
+func (l *link) close() {
+	l.closeOnce.Do(func() { close(l.done) })
+}
+
+// run connects to the peer and writes queued frames until closed
+func (l *link) run() {
+	backoff := minBackoff
+	for {
+		conn, err := net.DialTimeout("tcp", l.addr, dialTimeout)
+		if err == nil {
+			log.Printf("Cluster link to %s connected", l.addr)
+			connected := time.Now()
+			err = l.write(conn)
+			conn.Close()
+			if err == nil {
+				return
+			}
+			log.Printf("Cluster link to %s lost: %v", l.addr, err)
+			// Only a connection that lasted resets the backoff, so a peer
+			// that drops us right away is not redialed in a tight loop
+			if time.Since(connected) > maxBackoff {
+				backoff = minBackoff
+			}
+		}
+		select {
+		case <-l.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// write sends HELLO and then queued frames over conn. It returns nil
+// once the link is closed.
+func (l *link) write(conn net.Conn) error {
+	writer := bufio.NewWriter(conn)
+	frame := l.hello()
+	for {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := writer.WriteString(frame); err != nil {
+			return err
+		}
+		// Batch frames that are already waiting into one write
+		if len(l.queue) == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-l.done:
+			writer.Flush()
+			return nil
+		case frame = <-l.queue:
+		}
+	}
+}
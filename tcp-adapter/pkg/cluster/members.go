@@ -0,0 +1,80 @@
+package cluster
+
+import "time"
+
+// Status is the health of a member as seen by the local node
+type Status int
+
+const (
+	Alive Status = iota
+	Suspect
+	Dead
+)
+
+// String returns alive, suspect or dead
+func (s Status) String() string {
+	switch s {
+	case Suspect:
+		return "suspect"
+	case Dead:
+		return "dead"
+	}
+	return "alive"
+}
+
+// Member is a node as described in gossip
+type Member struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+	// Incarnation changes when the node restarts, so its heartbeat
+	// counting from zero again is not mistaken for old news
+	Incarnation int64  `json:"incarnation"`
+	Heartbeat   uint64 `json:"heartbeat"`
+	// Topics is the number of local subscribers of each topic
+	Topics map[string]int `json:"topics,omitempty"`
+}
+
+// newer reports whether m is a later state of the same member than old
+func (m Member) newer(old Member) bool {
+	if m.Incarnation != old.Incarnation {
+		return m.Incarnation > old.Incarnation
+	}
+	return m.Heartbeat > old.Heartbeat
+}
+
+// MemberInfo is a member with its status, as returned by Node.Members
+type MemberInfo struct {
+	ID          string         `json:"id"`
+	Addr        string         `json:"addr"`
+	Status      string         `json:"status"`
+	Self        bool           `json:"self,omitempty"`
+	Subscribers int            `json:"subscribers"`
+	Topics      map[string]int `json:"topics,omitempty"`
+}
+
+// memberState is a remote member and when its heartbeat last advanced
+type memberState struct {
+	Member
+	lastSeen time.Time
+}
+
+// status derives the member's health from its last heartbeat
+func (m *memberState) status(now time.Time, config Config) Status {
+	silent := now.Sub(m.lastSeen)
+	switch {
+	case silent > config.DeadAfter:
+		return Dead
+	case silent > config.SuspectAfter:
+		return Suspect
+	}
+	return Alive
+}
+
+// subscribers returns the total number of subscribers in topics
+func subscribers(topics map[string]int) int {
+	n := 0
+	for _, count := range topics {
+		n += count
+	}
+	return n
+}
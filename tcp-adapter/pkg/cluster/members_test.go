@@ -0,0 +1,49 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemberStatus(t *testing.T) {
+	config := Config{SuspectAfter: 3 * time.Second, DeadAfter: 10 * time.Second}
+	now := time.Now()
+	tests := []struct {
+		silent time.Duration
+		want   Status
+	}{
+		{0, Alive},
+		{3 * time.Second, Alive},
+		{4 * time.Second, Suspect},
+		{10 * time.Second, Suspect},
+		{11 * time.Second, Dead},
+	}
+	for _, tt := range tests {
+		m := &memberState{lastSeen: now.Add(-tt.silent)}
+		if got := m.status(now, config); got != tt.want {
+			t.Errorf("silent for %s: status %s, want %s", tt.silent, got, tt.want)
+		}
+	}
+}
+
+func TestMemberNewer(t *testing.T) {
+	old := Member{Incarnation: 2, Heartbeat: 10}
+	tests := []struct {
+		name string
+		m    Member
+		want bool
+	}{
+		{"later heartbeat", Member{Incarnation: 2, Heartbeat: 11}, true},
+		{"same heartbeat", Member{Incarnation: 2, Heartbeat: 10}, false},
+		{"earlier heartbeat", Member{Incarnation: 2, Heartbeat: 9}, false},
+		{"restarted", Member{Incarnation: 3, Heartbeat: 1}, true},
+		{"previous incarnation", Member{Incarnation: 1, Heartbeat: 99}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.newer(old); got != tt.want {
+				t.Errorf("newer = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/pubsub"
+	"tcp-adapter/pkg/signing"
+	"time"
+)
+
+// Commands spoken between nodes
+const (
+	helloCommand   = "HELLO"
+	gossipCommand  = "GOSSIP"
+	forwardCommand = "FORWARD"
+)
+
+// Cluster traffic headers
+const (
+	headerFrom = "from"
+	headerAddr = "addr"
+)
+
+// signingKeyID names the shared secret in signed cluster frames
+const signingKeyID = "cluster"
+
+// signingSkew is the clock difference accepted between nodes
+const signingSkew = 30 * time.Second
+
+// Node is the local member of a cluster. It forwards the publications of
+// its broker to the other members and delivers theirs locally.
+type Node struct {
+	config      Config
+	broker      *pubsub.Broker
+	incarnation int64
+	verifier    *signing.Verifier
+
+	mu        sync.Mutex
+	heartbeat uint64
+	members   map[string]*memberState
+	links     map[string]*link
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a node that forwards the publications of broker
+func New(config Config, broker *pubsub.Broker) (*Node, error) {
+	if err := config.normalize(); err != nil {
+		return nil, err
+	}
+	n := &Node{
+		config:      config,
+		broker:      broker,
+		incarnation: time.Now().UnixNano(),
+		members:     make(map[string]*memberState),
+		links:       make(map[string]*link),
+		conns:       make(map[net.Conn]struct{}),
+		done:        make(chan struct{}),
+	}
+	if len(config.Secret) > 0 {
+		n.verifier = signing.NewVerifier(signing.Config{
+			Keys:     signing.NewKeyringFromMap(map[string][]byte{signingKeyID: config.Secret}),
+			Required: []string{"*"},
+			MaxSkew:  signingSkew,
+		})
+	}
+	return n, nil
+}
+
+// ID returns the node's ID
+func (n *Node) ID() string {
+	return n.config.NodeID
+}
+
+// Start listens for peers, connects to the configured ones and starts
+// gossiping. From then on the broker's publications are forwarded.
+func (n *Node) Start() error {
+	listener := n.config.Listener
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", n.config.Addr); err != nil {
+			return fmt.Errorf("cluster: failed to listen on %s: %w", n.config.Addr, err)
+		}
+	}
+	n.mu.Lock()
+	n.listener = listener
+	for _, addr := range n.config.Peers {
+		addr = strings.TrimSpace(addr)
+		if addr != "" && addr != n.config.Addr && addr != n.config.Advertise {
+			n.addLinkLocked(addr, true)
+		}
+	}
+	n.mu.Unlock()
+
+	n.wg.Add(2)
+	go n.accept()
+	go n.gossipLoop()
+	n.broker.SetForwarder(n)
+	log.Printf("Cluster node %s listening on %s with %d peer(s)", n.config.NodeID, listener.Addr(), len(n.links))
+	return nil
+}
+
+// Stop leaves the cluster: publications stay local again and all peer
+// connections are closed
+func (n *Node) Stop() {
+	n.broker.SetForwarder(nil)
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.done)
+	if n.listener != nil {
+		n.listener.Close()
+	}
+	for conn := range n.conns {
+		conn.Close()
+	}
+	for _, l := range n.links {
+		l.close()
+	}
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// addLinkLocked starts a link to addr unless there is one
+func (n *Node) addLinkLocked(addr string, static bool) {
+	if _, ok := n.links[addr]; ok || n.closed {
+		return
+	}
+	l := newLink(addr, static, n.config.LinkQueueSize, func() string {
+		hello := protocol.NewMessage(helloCommand, n.config.NodeID)
+		hello.SetHeader(headerAddr, n.config.Advertise)
+		return n.frame(hello)
+	})
+	n.links[addr] = l
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		l.run()
+	}()
+}
+
+// frame encodes msg as sent by this node, signed if a secret is set
+func (n *Node) frame(msg *protocol.Message) string {
+	msg.SetHeader(headerFrom, n.config.NodeID)
+	if n.verifier != nil {
+		protocol.Sign(msg, signingKeyID, n.config.Secret, time.Now())
+	}
+	return msg.Encode()
+}
+
+// accept serves incoming peer connections
+func (n *Node) accept() {
+	defer n.wg.Done()
+	for {
+		conn, err := n.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Cluster accept error: %v", err)
+			continue
+		}
+		if !n.config.allows(conn.RemoteAddr()) {
+			log.Printf("Cluster connection from %s refused: not an allowed peer network", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		n.mu.Lock()
+		if n.closed {
+			n.mu.Unlock()
+			conn.Close()
+			return
+		}
+		n.conns[conn] = struct{}{}
+		n.wg.Add(1)
+		n.mu.Unlock()
+		go n.serve(conn)
+	}
+}
+
+// serve reads the frames a peer sends over conn
+func (n *Node) serve(conn net.Conn) {
+	defer n.wg.Done()
+	defer func() {
+		n.mu.Lock()
+		delete(n.conns, conn)
+		n.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := protocol.Decode(reader)
+		if err != nil {
+			if !errors.Is(err, protocol.ErrConnectionClosed) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Cluster connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if n.verifier != nil {
+			if perr := n.verifier.Verify(msg); perr != nil {
+				log.Printf("Cluster frame from %s rejected: %s", conn.RemoteAddr(), perr.Message)
+				return
+			}
+		}
+		if msg.Header(headerFrom) == n.config.NodeID {
+			// A peer address that turned out to be our own
+			return
+		}
+
+		switch msg.Command {
+		case helloCommand:
+			log.Printf("Cluster peer %s connected from %s", msg.Payload, conn.RemoteAddr())
+		case gossipCommand:
+			var members []Member
+			if err := json.Unmarshal([]byte(msg.Payload), &members); err != nil {
+				log.Printf("Invalid gossip from %s: %v", conn.RemoteAddr(), err)
+				continue
+			}
+			n.merge(members)
+		case forwardCommand:
+			if topic, payload, ok := strings.Cut(msg.Payload, ":"); ok {
+				n.broker.Deliver(topic, payload)
+			}
+		}
+	}
+}
+
+// gossipLoop advances the heartbeat and sends the member table to all
+// peers every interval
+func (n *Node) gossipLoop() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.config.GossipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+			n.gossip()
+		}
+	}
+}
+
+// gossip sends one round of gossip
+func (n *Node) gossip() {
+	topics := n.broker.Topics()
+	now := time.Now()
+
+	n.mu.Lock()
+	n.heartbeat++
+	members := []Member{{
+		ID:          n.config.NodeID,
+		Addr:        n.config.Advertise,
+		Incarnation: n.incarnation,
+		Heartbeat:   n.heartbeat,
+		Topics:      topics,
+	}}
+	for id, m := range n.members {
+		switch m.status(now, n.config) {
+		case Dead:
+			if l, ok := n.links[m.Addr]; ok && !l.static {
+				l.close()
+				delete(n.links, m.Addr)
+			}
+			// Forget dead members after a while; if they come back,
+			// gossip introduces them again
+			if now.Sub(m.lastSeen) > 2*n.config.DeadAfter {
+				log.Printf("Cluster member %s removed", id)
+				delete(n.members, id)
+			}
+		default:
+			members = append(members, m.Member)
+		}
+	}
+	links := make([]*link, 0, len(n.links))
+	for _, l := range n.links {
+		links = append(links, l)
+	}
+	n.mu.Unlock()
+
+	payload, err := json.Marshal(members)
+	if err != nil {
+		log.Printf("Cluster gossip encoding failed: %v", err)
+		return
+	}
+	frame := n.frame(protocol.NewMessage(gossipCommand, string(payload)))
+	for _, l := range links {
+		l.send(frame)
+	}
+}
+
+// merge applies received gossip to the member table
+func (n *Node) merge(members []Member) {
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, m := range members {
+		if m.ID == "" || m.ID == n.config.NodeID {
+			continue
+		}
+		existing, ok := n.members[m.ID]
+		if ok && !m.newer(existing.Member) {
+			continue
+		}
+		if !ok || existing.status(now, n.config) == Dead {
+			log.Printf("Cluster member %s (%s) is alive", m.ID, m.Addr)
+		}
+		n.members[m.ID] = &memberState{Member: m, lastSeen: now}
+		if m.Addr != "" && m.Addr != n.config.Advertise {
+			n.addLinkLocked(m.Addr, false)
+		}
+	}
+}
+
+// Forward sends a publication to every live member and returns how many
+// subscribers they last reported for topic. It implements
+// pubsub.Forwarder.
+func (n *Node) Forward(topic, payload string) int {
+	frame := n.frame(protocol.NewMessage(forwardCommand, topic+":"+payload))
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	count := 0
+	for _, m := range n.members {
+		if m.status(now, n.config) == Dead {
+			continue
+		}
+		// Members are sent every publication, not only those they
+		// reported subscribers for, since presence lags behind by up to
+		// one gossip interval
+		if l, ok := n.links[m.Addr]; ok {
+			l.send(frame)
+			count += m.Topics[topic]
+		}
+	}
+	return count
+}
+
+// Members returns the local node and every known member, sorted by ID
+func (n *Node) Members() []MemberInfo {
+	topics := n.broker.Topics()
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	infos := []MemberInfo{{
+		ID:          n.config.NodeID,
+		Addr:        n.config.Advertise,
+		Status:      Alive.String(),
+		Self:        true,
+		Subscribers: subscribers(topics),
+		Topics:      topics,
+	}}
+	for _, m := range n.members {
+		infos = append(infos, MemberInfo{
+			ID:          m.ID,
+			Addr:        m.Addr,
+			Status:      m.status(now, n.config).String(),
+			Subscribers: subscribers(m.Topics),
+			Topics:      m.Topics,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Presence returns the number of subscribers of topic across all live
+// members, including the local node
+func (n *Node) Presence(topic string) int {
+	count := n.broker.Topics()[topic]
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, m := range n.members {
+		if m.status(now, n.config) != Dead {
+			count += m.Topics[topic]
+		}
+	}
+	return count
+}
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/pubsub"
+)
+
+// inbox is a subscriber collecting the payloads delivered to it
+type inbox chan string
+
+func (in inbox) Deliver(msg *protocol.Message) { in <- msg.Payload }
+
+// receive waits for the next payload, or returns "" after a timeout
+func (in inbox) receive(timeout time.Duration) string {
+	select {
+	case payload := <-in:
+		return payload
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+type testNode struct {
+	*Node
+	broker *pubsub.Broker
+	addr   string
+}
+
+// startNode starts a node with fast gossip on a loopback port
+func startNode(t *testing.T, id, secret string, peers ...string) *testNode {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := pubsub.NewBroker()
+	n, err := New(Config{
+		NodeID:         id,
+		Addr:           listener.Addr().String(),
+		Listener:       listener,
+		Peers:          peers,
+		Secret:         []byte(secret),
+		GossipInterval: 20 * time.Millisecond,
+		SuspectAfter:   200 * time.Millisecond,
+		DeadAfter:      400 * time.Millisecond,
+	}, broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(n.Stop)
+	return &testNode{Node: n, broker: broker, addr: listener.Addr().String()}
+}
+
+// status returns how n sees member id, or "" if it does not know it
+func (n *testNode) status(id string) string {
+	for _, m := range n.Members() {
+		if m.ID == id {
+			return m.Status
+		}
+	}
+	return ""
+}
+
+// eventually polls cond until it holds or a few seconds have passed
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForward(t *testing.T) {
+	tests := []struct {
+		name string
+		// secrets of the publishing node, the peer it dials and a node
+		// introduced to it by gossip through that peer
+		secrets [3]string
+		// reached is which of the peer and the introduced node get the
+		// publication
+		reached [2]bool
+	}{
+		{"open cluster", [3]string{"", "", ""}, [2]bool{true, true}},
+		{"shared secret", [3]string{"s3cret", "s3cret", "s3cret"}, [2]bool{true, true}},
+		{"wrong secret", [3]string{"s3cret", "s3cret", "guess"}, [2]bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := startNode(t, "peer", tt.secrets[1])
+			publisher := startNode(t, "publisher", tt.secrets[0], peer.addr)
+			introduced := startNode(t, "introduced", tt.secrets[2], peer.addr)
+
+			inboxes := [2]inbox{make(inbox, 1), make(inbox, 1)}
+			peer.broker.Subscribe("news", inboxes[0])
+			introduced.broker.Subscribe("news", inboxes[1])
+			want := 1
+			if tt.reached[1] {
+				want = 2
+			}
+			eventually(t, "the publisher sees every subscriber", func() bool { return publisher.Presence("news") == want })
+
+			if n := publisher.broker.Publish("news", "hello"); n != want {
+				t.Errorf("Publish reached %d subscriber(s), want %d", n, want)
+			}
+			for i, in := range inboxes {
+				timeout := time.Second
+				if !tt.reached[i] {
+					timeout = 100 * time.Millisecond
+				}
+				if got := in.receive(timeout); (got == "news:hello") != tt.reached[i] {
+					t.Errorf("subscriber %d got %q, want reached %v", i, got, tt.reached[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMemberLifecycle(t *testing.T) {
+	a := startNode(t, "a", "")
+	b := startNode(t, "b", "", a.addr, "127.0.0.1:1")
+	eventually(t, "a knows b", func() bool { return a.status("b") == "alive" })
+	// a dials b back once gossip told it b's address
+	eventually(t, "b knows a", func() bool { return b.status("a") == "alive" })
+
+	b.broker.Subscribe("news", make(inbox, 1))
+	eventually(t, "a sees b's subscriber", func() bool { return a.Presence("news") == 1 })
+
+	b.Stop()
+	eventually(t, "b is suspect", func() bool { return a.status("b") == "suspect" })
+	eventually(t, "b is dead", func() bool { return a.status("b") == "dead" })
+	if n := a.Presence("news"); n != 0 {
+		t.Errorf("presence counts %d subscriber(s) of a dead member", n)
+	}
+	if n := a.broker.Publish("news", "x"); n != 0 {
+		t.Errorf("Publish counts %d subscriber(s) of a dead member", n)
+	}
+	eventually(t, "b is forgotten", func() bool { return a.status("b") == "" })
+}
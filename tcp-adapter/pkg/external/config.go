@@ -0,0 +1,136 @@
+// Package external implements adapter commands with external programs,
+// so they can be written in shell, Python or anything else that reads
+// stdin and writes stdout.
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a single invocation when Config.Timeout is unset
+const DefaultTimeout = 10 * time.Second
+
+// Input modes for one-shot programs
+const (
+	// InputStdin writes the payload to the program's standard input
+	InputStdin = "stdin"
+	// InputArgv appends the payload as the last command-line argument
+	InputArgv = "argv"
+)
+
+// Config maps one adapter command to an executable
+type Config struct {
+	// Command is the adapter command name, e.g. RESIZE
+	Command string `json:"command"`
+	// Path is the executable to run; Args are passed before the payload
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+	// Response is the command of successful responses (default
+	// <COMMAND>_RESPONSE)
+	Response string `json:"response,omitempty"`
+	// Input is stdin (default) or argv; ignored by persistent workers
+	Input string `json:"input,omitempty"`
+	// Timeout bounds each request; a persistent worker that exceeds it
+	// is killed and restarted
+	Timeout Duration `json:"timeout,omitempty"`
+	// MaxConcurrent limits parallel invocations (and is the number of
+	// workers in persistent mode); extra requests get TOO_MANY_REQUESTS
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// Env lists the adapter's environment variables passed through to the
+	// program; nothing else from the environment is inherited
+	Env []string `json:"env,omitempty"`
+	// Dir is the working directory of the program
+	Dir string `json:"dir,omitempty"`
+	// Persistent keeps the program running and exchanges one JSON object
+	// per line with it instead of starting a process per request
+	Persistent bool `json:"persistent,omitempty"`
+}
+
+// Duration is a time.Duration that reads "5s"-style strings from JSON
+type Duration time.Duration
+
+// UnmarshalJSON accepts a duration string or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads a JSON array of handler configurations from path
+func LoadConfig(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range configs {
+		if err := configs[i].normalize(); err != nil {
+			return nil, fmt.Errorf("%s: handler %d: %w", path, i+1, err)
+		}
+	}
+	return configs, nil
+}
+
+// normalize validates the configuration and fills in defaults
+func (c *Config) normalize() error {
+	c.Command = strings.ToUpper(strings.TrimSpace(c.Command))
+	if c.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if c.Path == "" {
+		return fmt.Errorf("%s: path is required", c.Command)
+	}
+	if c.Response == "" {
+		c.Response = c.Command + "_RESPONSE"
+	}
+	switch c.Input {
+	case "":
+		c.Input = InputStdin
+	case InputStdin, InputArgv:
+	default:
+		return fmt.Errorf("%s: unknown input %q (want stdin or argv)", c.Command, c.Input)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = Duration(DefaultTimeout)
+	}
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 1
+	}
+	return nil
+}
+
+// environ builds the program environment from the whitelisted variables
+// plus the request metadata
+func (c *Config) environ(extra ...string) []string {
+	env := make([]string, 0, len(c.Env)+len(extra))
+	for _, name := range c.Env {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, extra...)
+}
@@ -0,0 +1,58 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Config
+		wantErr string
+	}{
+		{"defaults", `[{"command":" resize ","path":"/bin/resize"}]`, Config{
+			Command: "RESIZE", Path: "/bin/resize", Response: "RESIZE_RESPONSE", Input: InputStdin,
+			Timeout: Duration(DefaultTimeout), MaxConcurrent: 1,
+		}, ""},
+		{"settings", `[{"command":"score","path":"score.py","response":"SCORED","input":"argv","timeout":"250ms","max_concurrent":4}]`, Config{
+			Command: "SCORE", Path: "score.py", Response: "SCORED", Input: InputArgv,
+			Timeout: Duration(250 * time.Millisecond), MaxConcurrent: 4,
+		}, ""},
+		{"timeout in nanoseconds", `[{"command":"x","path":"x","timeout":1000}]`, Config{
+			Command: "X", Path: "x", Response: "X_RESPONSE", Input: InputStdin,
+			Timeout: Duration(time.Microsecond), MaxConcurrent: 1,
+		}, ""},
+		{"missing command", `[{"path":"x"}]`, Config{}, "handler 1: command is required"},
+		{"missing path", `[{"command":"a","path":"a"},{"command":"b"}]`, Config{}, "handler 2: B: path is required"},
+		{"unknown input", `[{"command":"a","path":"a","input":"file"}]`, Config{}, `unknown input "file"`},
+		{"bad timeout", `[{"command":"a","path":"a","timeout":"soon"}]`, Config{}, "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "handlers.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configs, err := LoadConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			got := configs[0]
+			if got.Command != tt.want.Command || got.Path != tt.want.Path || got.Response != tt.want.Response ||
+				got.Input != tt.want.Input || got.Timeout != tt.want.Timeout || got.MaxConcurrent != tt.want.MaxConcurrent {
+				t.Errorf("LoadConfig = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
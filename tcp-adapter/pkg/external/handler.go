@@ -0,0 +1,225 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// waitDelay bounds how long a killed program's children may keep its
+// output pipes open
+const waitDelay = time.Second
+
+// maxOutput bounds what a program may write to stdout or to stderr for
+// one request; it is also the longest line a worker may answer with
+const maxOutput = 1 << 20
+
+// Handler runs one configured command
+type Handler struct {
+	config Config
+	// slots limits concurrent invocations
+	slots chan struct{}
+	// workers is the pool of persistent processes, nil for one-shot mode
+	workers chan *worker
+	// closed is closed by Close; workers are not handed out after it
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates the handler for config. Persistent workers are started
+// lazily on their first request.
+func New(config Config) (*Handler, error) {
+	if err := config.normalize(); err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		config: config,
+		slots:  make(chan struct{}, config.MaxConcurrent),
+		closed: make(chan struct{}),
+	}
+	if config.Persistent {
+		h.workers = make(chan *worker, config.MaxConcurrent)
+		for i := 0; i < config.MaxConcurrent; i++ {
+			h.workers <- &worker{config: &h.config}
+		}
+	}
+	return h, nil
+}
+
+// Register creates a handler for every config and adds it to router.
+// The returned handlers must be closed to stop persistent workers.
+func Register(router *handler.Router, configs []Config) ([]*Handler, error) {
+	handlers := make([]*Handler, 0, len(configs))
+	for _, config := range configs {
+		h, err := New(config)
+		if err != nil {
+			Close(handlers)
+			return nil, err
+		}
+		router.Handle(h.config.Command, h.Command)
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// Close stops the persistent workers of every handler
+func Close(handlers []*Handler) {
+	for _, h := range handlers {
+		h.Close()
+	}
+}
+
+// Command is the handler.CommandFunc for the configured command
+func (h *Handler) Command(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	default:
+		return protocol.Errorf(protocol.CodeTooManyRequests, "Too many concurrent %s requests", h.config.Command).ToMessage()
+	}
+
+	timeout := time.Duration(h.config.Timeout)
+	var (
+		payload string
+		err     error
+	)
+	if h.workers != nil {
+		w, ok := h.worker()
+		if !ok {
+			return protocol.Errorf(protocol.CodeUnavailable, "Command %s is shutting down", h.config.Command).ToMessage()
+		}
+		payload, err = w.call(ctx, msg, timeout)
+		h.workers <- w
+	} else {
+		payload, err = h.run(ctx, msg, timeout)
+	}
+
+	if err != nil {
+		var perr *protocol.Error
+		if errors.As(err, &perr) {
+			return perr.ToMessage()
+		}
+		log.Printf("External command %s from %s failed: %v", h.config.Command, ctx.RemoteAddr, err)
+		return protocol.Errorf(protocol.CodeInternal, "Command %s failed", h.config.Command).ToMessage()
+	}
+	return protocol.NewMessage(h.config.Response, payload)
+}
+
+// worker takes an idle persistent worker, or reports false once the
+// handler is closed
+func (h *Handler) worker() (*worker, bool) {
+	select {
+	case w := <-h.workers:
+		select {
+		case <-h.closed:
+			h.workers <- w
+			return nil, false
+		default:
+			return w, true
+		}
+	case <-h.closed:
+		return nil, false
+	}
+}
+
+// Close stops the persistent workers, waiting for those serving a
+// request to finish it. Requests arriving later are refused.
+func (h *Handler) Close() {
+	if h.workers == nil {
+		return
+	}
+	h.closeOnce.Do(func() {
+		close(h.closed)
+		for i := 0; i < cap(h.workers); i++ {
+			w := <-h.workers
+			w.stop()
+		}
+	})
+}
+
+// run starts the program once for a request and returns its output
+func (h *Handler) run(ctx *handler.Context, msg *protocol.Message, timeout time.Duration) (string, error) {
+	runCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := h.config.Args
+	if h.config.Input == InputArgv {
+		args = append(append([]string{}, args...), msg.Payload)
+	}
+	cmd := exec.CommandContext(runCtx, h.config.Path, args...)
+	cmd.Dir = h.config.Dir
+	cmd.Env = h.config.environ(requestEnv(ctx, msg)...)
+	cmd.WaitDelay = waitDelay
+	if h.config.Input == InputStdin {
+		cmd.Stdin = strings.NewReader(msg.Payload + "\n")
+	}
+	stdout := &limitedBuffer{max: maxOutput, exceeded: cancel}
+	stderr := &limitedBuffer{max: maxOutput, exceeded: cancel}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if stdout.full || stderr.full {
+		return "", fmt.Errorf("%s: output exceeds %d bytes", h.config.Path, maxOutput)
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return "", protocol.Errorf(protocol.CodeTimeout, "Command %s timed out after %s", h.config.Command, timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", h.config.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return singleLine(stdout.String())
+}
+
+// limitedBuffer keeps up to max bytes and calls exceeded once more are
+// written, discarding them so the program is not blocked on its output
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	full     bool
+	exceeded func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.full {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > b.max {
+		b.buf.Write(p[:b.max-b.buf.Len()])
+		b.full = true
+		b.exceeded()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// requestEnv describes the request to the program
+func requestEnv(ctx *handler.Context, msg *protocol.Message) []string {
+	return []string{
+		"ADAPTER_COMMAND=" + strings.ToUpper(msg.Command),
+		"ADAPTER_REMOTE_ADDR=" + ctx.RemoteAddr,
+		"ADAPTER_LISTENER=" + ctx.Listener,
+	}
+}
+
+// singleLine turns program output into a response payload, which cannot
+// span several lines
+func singleLine(output string) (string, error) {
+	output = strings.TrimRight(output, "\r\n")
+	if strings.ContainsAny(output, "\r\n") {
+		return "", errors.New("program output must be a single line")
+	}
+	return output, nil
+}
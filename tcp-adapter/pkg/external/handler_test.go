@@ -0,0 +1,176 @@
+package external_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tcp-adapter/pkg/adaptertest"
+	"tcp-adapter/pkg/external"
+	"tcp-adapter/pkg/protocol"
+)
+
+// serve registers configs on a new test server
+func serve(t *testing.T, configs ...external.Config) (*adaptertest.Server, []*external.Handler) {
+	t.Helper()
+	srv := adaptertest.NewServer(t)
+	handlers, err := external.Register(srv.Router(), configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { external.Close(handlers) })
+	return srv, handlers
+}
+
+// shell runs script with sh for the command
+func shell(command, script string) external.Config {
+	return external.Config{
+		Command: command,
+		Path:    "sh",
+		Args:    []string{"-c", script, "sh"},
+		Env:     []string{"PATH"},
+		Timeout: external.Duration(time.Second),
+	}
+}
+
+func TestOneShot(t *testing.T) {
+	argv := shell("ARGV", `echo "got $1"`)
+	argv.Input = external.InputArgv
+	renamed := shell("RENAMED", "cat")
+	renamed.Response = "DONE"
+	slow := shell("SLOW", "exec sleep 10")
+	slow.Timeout = external.Duration(100 * time.Millisecond)
+	srv, _ := serve(t,
+		shell("CAT", "cat"),
+		argv,
+		shell("ENV", `echo "$ADAPTER_COMMAND $ADAPTER_LISTENER"`),
+		renamed,
+		shell("FAIL", "echo oops >&2; exit 3"),
+		shell("LINES", "echo one; echo two"),
+		shell("FLOOD", "exec yes"),
+		slow,
+	)
+	c := srv.Client()
+
+	tests := []struct {
+		request string
+		want    string
+		code    protocol.ErrorCode
+	}{
+		{"CAT:hello", "CAT_RESPONSE:hello", 0},
+		{"ARGV:hello world", "ARGV_RESPONSE:got hello world", 0},
+		{"env:", "ENV_RESPONSE:ENV default", 0},
+		{"RENAMED:x", "DONE:x", 0},
+		{"FAIL:", "", protocol.CodeInternal},
+		{"LINES:", "", protocol.CodeInternal},
+		{"FLOOD:", "", protocol.CodeInternal},
+		{"SLOW:", "", protocol.CodeTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.request, func(t *testing.T) {
+			if tt.code != 0 {
+				c.ExpectError(tt.request, tt.code)
+			} else {
+				c.Expect(tt.request, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	srv, _ := serve(t, shell("WAIT", "sleep 0.5; echo done"))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		srv.Client().Expect("WAIT:", "WAIT_RESPONSE:done")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	srv.Client().ExpectError("WAIT:", protocol.CodeTooManyRequests)
+	wg.Wait()
+}
+
+// workerScript answers one JSON line per request: the payload, its PID
+// for "pid", and misbehaves for the other test payloads
+const workerScript = `
+while read -r line; do
+	id=$(printf '%s' "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+	payload=$(printf '%s' "$line" | sed 's/.*"payload":"\([^"]*\)".*/\1/')
+	case $payload in
+	pid) echo "{\"id\":$id,\"payload\":\"$$\"}" ;;
+	die) exit 1 ;;
+	hang) exec sleep 10 ;;
+	garbage) echo "not json" ;;
+	wrong-id) echo "{\"id\":0,\"payload\":\"x\"}" ;;
+	error) echo "{\"id\":$id,\"error\":{\"code\":409,\"message\":\"taken\"}}" ;;
+	odd-error) echo "{\"id\":$id,\"error\":{\"code\":999,\"message\":\"odd\"}}" ;;
+	*) echo "{\"id\":$id,\"payload\":\"$payload\"}" ;;
+	esac
+done
+`
+
+func TestWorker(t *testing.T) {
+	config := shell("WORK", workerScript)
+	config.Persistent = true
+	config.Timeout = external.Duration(200 * time.Millisecond)
+	srv, handlers := serve(t, config)
+	c := srv.Client()
+
+	pid := c.Send("WORK:pid").Payload
+	tests := []struct {
+		payload string
+		code    protocol.ErrorCode
+		// restarts is whether the worker is replaced after the request
+		restarts bool
+	}{
+		{"hello", 0, false},
+		{"error", protocol.CodeConflict, false},
+		{"odd-error", protocol.CodeInternal, false},
+		{"die", protocol.CodeInternal, true},
+		{"hang", protocol.CodeTimeout, true},
+		{"garbage", protocol.CodeInternal, true},
+		{"wrong-id", protocol.CodeInternal, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			if tt.code != 0 {
+				c.ExpectError("WORK:"+tt.payload, tt.code)
+			} else {
+				c.Expect("WORK:"+tt.payload, "WORK_RESPONSE:"+tt.payload)
+			}
+			next := c.Send("WORK:pid").Payload
+			if restarted := next != pid; restarted != tt.restarts {
+				t.Errorf("worker %s then %s, want restarted %v", pid, next, tt.restarts)
+			}
+			pid = next
+		})
+	}
+
+	handlers[0].Close()
+	handlers[0].Close()
+	e := c.ExpectError("WORK:hello", protocol.CodeUnavailable)
+	if !strings.Contains(e.Message, "shutting down") {
+		t.Errorf("error after Close = %v", e)
+	}
+}
+
+func TestCloseWaitsForBusyWorkers(t *testing.T) {
+	config := shell("WORK", `while read -r line; do sleep 0.3; echo "{\"id\":1,\"payload\":\"slow\"}"; done`)
+	config.Persistent = true
+	srv, handlers := serve(t, config)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Client().Expect("WORK:", "WORK_RESPONSE:slow")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	external.Close(handlers)
+	select {
+	case <-done:
+	default:
+		t.Fatal("Close returned before the busy worker finished")
+	}
+	srv.Client().ExpectError("WORK:", protocol.CodeUnavailable)
+}
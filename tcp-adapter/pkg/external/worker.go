@@ -0,0 +1,201 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// workerRequest is the line sent to a persistent worker for each request
+type workerRequest struct {
+	ID         uint64            `json:"id"`
+	Command    string            `json:"command"`
+	Payload    string            `json:"payload"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	RemoteAddr string            `json:"remote_addr"`
+	Listener   string            `json:"listener"`
+}
+
+// workerResponse is the line a persistent worker answers with. Setting
+// Error sends an error frame instead of the payload.
+type workerResponse struct {
+	ID      uint64 `json:"id"`
+	Payload string `json:"payload"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// worker is one persistent process. Workers are handed out one request
+// at a time by Handler, so a worker is never used concurrently.
+type worker struct {
+	config *Config
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// pipe is our end of the process's stdout. It is not an
+	// exec.Cmd.StdoutPipe, which Wait would close under a pending read.
+	pipe   *os.File
+	stdout *bufio.Scanner
+	// exited is closed when the process has been reaped
+	exited chan struct{}
+	nextID uint64
+}
+
+// start launches the process
+func (w *worker) start() error {
+	cmd := exec.Command(w.config.Path, w.config.Args...)
+	cmd.Dir = w.config.Dir
+	cmd.Env = w.config.environ()
+	cmd.Stderr = logWriter{prefix: w.config.Command}
+	cmd.WaitDelay = waitDelay
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	pipe, stdout, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return err
+	}
+	cmd.Stdout = stdout
+	err = cmd.Start()
+	stdout.Close()
+	if err != nil {
+		pipe.Close()
+		return fmt.Errorf("starting %s: %w", w.config.Path, err)
+	}
+
+	w.cmd = cmd
+	w.stdin = stdin
+	w.pipe = pipe
+	w.stdout = bufio.NewScanner(pipe)
+	w.stdout.Buffer(make([]byte, 0, 64*1024), maxOutput)
+	w.exited = make(chan struct{})
+	go func(exited chan struct{}) {
+		cmd.Wait()
+		close(exited)
+	}(w.exited)
+	log.Printf("Started worker %s for %s (pid %d)", w.config.Path, w.config.Command, cmd.Process.Pid)
+	return nil
+}
+
+// call sends one request and waits for its response, restarting the
+// process if it died earlier or does not answer within timeout
+func (w *worker) call(ctx *handler.Context, msg *protocol.Message, timeout time.Duration) (string, error) {
+	if w.cmd == nil {
+		if err := w.start(); err != nil {
+			return "", err
+		}
+	}
+
+	w.nextID++
+	request := workerRequest{
+		ID:         w.nextID,
+		Command:    msg.Command,
+		Payload:    msg.Payload,
+		Headers:    msg.Headers,
+		RemoteAddr: ctx.RemoteAddr,
+		Listener:   ctx.Listener,
+	}
+	line, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	type result struct {
+		response workerResponse
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		if _, err := w.stdin.Write(append(line, '\n')); err != nil {
+			r.err = fmt.Errorf("writing to worker: %w", err)
+		} else if !w.stdout.Scan() {
+			r.err = errors.New("worker exited")
+			if err := w.stdout.Err(); err != nil {
+				r.err = fmt.Errorf("reading from worker: %w", err)
+			}
+		} else if err := json.Unmarshal(w.stdout.Bytes(), &r.response); err != nil {
+			r.err = fmt.Errorf("invalid worker response: %w", err)
+		} else if r.response.ID != request.ID {
+			r.err = fmt.Errorf("worker answered request %d, expected %d", r.response.ID, request.ID)
+		}
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			w.stop()
+			return "", r.err
+		}
+		if e := r.response.Error; e != nil {
+			code := protocol.ErrorCode(e.Code)
+			if code.String() == "UNKNOWN" {
+				code = protocol.CodeInternal
+			}
+			return "", protocol.NewError(code, e.Message)
+		}
+		return singleLine(r.response.Payload)
+	case <-time.After(timeout):
+		w.kill()
+		<-done
+		return "", protocol.Errorf(protocol.CodeTimeout, "Command %s timed out after %s", w.config.Command, timeout)
+	}
+}
+
+// stop closes the process's stdin and kills it if it does not exit
+// promptly; the next request starts a new one
+func (w *worker) stop() {
+	if w.cmd == nil {
+		return
+	}
+	w.stdin.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(time.Second):
+		w.cmd.Process.Kill()
+		<-w.exited
+	}
+	w.release()
+}
+
+// kill terminates a process that stopped responding
+func (w *worker) kill() {
+	if w.cmd == nil {
+		return
+	}
+	w.cmd.Process.Kill()
+	<-w.exited
+	w.release()
+}
+
+// release closes stdout once the process has been reaped, which also
+// ends a read blocked on output of a child the process left behind
+func (w *worker) release() {
+	w.pipe.Close()
+	w.cmd = nil
+}
+
+// logWriter forwards a worker's stderr to the server log
+type logWriter struct {
+	prefix string
+}
+
+func (l logWriter) Write(p []byte) (int, error) {
+	log.Printf("[%s] %s", l.prefix, bytes.TrimRight(p, "\r\n"))
+	return len(p), nil
+}
@@ -0,0 +1,153 @@
+package filetransfer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/client"
+	"tcp-adapter/pkg/protocol"
+)
+
+// Upload sends the file at localPath to the server as remote, resuming
+// an interrupted upload of the same name. It returns the size and
+// SHA-256 digest verified by the server.
+func Upload(c *client.Client, localPath, remote string, chunkSize int) (int64, string, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	size, digest, err := fileDigest(localPath)
+	if err != nil {
+		return 0, "", err
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	args, err := call(c, "PUTSTAT", remote, "PUTSTAT_OK", 2)
+	if err != nil {
+		return 0, "", err
+	}
+	offset, _ := strconv.ParseInt(args[1], 10, 64)
+	if offset > size {
+		offset = 0
+	}
+
+	buf := make([]byte, chunkSize)
+	for first := true; first || offset < size; first = false {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return 0, "", err
+		}
+		payload := fmt.Sprintf("%s %d %s", remote, offset, base64.StdEncoding.EncodeToString(buf[:n]))
+		args, err := call(c, "PUT", payload, "PUT_OK", 2)
+		if err != nil {
+			return 0, "", err
+		}
+		offset, _ = strconv.ParseInt(args[1], 10, 64)
+	}
+
+	if _, err := call(c, "PUTDONE", fmt.Sprintf("%s %d %s", remote, size, digest), "PUTDONE_OK", 3); err != nil {
+		return 0, "", err
+	}
+	return size, digest, nil
+}
+
+// Download fetches remote into localPath, resuming from localPath+".part"
+// if a previous download was interrupted, and verifies the SHA-256 digest
+// reported by the server before moving the file into place.
+func Download(c *client.Client, remote, localPath string) (int64, string, error) {
+	partial := localPath + ".part"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, "", err
+	}
+
+	for {
+		args, err := call(c, "GET", fmt.Sprintf("%s %d", remote, offset), "GET_OK", 4)
+		if err != nil {
+			// The remote file may have shrunk since the partial download,
+			// leaving the offset past its end
+			if protoErr, ok := err.(*protocol.Error); ok && protoErr.Code == protocol.CodeConflict && offset > 0 {
+				f.Truncate(0)
+				f.Seek(0, io.SeekStart)
+				offset = 0
+				continue
+			}
+			if offset == 0 {
+				f.Close()
+				os.Remove(partial)
+			}
+			return 0, "", err
+		}
+		size, _ := strconv.ParseInt(args[2], 10, 64)
+		data, err := base64.StdEncoding.DecodeString(args[3])
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid chunk from server: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return 0, "", err
+		}
+		offset += int64(len(data))
+		if offset >= size || len(data) == 0 {
+			break
+		}
+	}
+
+	args, err := call(c, "GETSUM", remote, "GETSUM_OK", 3)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := f.Close(); err != nil {
+		return 0, "", err
+	}
+
+	size, digest, err := fileDigest(partial)
+	if err != nil {
+		return 0, "", err
+	}
+	if strconv.FormatInt(size, 10) != args[1] || !strings.EqualFold(digest, args[2]) {
+		os.Remove(partial)
+		return 0, "", fmt.Errorf("%w: downloaded %d bytes with sha256 %s, server has %s bytes with sha256 %s",
+			ErrChecksumMismatch, size, digest, args[1], args[2])
+	}
+	if err := os.Rename(partial, localPath); err != nil {
+		return 0, "", err
+	}
+	return size, digest, nil
+}
+
+// call sends a command and returns the response payload split into n
+// fields, turning error frames into *protocol.Error
+func call(c *client.Client, command, payload, want string, n int) ([]string, error) {
+	response, err := c.Do(command, payload)
+	if err != nil {
+		return nil, err
+	}
+	if protoErr, ok := protocol.ParseError(response); ok {
+		return nil, protoErr
+	}
+	if !strings.EqualFold(response.Command, want) {
+		return nil, fmt.Errorf("unexpected response %s:%s", response.Command, response.Payload)
+	}
+
+	args := strings.Fields(response.Payload)
+	if want == "GET_OK" && len(args) == 3 {
+		// Empty chunk at end of file
+		args = append(args, "")
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("unexpected %s payload: %s", want, response.Payload)
+	}
+	return args, nil
+}
@@ -0,0 +1,176 @@
+package filetransfer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+)
+
+// DefaultChunkSize is the largest chunk, in bytes before base64 encoding,
+// accepted by PUT and returned by GET
+const DefaultChunkSize = 32 * 1024
+
+// Register adds the file transfer commands to router:
+//
+//	PUTSTAT:<name>                  -> PUTSTAT_OK:<name> <offset>
+//	PUT:<name> <offset> <base64>    -> PUT_OK:<name> <offset>
+//	PUTDONE:<name> <size> <sha256>  -> PUTDONE_OK:<name> <size> <sha256>
+//	GET:<name> <offset>             -> GET_OK:<name> <offset> <size> <base64>
+//	GETSUM:<name>                   -> GETSUM_OK:<name> <size> <sha256>
+//	LIST:[dir]                      -> LIST_OK:[{"name":...,"size":...}]
+//
+// Names are relative to the store root and must not contain spaces.
+func Register(router *handler.Router, store *Store, chunkSize int) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	c := &commands{store: store, chunkSize: chunkSize}
+
+	router.Handle("PUTSTAT", c.putStat)
+	router.Handle("PUT", c.put)
+	router.Handle("PUTDONE", c.putDone)
+	router.Handle("GET", c.get)
+	router.Handle("GETSUM", c.getSum)
+	router.Handle("LIST", c.list)
+}
+
+type commands struct {
+	store     *Store
+	chunkSize int
+}
+
+func (c *commands) putStat(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	args, errMsg := fields(msg.Payload, 1)
+	if errMsg != nil {
+		return errMsg
+	}
+	offset, err := c.store.UploadOffset(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.NewMessage("PUTSTAT_OK", fmt.Sprintf("%s %d", args[0], offset))
+}
+
+func (c *commands) put(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	// An empty chunk (used for empty files) has no base64 argument
+	args := strings.Fields(msg.Payload)
+	if len(args) == 2 {
+		args = append(args, "")
+	}
+	if len(args) != 3 {
+		return protocol.Errorf(protocol.CodeBadRequest, "Expected 3 argument(s), got %d", len(args)).ToMessage()
+	}
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || offset < 0 {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Invalid offset: "+args[1])
+	}
+	data, err := base64.StdEncoding.DecodeString(args[2])
+	if err != nil {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Chunk is not valid base64")
+	}
+	if len(data) > c.chunkSize {
+		return protocol.Errorf(protocol.CodeBadRequest, "Chunk of %d bytes exceeds limit of %d", len(data), c.chunkSize).ToMessage()
+	}
+
+	next, err := c.store.WriteChunk(args[0], offset, data)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.NewMessage("PUT_OK", fmt.Sprintf("%s %d", args[0], next))
+}
+
+func (c *commands) putDone(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	args, errMsg := fields(msg.Payload, 3)
+	if errMsg != nil {
+		return errMsg
+	}
+	size, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || size < 0 {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Invalid size: "+args[1])
+	}
+
+	if err := c.store.FinishUpload(args[0], size, args[2]); err != nil {
+		return storeError(err)
+	}
+	log.Printf("File %s (%d bytes) uploaded by %s", args[0], size, ctx.RemoteAddr)
+	return protocol.NewMessage("PUTDONE_OK", fmt.Sprintf("%s %d %s", args[0], size, strings.ToLower(args[2])))
+}
+
+func (c *commands) get(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	args, errMsg := fields(msg.Payload, 2)
+	if errMsg != nil {
+		return errMsg
+	}
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || offset < 0 {
+		return protocol.ErrorMessage(protocol.CodeBadRequest, "Invalid offset: "+args[1])
+	}
+
+	data, size, err := c.store.ReadChunk(args[0], offset, c.chunkSize)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.NewMessage("GET_OK", fmt.Sprintf("%s %d %d %s",
+		args[0], offset, size, base64.StdEncoding.EncodeToString(data)))
+}
+
+func (c *commands) getSum(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	args, errMsg := fields(msg.Payload, 1)
+	if errMsg != nil {
+		return errMsg
+	}
+	size, digest, err := c.store.Checksum(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.NewMessage("GETSUM_OK", fmt.Sprintf("%s %d %s", args[0], size, digest))
+}
+
+func (c *commands) list(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	files, err := c.store.List(msg.Payload)
+	if err != nil {
+		return storeError(err)
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return protocol.ErrorMessage(protocol.CodeInternal, err.Error())
+	}
+	return protocol.NewMessage("LIST_OK", string(data))
+}
+
+// fields splits payload into exactly n space-separated arguments
+func fields(payload string, n int) ([]string, *protocol.Message) {
+	args := strings.Fields(payload)
+	if len(args) != n {
+		return nil, protocol.Errorf(protocol.CodeBadRequest, "Expected %d argument(s), got %d", n, len(args)).ToMessage()
+	}
+	return args, nil
+}
+
+// storeError maps store failures to error frames
+func storeError(err error) *protocol.Message {
+	switch {
+	case errors.Is(err, ErrInvalidPath):
+		return protocol.ErrorMessage(protocol.CodeForbidden, "Invalid path")
+	case errors.Is(err, fs.ErrNotExist):
+		return protocol.ErrorMessage(protocol.CodeNotFound, "File not found")
+	case errors.Is(err, ErrOffsetMismatch):
+		return protocol.ErrorMessage(protocol.CodeConflict, err.Error())
+	case errors.Is(err, ErrChecksumMismatch):
+		return protocol.ErrorMessage(protocol.CodeBadRequest, err.Error())
+	case errors.Is(err, ErrFileTooLarge):
+		return protocol.ErrorMessage(protocol.CodeBadRequest, err.Error())
+	case errors.Is(err, ErrQuotaExceeded):
+		return protocol.ErrorMessage(protocol.CodeUnavailable, err.Error())
+	default:
+		log.Printf("File transfer error: %v", err)
+		return protocol.ErrorMessage(protocol.CodeInternal, "Storage error")
+	}
+}
@@ -0,0 +1,446 @@
+// Package filetransfer moves files through the adapter in base64 chunks.
+//
+// Uploads are written to a partial file and only appear under their name
+// once PUTDONE has verified the size and SHA-256 digest, so an interrupted
+// upload can be resumed from the offset reported by PUTSTAT. Partial files
+// that see no writes for PartialTTL are removed.
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partialDir holds uploads in progress, relative to the storage root
+const partialDir = ".partial"
+
+// PartialTTL is how long an upload may go without writes before its
+// partial file is removed
+const PartialTTL = 24 * time.Hour
+
+// sweepInterval is how often new uploads look for abandoned ones
+const sweepInterval = time.Hour
+
+// ErrInvalidPath is returned for names that would escape the storage root
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrOffsetMismatch is returned when a chunk does not continue the upload
+var ErrOffsetMismatch = errors.New("offset mismatch")
+
+// ErrChecksumMismatch is returned when a finished upload fails verification
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrFileTooLarge is returned for chunks growing an upload beyond
+// Store.MaxFileSize
+var ErrFileTooLarge = errors.New("file too large")
+
+// ErrQuotaExceeded is returned for chunks that would store more than
+// Store.Quota below the root
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Store keeps transferred files below a root directory
+type Store struct {
+	root string
+	// MaxFileSize is the largest upload accepted, in bytes; 0 means no
+	// limit
+	MaxFileSize int64
+	// Quota bounds the bytes stored below the root, uploads in progress
+	// included; 0 means no limit. Usage is measured by NewStore and then
+	// tracked through the store, so files changed by other means are
+	// only noticed on restart.
+	Quota int64
+
+	mu        sync.Mutex
+	locks     map[string]*nameLock
+	lastSweep time.Time
+	// used is the bytes stored below the root
+	used int64
+}
+
+// nameLock serialises operations on one upload; it is dropped from
+// Store.locks when no operation holds or waits for it
+type nameLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewStore creates the root directory if needed and returns a store for it
+func NewStore(root string) (*Store, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(abs, partialDir), 0o755); err != nil {
+		return nil, fmt.Errorf("creating storage root: %w", err)
+	}
+	// Resolve symlinks once so containment checks compare real paths
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		root:  abs,
+		locks: make(map[string]*nameLock),
+	}
+	s.sweep(time.Now())
+	if s.used, err = usage(abs); err != nil {
+		return nil, fmt.Errorf("measuring storage root: %w", err)
+	}
+	return s, nil
+}
+
+// Root returns the absolute storage root
+func (s *Store) Root() string {
+	return s.root
+}
+
+// resolve maps a client-supplied name to a path inside root. Absolute
+// paths, ".." components, the partial directory and symlinks leading
+// outside the root are rejected.
+func (s *Store) resolve(name string) (string, error) {
+	name = filepath.ToSlash(strings.TrimSpace(name))
+	if name == "" || strings.ContainsRune(name, '\\') || strings.ContainsRune(name, 0) {
+		return "", ErrInvalidPath
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(clean) {
+		return "", ErrInvalidPath
+	}
+	if first := strings.Split(filepath.ToSlash(clean), "/")[0]; first == partialDir {
+		return "", ErrInvalidPath
+	}
+
+	path := filepath.Join(s.root, clean)
+
+	// The deepest existing ancestor must still be inside the root, so a
+	// symlinked directory cannot redirect writes elsewhere
+	dir := filepath.Dir(path)
+	for {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			if real != s.root && !strings.HasPrefix(real, s.root+string(filepath.Separator)) {
+				return "", ErrInvalidPath
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) || dir == s.root {
+			return "", err
+		}
+		dir = filepath.Dir(dir)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return "", ErrInvalidPath
+	}
+	return path, nil
+}
+
+// usage adds up the sizes of the regular files below root
+func usage(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// reserve counts n more bytes as stored, failing if that exceeds Quota
+func (s *Store) reserve(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Quota > 0 && n > 0 && s.used+n > s.Quota {
+		return fmt.Errorf("%w: limit is %d bytes", ErrQuotaExceeded, s.Quota)
+	}
+	s.used += n
+	return nil
+}
+
+// freed counts n bytes as no longer stored
+func (s *Store) freed(n int64) {
+	s.reserve(-n)
+}
+
+// partialPath returns where the upload of name is staged
+func (s *Store) partialPath(name string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(filepath.Clean(name))))
+	return filepath.Join(s.root, partialDir, hex.EncodeToString(sum[:16]))
+}
+
+// lock serialises operations on one name
+func (s *Store) lock(name string) func() {
+	key := s.partialPath(name)
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &nameLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sweep removes partial files older than PartialTTL, at most once per
+// sweepInterval
+func (s *Store) sweep(now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	dir := filepath.Join(s.root, partialDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Listing partial uploads: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || now.Sub(info.ModTime()) < PartialTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Removing abandoned upload: %v", err)
+			}
+			continue
+		}
+		s.freed(info.Size())
+	}
+}
+
+// UploadOffset returns how many bytes of name have been uploaded so far
+func (s *Store) UploadOffset(name string) (int64, error) {
+	if _, err := s.resolve(name); err != nil {
+		return 0, err
+	}
+	defer s.lock(name)()
+
+	info, err := os.Stat(s.partialPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// WriteChunk appends data to the upload of name. offset must equal the
+// current upload size; offset 0 restarts the upload. Chunks growing the
+// upload beyond MaxFileSize or the store beyond Quota are rejected.
+func (s *Store) WriteChunk(name string, offset int64, data []byte) (int64, error) {
+	if _, err := s.resolve(name); err != nil {
+		return 0, err
+	}
+	if s.MaxFileSize > 0 && offset+int64(len(data)) > s.MaxFileSize {
+		return 0, fmt.Errorf("%w: limit is %d bytes", ErrFileTooLarge, s.MaxFileSize)
+	}
+	defer s.lock(name)()
+
+	partial := s.partialPath(name)
+	flags := os.O_WRONLY
+	var restarted int64
+	if offset == 0 {
+		flags |= os.O_CREATE | os.O_TRUNC
+		s.sweep(time.Now())
+		if info, err := os.Stat(partial); err == nil {
+			restarted = info.Size()
+		}
+	}
+	f, err := os.OpenFile(partial, flags, 0o644)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("%w: expected offset 0", ErrOffsetMismatch)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s.freed(restarted)
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size != offset {
+		return size, fmt.Errorf("%w: expected offset %d", ErrOffsetMismatch, size)
+	}
+	if err := s.reserve(int64(len(data))); err != nil {
+		return size, err
+	}
+	if n, err := f.Write(data); err != nil {
+		s.freed(int64(len(data) - n))
+		return 0, err
+	}
+	return size + int64(len(data)), nil
+}
+
+// FinishUpload verifies the upload of name against size and the hex
+// SHA-256 digest and moves it into place. A failed verification discards
+// the partial file.
+func (s *Store) FinishUpload(name string, size int64, digest string) error {
+	path, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	defer s.lock(name)()
+
+	partial := s.partialPath(name)
+	gotSize, gotDigest, err := fileDigest(partial)
+	if err != nil {
+		return err
+	}
+	if gotSize != size || !strings.EqualFold(gotDigest, digest) {
+		if os.Remove(partial) == nil {
+			s.freed(gotSize)
+		}
+		return fmt.Errorf("%w: got %d bytes with sha256 %s", ErrChecksumMismatch, gotSize, gotDigest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var replaced int64
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		replaced = info.Size()
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return err
+	}
+	s.freed(replaced)
+	return nil
+}
+
+// ReadChunk reads up to length bytes of name starting at offset and
+// returns them with the total file size
+func (s *Store) ReadChunk(name string, offset int64, length int) ([]byte, int64, error) {
+	path, err := s.resolve(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, 0, ErrInvalidPath
+	}
+	if offset < 0 || offset > info.Size() {
+		return nil, 0, fmt.Errorf("%w: offset %d beyond size %d", ErrOffsetMismatch, offset, info.Size())
+	}
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+	return buf[:n], info.Size(), nil
+}
+
+// Checksum returns the size and hex SHA-256 digest of name
+func (s *Store) Checksum(name string) (int64, string, error) {
+	path, err := s.resolve(name)
+	if err != nil {
+		return 0, "", err
+	}
+	return fileDigest(path)
+}
+
+// List returns the stored files below dir ("" for the root), sorted by name
+func (s *Store) List(dir string) ([]FileInfo, error) {
+	base := s.root
+	if strings.TrimSpace(dir) != "" {
+		path, err := s.resolve(dir)
+		if err != nil {
+			return nil, err
+		}
+		base = path
+	}
+
+	files := []FileInfo{}
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == partialDir && filepath.Dir(path) == s.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(s.root, path)
+		files = append(files, FileInfo{Name: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// fileDigest hashes the file at path
+func fileDigest(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
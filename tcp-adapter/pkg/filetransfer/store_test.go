@@ -0,0 +1,144 @@
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// upload stores data as name in one chunk
+func upload(t *testing.T, s *Store, name string, data []byte) {
+	t.Helper()
+	if _, err := s.WriteChunk(name, 0, data); err != nil {
+		t.Fatalf("WriteChunk(%s): %v", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if err := s.FinishUpload(name, int64(len(data)), hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("FinishUpload(%s): %v", name, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	s, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(s.Root(), "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(s.Root(), "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(s.Root(), "dir"), filepath.Join(s.Root(), "inside")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "f"), filepath.Join(s.Root(), "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string // relative to the root, "" for ErrInvalidPath
+	}{
+		{"report.csv", "report.csv"},
+		{" dir/report.csv ", "dir/report.csv"},
+		{"new/dirs/report.csv", "new/dirs/report.csv"},
+		{"dir/../report.csv", "report.csv"},
+		{"./dir//report.csv", "dir/report.csv"},
+		{"inside/report.csv", "inside/report.csv"},
+		{"", ""},
+		{"..", ""},
+		{"../report.csv", ""},
+		{"dir/../../report.csv", ""},
+		{"/etc/passwd", ""},
+		{`dir\report.csv`, ""},
+		{"dir/report\x00.csv", ""},
+		{".partial/abc", ""},
+		{"dir/../.partial/abc", ""},
+		{"escape/report.csv", ""},
+		{"escape/new/report.csv", ""},
+		{"link", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.resolve(tt.name)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Fatalf("resolve = %q, %v, want ErrInvalidPath", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if want := filepath.Join(s.Root(), filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("resolve = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "existing"), make([]byte, 4), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxFileSize, s.Quota = 8, 16
+
+	tests := []struct {
+		name    string
+		file    string
+		offset  int64
+		size    int
+		wantErr error
+	}{
+		{"within limits", "a", 0, 6, nil},
+		{"continues an upload", "a", 6, 2, nil},
+		{"beyond the file size", "a", 8, 1, ErrFileTooLarge},
+		{"single chunk too large", "b", 0, 9, ErrFileTooLarge},
+		// 4 existing and 8 uploading leave 4 bytes of the quota
+		{"beyond the quota", "b", 0, 5, ErrQuotaExceeded},
+		{"fills the quota", "b", 0, 4, nil},
+		// Restarting an upload frees what it had written
+		{"restart within the quota", "a", 0, 8, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.WriteChunk(tt.file, tt.offset, make([]byte, tt.size))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteChunk = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Replacing a file counts only its new size
+	root = t.TempDir()
+	if s, err = NewStore(root); err != nil {
+		t.Fatal(err)
+	}
+	s.Quota = 16
+	upload(t, s, "f", make([]byte, 8))
+	upload(t, s, "f", make([]byte, 8))
+	upload(t, s, "g", make([]byte, 8))
+	if _, err := s.WriteChunk("h", 0, []byte{1}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("WriteChunk over a full quota = %v", err)
+	}
+
+	// Usage is measured again when the store is reopened
+	reopened, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.used != 16 {
+		t.Errorf("reopened store uses %d bytes, want 16", reopened.used)
+	}
+}
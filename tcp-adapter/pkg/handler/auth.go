@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"strings"
+	"tcp-adapter/pkg/protocol"
+)
+
+// authExempt lists commands that may run before authenticating
+var authExempt = map[string]bool{
+	"AUTH": true,
+	"PING": true,
+	"QUIT": true,
+	// RESUME restores an authenticated session from its secret token
+	"RESUME": true,
+}
+
+// AuthCommand returns the AUTH command, which marks the connection as
+// authenticated when the payload matches token
+func AuthCommand(token string) CommandFunc {
+	return func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		if subtle.ConstantTimeCompare([]byte(msg.Payload), []byte(token)) != 1 {
+			return protocol.ErrorMessage(protocol.CodeUnauthorized, "Invalid credentials")
+		}
+		ctx.SetAuthenticated(true)
+		return protocol.NewMessage("AUTH_OK", "Authenticated")
+	}
+}
+
+// RequireAuth rejects commands from unauthenticated connections, except
+// the ones needed to log in, check health or disconnect
+func RequireAuth() Interceptor {
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message {
+		if !ctx.Authenticated() && !authExempt[strings.ToUpper(msg.Command)] {
+			return protocol.ErrorMessage(protocol.CodeUnauthorized, "Authentication required")
+		}
+		return next(ctx, msg)
+	}
+}
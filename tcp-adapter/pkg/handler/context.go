@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/tracing"
+	"time"
+)
+
+// Context carries per-connection state to commands and interceptors.
+// A single Context lives for the whole connection, so values stored by
+// one command (e.g. authentication) are visible to the following ones.
+type Context struct {
+	// ID identifies the connection. Unlike RemoteAddr it is never shared
+	// with another connection, even across restarts.
+	ID          string
+	RemoteAddr  string
+	Listener    string
+	ConnectedAt time.Time
+
+	// authenticated is read by the connection's commands and written by
+	// AUTH or by a RESUME on another connection
+	authenticated atomic.Bool
+
+	// Trace is the span context of the request being executed, valid
+	// only while a command runs and only when tracing is enabled
+	Trace  tracing.SpanContext
+	tracer *tracing.Tracer
+
+	// send queues a message on the connection's write queue and calls
+	// written, if set, once it has been flushed
+	send func(msg *protocol.Message, written func()) error
+	// close closes the underlying connection
+	close func() error
+
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewContext creates the context for a connection from remoteAddr
+func NewContext(remoteAddr string) *Context {
+	return &Context{
+		ID:          newConnectionID(),
+		RemoteAddr:  remoteAddr,
+		ConnectedAt: time.Now(),
+		values:      make(map[string]interface{}),
+	}
+}
+
+// newConnectionID returns a random connection ID
+func newConnectionID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("handler: no randomness: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Set stores a value on the connection context
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// Get returns a value previously stored with Set
+func (c *Context) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// StartSpan starts a child span of the current request for work done by
+// a command. It returns nil (which is safe to use) if tracing is disabled.
+func (c *Context) StartSpan(name string) *tracing.Span {
+	if c.tracer == nil {
+		return nil
+	}
+	return c.tracer.StartSpan(name, c.Trace)
+}
+
+// Send pushes an unsolicited message to the client through the
+// connection's write queue, so a slow client never blocks the caller.
+// It fails once the connection is closed or if the queue overflowed.
+func (c *Context) Send(msg *protocol.Message) error {
+	return c.SendWritten(msg, nil)
+}
+
+// SendWritten is Send with a callback run on the connection's writer
+// goroutine once msg has been flushed to the client. The callback is not
+// run if the message is dropped or the connection fails first.
+func (c *Context) SendWritten(msg *protocol.Message, written func()) error {
+	if c.send == nil {
+		return ErrQueueClosed
+	}
+	return c.send(msg, written)
+}
+
+// Authenticated reports whether the connection has logged in
+func (c *Context) Authenticated() bool {
+	return c.authenticated.Load()
+}
+
+// SetAuthenticated marks the connection as logged in or out. It is safe
+// to call from any goroutine.
+func (c *Context) SetAuthenticated(authenticated bool) {
+	c.authenticated.Store(authenticated)
+}
+
+// Close closes the client connection, e.g. when another connection
+// takes over its session. Handle returns once the connection is closed.
+func (c *Context) Close() error {
+	if c.close == nil {
+		return nil
+	}
+	return c.close()
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"strings"
+	"tcp-adapter/pkg/protocol"
+)
+
+// AllowCommands rejects every command not in commands with FORBIDDEN.
+// AUTH, PING, QUIT and RESUME are always allowed so clients can still
+// log in, check health, disconnect and resume sessions.
+func AllowCommands(commands []string) Interceptor {
+	allowed := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		allowed[strings.ToUpper(strings.TrimSpace(command))] = true
+	}
+
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message {
+		command := strings.ToUpper(msg.Command)
+		if !allowed[command] && !authExempt[command] {
+			return protocol.Errorf(protocol.CodeForbidden, "Command %s is not enabled on listener %s", msg.Command, ctx.Listener).ToMessage()
+		}
+		return next(ctx, msg)
+	}
+}
@@ -4,95 +4,201 @@ import (
 	"bufio"
 	"log"
 	"net"
-	"strings"
+	"strconv"
 	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/tracing"
+	"time"
 )
 
+// drainWriteTimeout bounds how long a closing connection may take to
+// flush its write queue
+const drainWriteTimeout = 5 * time.Second
+
 // ConnectionHandler handles individual TCP connections
 type ConnectionHandler struct {
 	conn   net.Conn
 	reader *bufio.Reader
 	writer *bufio.Writer
+	router *Router
+	ctx    *Context
+	opts   Options
+
+	// dispatch runs the listener interceptors and then the router
+	dispatch  CommandFunc
+	malformed int
+
+	// queue holds outbound messages for the writer goroutine
+	queue *writeQueue
 }
 
-// NewConnectionHandler creates a new connection handler
-func NewConnectionHandler(conn net.Conn) *ConnectionHandler {
-	return &ConnectionHandler{
-		conn:   conn,
-		reader: bufio.NewReader(conn),
-		writer: bufio.NewWriter(conn),
+// NewConnectionHandler creates a new connection handler that dispatches
+// commands through router
+func NewConnectionHandler(conn net.Conn, router *Router, opts Options) *ConnectionHandler {
+	ctx := NewContext(conn.RemoteAddr().String())
+	ctx.Listener = opts.Listener
+	ctx.tracer = opts.Tracer
+
+	h := &ConnectionHandler{
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		writer:   bufio.NewWriter(conn),
+		router:   router,
+		ctx:      ctx,
+		opts:     opts,
+		dispatch: chain(opts.Interceptors, router.Dispatch),
 	}
+	h.queue = newWriteQueue(opts.WriteQueueSize, opts.OverflowPolicy, opts.QueueMetrics, func() {
+		log.Printf("Disconnecting slow consumer %s: write queue full", ctx.RemoteAddr)
+		conn.Close()
+	})
+	ctx.send = h.queueMessage
+	ctx.close = conn.Close
+	return h
 }
 
 // Handle processes messages from the connection
 func (h *ConnectionHandler) Handle() {
-	defer h.conn.Close()
-	
-	clientAddr := h.conn.RemoteAddr().String()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		if err := h.queue.run(h.writer); err != nil {
+			log.Printf("Write to %s failed: %v", h.ctx.RemoteAddr, err)
+			h.conn.Close()
+		}
+	}()
+	defer func() {
+		// Let the writer flush what is queued, but never wait forever
+		// on a client that stopped reading
+		h.queue.close()
+		h.conn.SetWriteDeadline(time.Now().Add(drainWriteTimeout))
+		<-written
+		h.conn.Close()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic while handling %s: %v", h.ctx.RemoteAddr, r)
+		}
+	}()
+	if h.opts.OnDisconnect != nil {
+		defer h.opts.OnDisconnect(h.ctx)
+	}
+
+	clientAddr := h.ctx.RemoteAddr
 	log.Printf("New connection from: %s", clientAddr)
 
 	// Send welcome message
 	welcome := protocol.NewMessage("WELCOME", "Connected to TCP Adapter Server")
+	if h.opts.OnConnect != nil {
+		h.opts.OnConnect(h.ctx, welcome)
+	}
 	h.SendMessage(welcome)
 
 	// Main message loop
 	for {
-		msg, err := protocol.Decode(h.reader)
+		line, err := protocol.ReadLine(h.reader)
 		if err != nil {
 			log.Printf("Connection closed from %s: %v", clientAddr, err)
 			return
 		}
+		received := time.Now()
 
-		log.Printf("Received from %s - Command: %s, Payload: %s", 
-			clientAddr, msg.Command, msg.Payload)
+		msg, err := protocol.Parse(line)
+		if err != nil {
+			if !h.tolerateMalformed(err) {
+				log.Printf("Closing connection from %s: too many malformed frames", clientAddr)
+				return
+			}
+			continue
+		}
 
-		// Process the message
+		if h.opts.Tracer != nil {
+			h.handleTraced(msg, received)
+			continue
+		}
 		response := h.processMessage(msg)
-		h.SendMessage(response)
+		if response != nil {
+			h.SendMessage(response)
+		}
 	}
 }
 
-// processMessage handles different message commands
-func (h *ConnectionHandler) processMessage(msg *protocol.Message) *protocol.Message {
-	switch strings.ToUpper(msg.Command) {
-	case "ECHO":
-		return protocol.NewMessage("ECHO_RESPONSE", msg.Payload)
-	
-	case "UPPER":
-		return protocol.NewMessage("UPPER_RESPONSE", strings.ToUpper(msg.Payload))
-	
-	case "LOWER":
-		return protocol.NewMessage("LOWER_RESPONSE", strings.ToLower(msg.Payload))
-	
-	case "REVERSE":
-		reversed := reverseString(msg.Payload)
-		return protocol.NewMessage("REVERSE_RESPONSE", reversed)
-	
-	case "PING":
-		return protocol.NewMessage("PONG", "alive")
-	
-	case "QUIT":
-		return protocol.NewMessage("BYE", "Goodbye!")
-	
-	default:
-		return protocol.NewMessage("ERROR", "Unknown command: "+msg.Command)
+// handleTraced processes a message like Handle does, recording a request
+// span with decode, execute and encode children. A valid traceparent on
+// the request parents the span and is answered with the request span's
+// own traceparent so the client can link the two.
+func (h *ConnectionHandler) handleTraced(msg *protocol.Message, received time.Time) {
+	decoded := time.Now()
+	tracer := h.opts.Tracer
+	incoming := msg.Header(tracing.TraceparentHeader)
+	parent, err := tracing.ParseTraceparent(incoming)
+	if incoming != "" && err != nil {
+		log.Printf("Ignoring invalid traceparent from %s: %q", h.ctx.RemoteAddr, incoming)
 	}
-}
 
-// SendMessage sends a message to the client
-func (h *ConnectionHandler) SendMessage(msg *protocol.Message) error {
-	_, err := h.writer.WriteString(msg.Encode())
-	if err != nil {
-		return err
+	request := tracer.StartSpanAt("request", parent, received)
+	request.SetAttribute("command", msg.Command)
+	request.SetAttribute("listener", h.ctx.Listener)
+	request.SetAttribute("remote_addr", h.ctx.RemoteAddr)
+	defer request.Finish()
+
+	decode := tracer.StartSpanAt("decode", request.Context(), received)
+	decode.SetAttribute("bytes", strconv.Itoa(len(msg.Payload)))
+	decode.FinishAt(decoded)
+
+	execute := tracer.StartSpan("execute", request.Context())
+	execute.SetAttribute("command", msg.Command)
+	h.ctx.Trace = request.Context()
+	response := h.processMessage(msg)
+	h.ctx.Trace = tracing.SpanContext{}
+	if perr, ok := protocol.ParseError(response); ok {
+		execute.SetAttribute("error_code", strconv.Itoa(int(perr.Code)))
+		execute.SetError(perr.Message)
+		request.SetError(perr.Message)
+	}
+	execute.Finish()
+
+	if response == nil {
+		return
 	}
-	return h.writer.Flush()
+	encode := tracer.StartSpan("encode", request.Context())
+	if parent.IsValid() {
+		response.SetHeader(tracing.TraceparentHeader, request.Context().Traceparent())
+	}
+	if err := h.SendMessage(response); err != nil {
+		encode.SetError(err.Error())
+	}
+	encode.Finish()
 }
 
-// reverseString reverses a string
-func reverseString(s string) string {
-	runes := []rune(s)
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+// tolerateMalformed answers a malformed frame with an error frame and
+// reports whether the connection may stay open
+func (h *ConnectionHandler) tolerateMalformed(err error) bool {
+	h.malformed++
+	log.Printf("Malformed frame %d from %s: %v", h.malformed, h.ctx.RemoteAddr, err)
+
+	limit := h.opts.MaxMalformedFrames
+	if limit >= 0 && h.malformed > limit {
+		h.SendMessage(protocol.ErrorMessage(protocol.CodeMalformedFrame, "Too many malformed frames, closing connection"))
+		return false
 	}
-	return string(runes)
+	h.SendMessage(protocol.ErrorMessage(protocol.CodeMalformedFrame, "Expected COMMAND:PAYLOAD"))
+	return true
+}
+
+// processMessage runs the command through the listener's interceptors
+// and the router's interceptor chain
+func (h *ConnectionHandler) processMessage(msg *protocol.Message) *protocol.Message {
+	return h.dispatch(h.ctx, msg)
+}
+
+// SendMessage queues a message for the client. It does not wait for the
+// message to be written; see OverflowPolicy for what happens when the
+// client reads slower than messages are produced.
+func (h *ConnectionHandler) SendMessage(msg *protocol.Message) error {
+	return h.queue.push(msg.Encode(), nil)
+}
+
+// queueMessage queues msg and calls written once it has been flushed
+func (h *ConnectionHandler) queueMessage(msg *protocol.Message, written func()) error {
+	return h.queue.push(msg.Encode(), written)
 }
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// Interceptor wraps a command invocation. It may inspect or modify the
+// message, call next to continue the chain, or return its own response
+// without calling next to short-circuit the command.
+type Interceptor func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message
+
+// chain composes interceptors around fn, first interceptor outermost
+func chain(interceptors []Interceptor, fn CommandFunc) CommandFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], fn
+		fn = func(ctx *Context, msg *protocol.Message) *protocol.Message {
+			return interceptor(ctx, msg, next)
+		}
+	}
+	return fn
+}
+
+// InterceptorRegistry holds named interceptors so the chain order can be
+// taken from configuration (e.g. "recovery,logging,auth,timing")
+type InterceptorRegistry struct {
+	mu           sync.RWMutex
+	interceptors map[string]Interceptor
+}
+
+// NewInterceptorRegistry creates a registry with the built-in
+// recovery, logging and timing interceptors
+func NewInterceptorRegistry() *InterceptorRegistry {
+	reg := &InterceptorRegistry{
+		interceptors: make(map[string]Interceptor),
+	}
+	reg.Register("recovery", Recovery())
+	reg.Register("logging", Logging())
+	reg.Register("timing", Timing())
+	return reg
+}
+
+// Register adds or replaces a named interceptor
+func (reg *InterceptorRegistry) Register(name string, interceptor Interceptor) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.interceptors[strings.ToLower(name)] = interceptor
+}
+
+// Build returns the interceptors for names in the given order
+func (reg *InterceptorRegistry) Build(names []string) ([]Interceptor, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	var result []Interceptor
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		interceptor, ok := reg.interceptors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown interceptor: %s", name)
+		}
+		result = append(result, interceptor)
+	}
+	return result, nil
+}
+
+// Recovery turns a panic in the rest of the chain into an INTERNAL error.
+// The router already isolates panics per command; placing Recovery in the
+// chain additionally lets outer interceptors observe the error response.
+func Recovery() Interceptor {
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) (response *protocol.Message) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic in command %s from %s: %v\n%s", msg.Command, ctx.RemoteAddr, r, debug.Stack())
+				response = protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+			}
+		}()
+		return next(ctx, msg)
+	}
+}
+
+// Logging logs every command and the response it produced
+func Logging() Interceptor {
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message {
+		log.Printf("Received from %s - Command: %s, Payload: %s",
+			ctx.RemoteAddr, msg.Command, truncate(msg.Payload, maxLoggedPayload))
+		response := next(ctx, msg)
+		if response != nil {
+			log.Printf("Responded to %s - Command: %s", ctx.RemoteAddr, response.Command)
+		}
+		return response
+	}
+}
+
+// maxLoggedPayload keeps large payloads such as file chunks out of the log
+const maxLoggedPayload = 200
+
+// truncate shortens s to at most n bytes for logging
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:n], len(s))
+}
+
+// Timing logs how long each command took to execute
+func Timing() Interceptor {
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message {
+		start := time.Now()
+		response := next(ctx, msg)
+		log.Printf("Command %s from %s took %s", msg.Command, ctx.RemoteAddr, time.Since(start))
+		return response
+	}
+}
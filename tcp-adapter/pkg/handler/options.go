@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/tracing"
+)
+
+// DefaultMaxMalformedFrames is the number of malformed frames tolerated
+// per connection before it is closed
+const DefaultMaxMalformedFrames = 3
+
+// Options configures the behaviour of a connection handler
+type Options struct {
+	// MaxMalformedFrames is how many malformed frames a connection may
+	// send before it is closed. Each one is answered with an error frame.
+	// A negative value tolerates any number of malformed frames.
+	MaxMalformedFrames int
+
+	// Listener is the name of the listener that accepted the connection
+	Listener string
+
+	// Interceptors run before the router's own chain. They let a listener
+	// restrict commands or require authentication for its connections only.
+	Interceptors []Interceptor
+
+	// Tracer records decode/execute/encode spans for every message when
+	// set; a traceparent header on the request makes them part of the
+	// caller's trace
+	Tracer *tracing.Tracer
+
+	// WriteQueueSize is how many outbound messages may wait for a slow
+	// client before OverflowPolicy applies
+	WriteQueueSize int
+	OverflowPolicy OverflowPolicy
+	// QueueMetrics, if set, receives the write queue counters; it is
+	// usually shared by every connection of a listener
+	QueueMetrics *QueueMetrics
+
+	// OnConnect runs before the welcome message is sent and may add
+	// headers to it; OnDisconnect runs once the client has gone
+	OnConnect    func(ctx *Context, welcome *protocol.Message)
+	OnDisconnect func(ctx *Context)
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		MaxMalformedFrames: DefaultMaxMalformedFrames,
+		WriteQueueSize:     DefaultWriteQueueSize,
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// DefaultPoolQueueSize is how many commands of one priority may wait for
+// a pool worker
+const DefaultPoolQueueSize = 1024
+
+// Priority orders queued commands; higher priorities run first
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ParsePriority parses low, normal or high
+func ParsePriority(name string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return 0, fmt.Errorf("unknown priority %q (want low, normal or high)", name)
+}
+
+// PoolConfig configures a command execution pool
+type PoolConfig struct {
+	// Workers is the number of commands executed at once across all
+	// connections; defaults to the number of CPUs
+	Workers int
+	// QueueSize is how many commands of each priority may wait for a
+	// worker before new ones are rejected with TOO_MANY_REQUESTS, so a
+	// flood of normal work never keeps high-priority commands out
+	QueueSize int
+	// Limits caps how many workers a command may occupy at once, so one
+	// expensive command cannot take the whole pool
+	Limits map[string]int
+	// Priorities overrides the normal priority per command. AUTH, PING and
+	// QUIT default to high so health checks are not stuck behind work.
+	Priorities map[string]Priority
+}
+
+// PoolStats is a snapshot of a pool's counters
+type PoolStats struct {
+	Workers   int
+	Busy      int
+	Queued    int
+	Submitted int64
+	Completed int64
+	Rejected  int64
+	// TotalWait and MaxWait measure the time commands spent queued
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// AvgWait returns the mean queue time of started commands
+func (s PoolStats) AvgWait() time.Duration {
+	started := s.Completed + int64(s.Busy)
+	if started == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(started)
+}
+
+// poolJob is one command waiting for or running on a worker
+type poolJob struct {
+	command  string
+	run      func()
+	enqueued time.Time
+}
+
+// Pool executes commands on a fixed set of workers. Connections still
+// process their own commands in order; the pool bounds how many run at
+// the same time across all connections.
+type Pool struct {
+	config PoolConfig
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  [PriorityHigh + 1][]*poolJob
+	queued  int
+	running map[string]int
+	busy    int
+	closed  bool
+	stats   PoolStats
+}
+
+// NewPool starts the workers of a pool
+func NewPool(config PoolConfig) *Pool {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultPoolQueueSize
+	}
+	limits := make(map[string]int, len(config.Limits))
+	for command, limit := range config.Limits {
+		limits[strings.ToUpper(command)] = limit
+	}
+	priorities := map[string]Priority{
+		"AUTH": PriorityHigh,
+		"PING": PriorityHigh,
+		"QUIT": PriorityHigh,
+	}
+	for command, priority := range config.Priorities {
+		priorities[strings.ToUpper(command)] = priority
+	}
+	config.Limits, config.Priorities = limits, priorities
+
+	p := &Pool{
+		config:  config,
+		running: make(map[string]int),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.stats.Workers = config.Workers
+	for i := 0; i < config.Workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Interceptor runs the rest of the chain on a pool worker and waits for
+// it. Commands that cannot be queued get TOO_MANY_REQUESTS. Place it last
+// so outer interceptors (logging, auth, ...) run on the connection.
+func (p *Pool) Interceptor() Interceptor {
+	return func(ctx *Context, msg *protocol.Message, next CommandFunc) *protocol.Message {
+		var response *protocol.Message
+		done := make(chan struct{})
+		command := strings.ToUpper(msg.Command)
+		queued := ctx.StartSpan("queue")
+		job := &poolJob{
+			command: command,
+			run: func() {
+				queued.Finish()
+				defer close(done)
+				defer func() {
+					// The connection's own recovery cannot see panics on a worker
+					if rec := recover(); rec != nil {
+						log.Printf("Panic in command %s from %s: %v\n%s", msg.Command, ctx.RemoteAddr, rec, debug.Stack())
+						response = protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+					}
+				}()
+				response = next(ctx, msg)
+			},
+		}
+		if !p.submit(job) {
+			queued.SetError("queue full")
+			queued.Finish()
+			return protocol.Errorf(protocol.CodeTooManyRequests, "Server busy, %s not queued", msg.Command).ToMessage()
+		}
+		<-done
+		return response
+	}
+}
+
+// submit queues job, reporting false if the queue is full or closed
+func (p *Pool) submit(job *poolJob) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	priority, ok := p.config.Priorities[job.command]
+	if !ok {
+		priority = PriorityNormal
+	}
+	if p.closed || len(p.queues[priority]) >= p.config.QueueSize {
+		p.stats.Rejected++
+		return false
+	}
+	job.enqueued = time.Now()
+	p.queues[priority] = append(p.queues[priority], job)
+	p.queued++
+	p.stats.Submitted++
+	p.cond.Signal()
+	return true
+}
+
+// work runs queued jobs until the pool is closed
+func (p *Pool) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		job := p.next()
+		for job == nil {
+			if p.closed && p.queued == 0 {
+				return
+			}
+			p.cond.Wait()
+			job = p.next()
+		}
+
+		wait := time.Since(job.enqueued)
+		p.stats.TotalWait += wait
+		if wait > p.stats.MaxWait {
+			p.stats.MaxWait = wait
+		}
+		p.running[job.command]++
+		p.busy++
+		p.mu.Unlock()
+
+		job.run()
+
+		p.mu.Lock()
+		p.busy--
+		p.running[job.command]--
+		p.stats.Completed++
+		// A command below its limit again may unblock a waiting job
+		p.cond.Broadcast()
+	}
+}
+
+// next removes the highest-priority job whose command is below its limit
+func (p *Pool) next() *poolJob {
+	for priority := PriorityHigh; priority >= PriorityLow; priority-- {
+		queue := p.queues[priority]
+		for i, job := range queue {
+			if limit, ok := p.config.Limits[job.command]; ok && p.running[job.command] >= limit {
+				continue
+			}
+			copy(queue[i:], queue[i+1:])
+			queue[len(queue)-1] = nil
+			p.queues[priority] = queue[:len(queue)-1]
+			p.queued--
+			return job
+		}
+	}
+	return nil
+}
+
+// Stats returns the pool's counters
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Busy = p.busy
+	stats.Queued = p.queued
+	return stats
+}
+
+// Close stops the workers once the queued commands have run
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
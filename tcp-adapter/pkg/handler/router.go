@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+)
+
+// CommandFunc executes a single command and returns the response
+type CommandFunc func(ctx *Context, msg *protocol.Message) *protocol.Message
+
+// Router maps command names to their implementations and wraps every
+// invocation in the configured interceptor chain
+type Router struct {
+	mu           sync.RWMutex
+	commands     map[string]CommandFunc
+	interceptors []Interceptor
+}
+
+// NewRouter creates a router with the built-in commands registered
+func NewRouter() *Router {
+	r := &Router{
+		commands: make(map[string]CommandFunc),
+	}
+	registerBuiltins(r)
+	return r
+}
+
+// Handle registers fn for command, replacing any existing implementation.
+// Command names are case-insensitive.
+func (r *Router) Handle(command string, fn CommandFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[strings.ToUpper(command)] = fn
+}
+
+// Use appends interceptors to the chain. The first interceptor added is
+// the outermost one and sees every command first.
+func (r *Router) Use(interceptors ...Interceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interceptors = append(r.interceptors, interceptors...)
+}
+
+// Dispatch runs the command named by msg through the interceptor chain.
+// A panic anywhere in the chain is contained to this command and answered
+// with an INTERNAL error, so it never takes down the connection or server.
+func (r *Router) Dispatch(ctx *Context, msg *protocol.Message) (response *protocol.Message) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Panic in command %s from %s: %v\n%s", msg.Command, ctx.RemoteAddr, rec, debug.Stack())
+			response = protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+		}
+	}()
+
+	r.mu.RLock()
+	fn, ok := r.commands[strings.ToUpper(msg.Command)]
+	interceptors := r.interceptors
+	r.mu.RUnlock()
+
+	if !ok {
+		fn = unknownCommand
+	}
+	return chain(interceptors, fn)(ctx, msg)
+}
+
+// unknownCommand is invoked for commands without a registered handler
+func unknownCommand(ctx *Context, msg *protocol.Message) *protocol.Message {
+	return protocol.ErrorMessage(protocol.CodeUnknownCommand, "Unknown command: "+msg.Command)
+}
+
+// registerBuiltins adds the commands every adapter supports
+func registerBuiltins(r *Router) {
+	r.Handle("ECHO", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("ECHO_RESPONSE", msg.Payload)
+	})
+
+	r.Handle("UPPER", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("UPPER_RESPONSE", strings.ToUpper(msg.Payload))
+	})
+
+	r.Handle("LOWER", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("LOWER_RESPONSE", strings.ToLower(msg.Payload))
+	})
+
+	r.Handle("REVERSE", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("REVERSE_RESPONSE", reverseString(msg.Payload))
+	})
+
+	r.Handle("PING", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("PONG", "alive")
+	})
+
+	r.Handle("QUIT", func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("BYE", "Goodbye!")
+	})
+}
+
+// reverseString reverses a string
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
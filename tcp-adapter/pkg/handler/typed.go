@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/validate"
+)
+
+// validator is implemented by requests with checks that tags cannot express
+type validator interface {
+	Validate() error
+}
+
+// TypedFunc implements a command with JSON request and response bodies
+type TypedFunc[Req, Resp any] func(ctx *Context, req *Req) (*Resp, error)
+
+// JSON adapts fn to a CommandFunc. The payload is decoded into Req
+// (unknown fields are rejected) and validated with its `validate` tags
+// and its Validate method, if any, before fn runs. Failures are answered
+// with BAD_REQUEST and the field errors in the fields header. The
+// response is Resp encoded as JSON under responseCommand. A
+// *protocol.Error returned by fn is sent as-is, any other error as
+// INTERNAL.
+//
+// Malformed `validate` tags on Req are logged when JSON is called and
+// every request is then answered with INTERNAL.
+func JSON[Req, Resp any](responseCommand string, fn TypedFunc[Req, Resp]) CommandFunc {
+	tagErr := validate.Type(reflect.TypeOf((*Req)(nil)))
+	if tagErr != nil {
+		log.Printf("Command %s cannot validate its requests: %v", responseCommand, tagErr)
+	}
+	return func(ctx *Context, msg *protocol.Message) *protocol.Message {
+		if tagErr != nil {
+			return protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+		}
+		req := new(Req)
+		if err := decodeJSON(msg.Payload, req); err != nil {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, "Invalid JSON payload: "+err.Error())
+		}
+		if err := validate.Struct(req); err != nil {
+			return validationError(err)
+		}
+		if v, ok := interface{}(req).(validator); ok {
+			if err := v.Validate(); err != nil {
+				return validationError(err)
+			}
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			var perr *protocol.Error
+			if errors.As(err, &perr) {
+				return perr.ToMessage()
+			}
+			var verr validate.Errors
+			if errors.As(err, &verr) {
+				return validationError(verr)
+			}
+			log.Printf("Command %s from %s failed: %v", msg.Command, ctx.RemoteAddr, err)
+			return protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+		}
+
+		body, err := json.Marshal(resp)
+		if err != nil {
+			log.Printf("Encoding %s response failed: %v", msg.Command, err)
+			return protocol.ErrorMessage(protocol.CodeInternal, "Internal error")
+		}
+		response := protocol.NewMessage(responseCommand, string(body))
+		response.SetHeader(protocol.HeaderContentType, protocol.ContentTypeJSON)
+		return response
+	}
+}
+
+// decodeJSON strictly decodes a single JSON value; an empty payload is
+// treated as an empty object
+func decodeJSON(payload string, v interface{}) error {
+	if payload == "" {
+		payload = "{}"
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// validationError builds the BAD_REQUEST answer for failed validation
+func validationError(err error) *protocol.Message {
+	response := protocol.ErrorMessage(protocol.CodeBadRequest, "Validation failed: "+err.Error())
+	var fields validate.Errors
+	if errors.As(err, &fields) {
+		if encoded, err := json.Marshal(fields); err == nil {
+			response.SetHeader(protocol.HeaderFields, string(encoded))
+		}
+	}
+	return response
+}
@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"tcp-adapter/pkg/adaptertest"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/validate"
+	"testing"
+)
+
+type bookRequest struct {
+	Flight string `json:"flight" validate:"required,min=3"`
+	Seats  int    `json:"seats" validate:"min=1,max=9"`
+}
+
+// Validate rejects what the tags cannot express
+func (r *bookRequest) Validate() error {
+	if r.Flight == "XX000" {
+		return errors.New("flight XX000 is not bookable")
+	}
+	return nil
+}
+
+type bookResponse struct {
+	ID string `json:"id"`
+}
+
+func book(ctx *handler.Context, req *bookRequest) (*bookResponse, error) {
+	switch req.Flight {
+	case "FULL":
+		return nil, protocol.NewError(protocol.CodeConflict, "Flight is full")
+	case "BROKEN":
+		return nil, errors.New("database unavailable")
+	}
+	return &bookResponse{ID: req.Flight + "-1"}, nil
+}
+
+func TestJSON(t *testing.T) {
+	srv := adaptertest.NewServer(t)
+	srv.Router().Handle("BOOK", handler.JSON("BOOKED", book))
+	c := srv.Client()
+
+	got := c.Expect(`BOOK:{"flight":"AI101","seats":2}`, `BOOKED:{"id":"AI101-1"}`)
+	if ct := got.Header(protocol.HeaderContentType); ct != protocol.ContentTypeJSON {
+		t.Errorf("content type = %q", ct)
+	}
+
+	c.ExpectError(`BOOK:{"flight":`, protocol.CodeBadRequest)
+	c.ExpectError(`BOOK:{"flight":"AI101","seats":2,"meal":"veg"}`, protocol.CodeBadRequest)
+	c.ExpectError(`BOOK:{"flight":"AI101","seats":2} {}`, protocol.CodeBadRequest)
+	c.ExpectError(`BOOK:{"flight":"XX000","seats":1}`, protocol.CodeBadRequest)
+	c.ExpectError(`BOOK:{"flight":"FULL","seats":1}`, protocol.CodeConflict)
+	c.ExpectError(`BOOK:{"flight":"BROKEN","seats":1}`, protocol.CodeInternal)
+
+	c.Write(adaptertest.ParseMessage(t, "BOOK:"))
+	invalid := c.Read()
+	adaptertest.AssertError(t, invalid, protocol.CodeBadRequest)
+	var fields validate.Errors
+	if err := json.Unmarshal([]byte(invalid.Header(protocol.HeaderFields)), &fields); err != nil {
+		t.Fatalf("fields header: %v", err)
+	}
+	want := validate.Errors{{Field: "flight", Error: "is required"}, {Field: "seats", Error: "must be at least 1"}}
+	if len(fields) != len(want) || fields[0] != want[0] || fields[1] != want[1] {
+		t.Errorf("fields = %+v, want %+v", fields, want)
+	}
+}
+
+func TestJSONMalformedTags(t *testing.T) {
+	type request struct {
+		Name string `validate:"requird"`
+	}
+	srv := adaptertest.NewServer(t)
+	srv.Router().Handle("HELLO", handler.JSON("HELLO", func(ctx *handler.Context, req *request) (*request, error) {
+		t.Error("handler ran despite a malformed tag")
+		return req, nil
+	}))
+	srv.Client().ExpectError(`HELLO:{"Name":"bob"}`, protocol.CodeInternal)
+}
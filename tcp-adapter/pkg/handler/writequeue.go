@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWriteQueueSize is the number of outbound messages buffered per
+// connection before the overflow policy applies
+const DefaultWriteQueueSize = 256
+
+// OverflowPolicy decides what happens when a connection's write queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDisconnect closes the connection of a client that cannot keep up
+	OverflowDisconnect OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message to make room
+	OverflowDropOldest
+	// OverflowDropNewest discards the message being queued
+	OverflowDropNewest
+)
+
+var overflowPolicies = map[string]OverflowPolicy{
+	"disconnect":  OverflowDisconnect,
+	"drop-oldest": OverflowDropOldest,
+	"drop-newest": OverflowDropNewest,
+}
+
+// String returns the name used by ParseOverflowPolicy
+func (p OverflowPolicy) String() string {
+	for name, policy := range overflowPolicies {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// ParseOverflowPolicy parses disconnect, drop-oldest or drop-newest
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	policy, ok := overflowPolicies[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown overflow policy %q (want disconnect, drop-oldest or drop-newest)", name)
+	}
+	return policy, nil
+}
+
+var (
+	// ErrMessageDropped is returned by SendMessage when the drop-newest
+	// policy discarded the message
+	ErrMessageDropped = errors.New("write queue full, message dropped")
+	// ErrSlowConsumer is returned by SendMessage when the connection was
+	// closed because its write queue overflowed
+	ErrSlowConsumer = errors.New("write queue full, slow consumer disconnected")
+	// ErrQueueClosed is returned by SendMessage after the connection ended
+	ErrQueueClosed = errors.New("write queue closed")
+)
+
+// QueueMetrics aggregates write queue counters. One instance is usually
+// shared by every connection of a listener; all fields are updated
+// atomically and should be read with LoadQueueMetrics.
+type QueueMetrics struct {
+	// Depth is the number of messages currently queued
+	Depth int64
+	// MaxDepth is the highest depth a single connection has reached
+	MaxDepth int64
+	// Sent counts messages written to clients
+	Sent int64
+	// Dropped counts messages discarded by a drop policy
+	Dropped int64
+	// Disconnects counts connections closed as slow consumers
+	Disconnects int64
+}
+
+// LoadQueueMetrics returns a consistent-enough snapshot of m
+func LoadQueueMetrics(m *QueueMetrics) QueueMetrics {
+	return QueueMetrics{
+		Depth:       atomic.LoadInt64(&m.Depth),
+		MaxDepth:    atomic.LoadInt64(&m.MaxDepth),
+		Sent:        atomic.LoadInt64(&m.Sent),
+		Dropped:     atomic.LoadInt64(&m.Dropped),
+		Disconnects: atomic.LoadInt64(&m.Disconnects),
+	}
+}
+
+// queued is an encoded message waiting to be written
+type queued struct {
+	line string
+	// written, if set, is called once line has been flushed
+	written func()
+}
+
+// writeQueue buffers encoded messages for a connection's writer goroutine
+type writeQueue struct {
+	mu      sync.Mutex
+	pending []queued
+	closed  bool
+	err     error
+
+	capacity int
+	policy   OverflowPolicy
+	metrics  *QueueMetrics
+
+	// ready is signalled when messages are queued or the queue is closed
+	ready chan struct{}
+	// overflow is called once, outside the lock, when a slow consumer
+	// has to be disconnected
+	overflow func()
+}
+
+func newWriteQueue(capacity int, policy OverflowPolicy, metrics *QueueMetrics, overflow func()) *writeQueue {
+	if capacity <= 0 {
+		capacity = DefaultWriteQueueSize
+	}
+	if metrics == nil {
+		metrics = &QueueMetrics{}
+	}
+	return &writeQueue{
+		capacity: capacity,
+		policy:   policy,
+		metrics:  metrics,
+		ready:    make(chan struct{}, 1),
+		overflow: overflow,
+	}
+}
+
+// push queues an encoded message, applying the overflow policy when full.
+// written is called from the writer goroutine after line is flushed; it
+// is never called if the message is dropped or the write fails.
+func (q *writeQueue) push(line string, written func()) error {
+	q.mu.Lock()
+	if q.closed {
+		err := q.err
+		q.mu.Unlock()
+		if err == nil {
+			err = ErrQueueClosed
+		}
+		return err
+	}
+
+	if len(q.pending) >= q.capacity {
+		switch q.policy {
+		case OverflowDropOldest:
+			q.pending[0] = queued{}
+			q.pending = q.pending[1:]
+			atomic.AddInt64(&q.metrics.Dropped, 1)
+			atomic.AddInt64(&q.metrics.Depth, -1)
+		case OverflowDropNewest:
+			q.mu.Unlock()
+			atomic.AddInt64(&q.metrics.Dropped, 1)
+			return ErrMessageDropped
+		default:
+			q.closeLocked(ErrSlowConsumer)
+			q.mu.Unlock()
+			atomic.AddInt64(&q.metrics.Disconnects, 1)
+			if q.overflow != nil {
+				q.overflow()
+			}
+			return ErrSlowConsumer
+		}
+	}
+
+	q.pending = append(q.pending, queued{line: line, written: written})
+	depth := int64(len(q.pending))
+	q.mu.Unlock()
+
+	atomic.AddInt64(&q.metrics.Depth, 1)
+	for {
+		max := atomic.LoadInt64(&q.metrics.MaxDepth)
+		if depth <= max || atomic.CompareAndSwapInt64(&q.metrics.MaxDepth, max, depth) {
+			break
+		}
+	}
+	q.signal()
+	return nil
+}
+
+// close stops accepting messages; already queued ones are still written
+func (q *writeQueue) close() {
+	q.mu.Lock()
+	q.closeLocked(nil)
+	q.mu.Unlock()
+}
+
+func (q *writeQueue) closeLocked(err error) {
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.err = err
+	if err != nil {
+		// A slow consumer is disconnected, nothing more is written
+		atomic.AddInt64(&q.metrics.Depth, -int64(len(q.pending)))
+		q.pending = nil
+	}
+	q.signal()
+}
+
+func (q *writeQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns every queued message. It reports false once
+// the queue is closed and empty.
+func (q *writeQueue) take(batch []queued) ([]queued, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			batch = append(batch[:0], q.pending...)
+			q.pending = q.pending[:0]
+			q.mu.Unlock()
+			return batch, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return nil, false
+		}
+		<-q.ready
+	}
+}
+
+// run writes queued messages until the queue is closed and drained,
+// flushing once per batch so a burst costs a single syscall
+func (q *writeQueue) run(writer *bufio.Writer) error {
+	var batch []queued
+	for {
+		var ok bool
+		batch, ok = q.take(batch)
+		if !ok {
+			return nil
+		}
+		err := writeBatch(writer, batch)
+		atomic.AddInt64(&q.metrics.Depth, -int64(len(batch)))
+		if err != nil {
+			q.fail(err)
+			return err
+		}
+		atomic.AddInt64(&q.metrics.Sent, int64(len(batch)))
+		for i := range batch {
+			if batch[i].written != nil {
+				batch[i].written()
+			}
+			batch[i] = queued{}
+		}
+	}
+}
+
+func writeBatch(writer *bufio.Writer, batch []queued) error {
+	for _, msg := range batch {
+		if _, err := writer.WriteString(msg.line); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// fail closes the queue after a write error and discards what is left
+func (q *writeQueue) fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	if q.err == nil {
+		q.err = err
+	}
+	atomic.AddInt64(&q.metrics.Depth, -int64(len(q.pending)))
+	q.pending = nil
+}
@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrorCommand is the command of every error frame
+const ErrorCommand = "ERROR"
+
+// ErrorCode identifies the class of a protocol error
+type ErrorCode int
+
+// Error codes follow HTTP status semantics so they are familiar to
+// clients. The exception is CodeNotFound: 404 already means an unknown
+// command, so missing resources are 410 whether or not they ever existed.
+const (
+	CodeBadRequest   ErrorCode = 400
+	CodeUnauthorized ErrorCode = 401
+	// CodePaymentRequired is for payments that were declined
+	CodePaymentRequired ErrorCode = 402
+	CodeForbidden       ErrorCode = 403
+	CodeUnknownCommand  ErrorCode = 404
+	CodeConflict        ErrorCode = 409
+	// CodeNotFound is for missing resources; 404 means an unknown command
+	CodeNotFound        ErrorCode = 410
+	CodeMalformedFrame  ErrorCode = 422
+	CodeTooManyRequests ErrorCode = 429
+	CodeInternal        ErrorCode = 500
+	CodeUnavailable     ErrorCode = 503
+	CodeTimeout         ErrorCode = 504
+)
+
+var codeNames = map[ErrorCode]string{
+	CodeBadRequest:      "BAD_REQUEST",
+	CodeUnauthorized:    "UNAUTHORIZED",
+	CodePaymentRequired: "PAYMENT_REQUIRED",
+	CodeForbidden:       "FORBIDDEN",
+	CodeUnknownCommand:  "UNKNOWN_COMMAND",
+	CodeConflict:        "CONFLICT",
+	CodeNotFound:        "NOT_FOUND",
+	CodeMalformedFrame:  "MALFORMED_FRAME",
+	CodeTooManyRequests: "TOO_MANY_REQUESTS",
+	CodeInternal:        "INTERNAL",
+	CodeUnavailable:     "UNAVAILABLE",
+	CodeTimeout:         "TIMEOUT",
+}
+
+// String returns the symbolic name of the code
+func (c ErrorCode) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// Retryable reports whether a request failing with this code may succeed
+// if sent again unchanged
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case CodeTooManyRequests, CodeInternal, CodeUnavailable, CodeTimeout:
+		return true
+	}
+	return false
+}
+
+// ErrMalformedFrame is returned by Decode for lines that are not valid
+// messages. The connection is still usable after this error.
+var ErrMalformedFrame = errors.New("malformed frame")
+
+// ErrConnectionClosed is returned by Decode when the peer closed the connection
+var ErrConnectionClosed = errors.New("connection closed")
+
+// Error is a typed protocol error sent to clients as an error frame
+type Error struct {
+	Code      ErrorCode
+	Message   string
+	Retryable bool
+}
+
+// NewError creates an error whose retryable flag is derived from code
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{
+		Code:      code,
+		Message:   message,
+		Retryable: code.Retryable(),
+	}
+}
+
+// Errorf creates an error with a formatted message
+func Errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return NewError(code, fmt.Sprintf(format, args...))
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, e.Code, e.Message)
+}
+
+// ToMessage converts the error into a standard error frame.
+// Format: ERROR:<code>:<name>:<retryable>:<message>
+func (e *Error) ToMessage() *Message {
+	payload := fmt.Sprintf("%d:%s:%t:%s", e.Code, e.Code, e.Retryable, e.Message)
+	return NewMessage(ErrorCommand, payload)
+}
+
+// ErrorMessage is a shorthand for NewError(code, message).ToMessage()
+func ErrorMessage(code ErrorCode, message string) *Message {
+	return NewError(code, message).ToMessage()
+}
+
+// ParseError extracts the typed error from an error frame.
+// It returns false if msg is not a well-formed error frame.
+func ParseError(msg *Message) (*Error, bool) {
+	if msg == nil || !strings.EqualFold(msg.Command, ErrorCommand) {
+		return nil, false
+	}
+
+	parts := strings.SplitN(msg.Payload, ":", 4)
+	if len(parts) != 4 {
+		return nil, false
+	}
+	code, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	retryable, err := strconv.ParseBool(parts[2])
+	if err != nil {
+		return nil, false
+	}
+
+	return &Error{
+		Code:      ErrorCode(code),
+		Message:   parts[3],
+		Retryable: retryable,
+	}, true
+}
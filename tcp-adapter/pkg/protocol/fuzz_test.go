@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func FuzzDecode(f *testing.F) {
+	f.Add("ECHO:Hello World\n")
+	f.Add("PING:\n")
+	f.Add("no separator\n")
+	f.Add("A:B:C")
+	f.Add("\n\n")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		reader := bufio.NewReader(strings.NewReader(input))
+		for {
+			msg, err := Decode(reader)
+			if errors.Is(err, ErrMalformedFrame) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			if strings.Contains(msg.Command, ":") {
+				t.Fatalf("command %q contains the separator", msg.Command)
+			}
+			if strings.ContainsAny(msg.Command+msg.Payload, "\n") {
+				t.Fatalf("decoded message %q spans lines", msg.Encode())
+			}
+		}
+	})
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	f.Add("ECHO", "Hello World", "", "")
+	f.Add("PING", "", "", "")
+	f.Add("SET", "key:value", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	f.Add("SET", "x", "a:b&c=d", "%zz?")
+
+	f.Fuzz(func(t *testing.T, command, payload, headerKey, headerValue string) {
+		if command == "" || strings.ContainsAny(command, ":?\r\n") || strings.ContainsAny(payload, "\r\n") {
+			t.Skip()
+		}
+		if strings.TrimSpace(command) != command || strings.TrimSpace(payload) != payload {
+			t.Skip()
+		}
+
+		original := NewMessage(command, payload)
+		if headerKey != "" {
+			original.SetHeader(headerKey, headerValue)
+		}
+		encoded := original.Encode()
+		msg, err := Decode(bufio.NewReader(strings.NewReader(encoded)))
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", encoded, err)
+		}
+		if msg.Command != command || msg.Payload != payload {
+			t.Fatalf("round trip of %q = %q:%q", encoded, msg.Command, msg.Payload)
+		}
+		if headerKey != "" && msg.Header(headerKey) != headerValue {
+			t.Fatalf("round trip of %q lost header %q=%q", encoded, headerKey, headerValue)
+		}
+	})
+}
+
+func FuzzParseError(f *testing.F) {
+	f.Add("404:UNKNOWN_COMMAND:false:Unknown command: FOO")
+	f.Add("500:INTERNAL:true:")
+	f.Add("x:y:z")
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		protoErr, ok := ParseError(NewMessage(ErrorCommand, payload))
+		if !ok {
+			return
+		}
+		again, ok := ParseError(protoErr.ToMessage())
+		if !ok {
+			t.Fatalf("re-encoded error %q does not parse", protoErr.ToMessage().Payload)
+		}
+		if *again != *protoErr {
+			t.Fatalf("round trip = %+v, want %+v", again, protoErr)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package protocol
+
+// Well-known message headers
+const (
+	// HeaderContentType names the payload encoding, e.g. ContentTypeJSON
+	HeaderContentType = "content-type"
+	// HeaderFields carries a JSON list of field errors on BAD_REQUEST
+	// answers to typed commands
+	HeaderFields = "fields"
+	// HeaderSession carries the session token on WELCOME and RESUMED
+	HeaderSession = "session"
+	// HeaderSeq numbers the messages pushed to a session
+	HeaderSeq = "seq"
+	// HeaderLost on RESUMED counts messages that could not be replayed
+	HeaderLost = "lost"
+)
+
+// MessageCommand is the command of pub/sub messages pushed to subscribers.
+// Format: MESSAGE:<topic>:<payload>
+const MessageCommand = "MESSAGE"
+
+// ContentTypeJSON marks payloads encoded as JSON
+const ContentTypeJSON = "json"
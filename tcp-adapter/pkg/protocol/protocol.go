@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net/url"
 	"strings"
 )
 
@@ -11,38 +12,92 @@ import (
 type Message struct {
 	Command string
 	Payload string
+	// Headers carry optional metadata such as trace context. They are
+	// omitted from the wire format when empty.
+	Headers map[string]string
 }
 
 // Encode converts a Message to string format for transmission
-// Format: COMMAND:PAYLOAD\n
+// Format: COMMAND:PAYLOAD\n, or COMMAND?k1=v1&k2=v2:PAYLOAD\n with headers
+// (keys and values are URL query encoded)
 func (m *Message) Encode() string {
-	return fmt.Sprintf("%s:%s\n", m.Command, m.Payload)
+	if len(m.Headers) == 0 {
+		return fmt.Sprintf("%s:%s\n", m.Command, m.Payload)
+	}
+
+	values := url.Values{}
+	for key, value := range m.Headers {
+		values.Set(key, value)
+	}
+	return fmt.Sprintf("%s?%s:%s\n", m.Command, values.Encode(), m.Payload)
 }
 
-// Decode reads and parses a message from a reader
+// Header returns the value of a header, or "" if it is not set
+func (m *Message) Header(key string) string {
+	return m.Headers[key]
+}
+
+// SetHeader sets a header value
+func (m *Message) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// Decode reads and parses a message from a reader.
+// A line without a COMMAND:PAYLOAD separator yields an error wrapping
+// ErrMalformedFrame; the reader is positioned at the next line.
 func Decode(reader *bufio.Reader) (*Message, error) {
+	line, err := ReadLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(line)
+}
+
+// ReadLine reads the next raw frame from a reader without parsing it
+func ReadLine(reader *bufio.Reader) (string, error) {
 	// Read until newline
 	line, err := reader.ReadString('\n')
 	if err != nil {
 		if err == io.EOF {
-			return nil, fmt.Errorf("connection closed")
+			return "", ErrConnectionClosed
 		}
-		return nil, err
+		return "", err
 	}
+	return line, nil
+}
 
+// Parse parses a single frame as returned by ReadLine
+func Parse(line string) (*Message, error) {
 	// Remove trailing newline
 	line = strings.TrimSpace(line)
 
 	// Split by colon
 	parts := strings.SplitN(line, ":", 2)
 	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid message format: %s", line)
+		return nil, fmt.Errorf("%w: invalid message format: %s", ErrMalformedFrame, line)
 	}
 
-	return &Message{
+	msg := &Message{
 		Command: parts[0],
 		Payload: parts[1],
-	}, nil
+	}
+
+	// Headers follow the command after '?'
+	if command, query, found := strings.Cut(parts[0], "?"); found {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid headers: %v", ErrMalformedFrame, err)
+		}
+		msg.Command = command
+		for key := range values {
+			msg.SetHeader(key, values.Get(key))
+		}
+	}
+
+	return msg, nil
 }
 
 // NewMessage creates a new message
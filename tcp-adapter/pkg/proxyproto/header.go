@@ -0,0 +1,160 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// v1Prefix starts every version 1 (text) header
+var v1Prefix = []byte("PROXY ")
+
+// v2Signature starts every version 2 (binary) header
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// v1MaxLength is the longest valid v1 header including CRLF
+const v1MaxLength = 107
+
+// ErrNoHeader is returned when a connection does not start with a PROXY header
+var ErrNoHeader = errors.New("proxyproto: missing PROXY protocol header")
+
+// Header is the information carried by a PROXY protocol header
+type Header struct {
+	Version     int
+	Source      net.Addr
+	Destination net.Addr
+	// Local is true for health checks sent by the proxy itself; the
+	// connection's own addresses should be used in that case
+	Local bool
+}
+
+// ReadHeader reads a v1 or v2 header from r
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	peek, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(peek, v1Prefix) {
+		return readV1(r)
+	}
+
+	peek, err = r.Peek(len(v2Signature))
+	if err == nil && bytes.Equal(peek, v2Signature) {
+		return readV2(r)
+	}
+	return nil, ErrNoHeader
+}
+
+// readV1 parses "PROXY TCP4 src dst sport dport\r\n"
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("proxyproto: v1 header not terminated by CRLF")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("proxyproto: invalid v1 header")
+	}
+	if fields[1] == "UNKNOWN" {
+		return &Header{Version: 1, Local: true}, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("proxyproto: invalid v1 header")
+	}
+
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Version: 1, Source: src, Destination: dst}, nil
+}
+
+func parseV1Addr(family, ipText, portText string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(ipText)
+	if ip == nil || (family == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("proxyproto: invalid %s address %q", family, ipText)
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxyproto: invalid port %q", portText)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 parses the binary header, ignoring any TLVs
+func readV2(r *bufio.Reader) (*Header, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+
+	versionCommand := fixed[12]
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("proxyproto: unsupported v2 version %d", versionCommand>>4)
+	}
+	family := fixed[13]
+	length := int(binary.BigEndian.Uint16(fixed[14:16]))
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	switch versionCommand & 0x0F {
+	case 0x0: // LOCAL
+		return &Header{Version: 2, Local: true}, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("proxyproto: unsupported v2 command %d", versionCommand&0x0F)
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case 0x1: // AF_INET
+		ipLen = net.IPv4len
+	case 0x2: // AF_INET6
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC or AF_UNIX carry nothing usable as a client address
+		return &Header{Version: 2, Local: true}, nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, fmt.Errorf("proxyproto: v2 address block too short")
+	}
+
+	srcIP := net.IP(append([]byte{}, body[:ipLen]...))
+	dstIP := net.IP(append([]byte{}, body[ipLen:2*ipLen]...))
+	srcPort := int(binary.BigEndian.Uint16(body[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(body[2*ipLen+2:]))
+
+	header := &Header{Version: 2}
+	if family&0x0F == 0x2 { // DGRAM
+		header.Source = &net.UDPAddr{IP: srcIP, Port: srcPort}
+		header.Destination = &net.UDPAddr{IP: dstIP, Port: dstPort}
+	} else {
+		header.Source = &net.TCPAddr{IP: srcIP, Port: srcPort}
+		header.Destination = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	}
+	return header, nil
+}
@@ -0,0 +1,126 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// v2Header builds a binary header with the given version and command
+// byte, family byte and body
+func v2Header(versionCommand, family byte, body []byte) string {
+	var buf bytes.Buffer
+	buf.Write(v2Signature)
+	buf.WriteByte(versionCommand)
+	buf.WriteByte(family)
+	binary.Write(&buf, binary.BigEndian, uint16(len(body)))
+	buf.Write(body)
+	return buf.String()
+}
+
+// addressBlock is the v2 body for src and dst with their ports
+func addressBlock(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	body := append(append([]byte{}, src...), dst...)
+	body = binary.BigEndian.AppendUint16(body, srcPort)
+	return binary.BigEndian.AppendUint16(body, dstPort)
+}
+
+func TestReadHeader(t *testing.T) {
+	v4 := addressBlock(net.ParseIP("192.0.2.1").To4(), net.ParseIP("198.51.100.2").To4(), 5000, 443)
+	v6 := addressBlock(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 5000, 443)
+	tests := []struct {
+		name    string
+		input   string
+		version int
+		source  string
+		local   bool
+		wantErr bool
+	}{
+		{"v1 TCP4", "PROXY TCP4 192.0.2.1 198.51.100.2 5000 443\r\n", 1, "192.0.2.1:5000", false, false},
+		{"v1 TCP6", "PROXY TCP6 2001:db8::1 2001:db8::2 5000 443\r\n", 1, "[2001:db8::1]:5000", false, false},
+		{"v1 UNKNOWN", "PROXY UNKNOWN\r\n", 1, "", true, false},
+		{"v1 without CRLF", "PROXY TCP4 192.0.2.1 198.51.100.2 5000 443\n", 0, "", false, true},
+		{"v1 family mismatch", "PROXY TCP4 2001:db8::1 198.51.100.2 5000 443\r\n", 0, "", false, true},
+		{"v1 bad port", "PROXY TCP4 192.0.2.1 198.51.100.2 70000 443\r\n", 0, "", false, true},
+		{"v1 missing fields", "PROXY TCP4 192.0.2.1\r\n", 0, "", false, true},
+		{"v1 too long", "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", 0, "", false, true},
+		{"v2 TCP over IPv4", v2Header(0x21, 0x11, v4), 2, "192.0.2.1:5000", false, false},
+		{"v2 TCP over IPv6", v2Header(0x21, 0x21, v6), 2, "[2001:db8::1]:5000", false, false},
+		{"v2 UDP", v2Header(0x21, 0x12, v4), 2, "192.0.2.1:5000", false, false},
+		{"v2 TLVs are skipped", v2Header(0x21, 0x11, append(v4, 0x04, 0x00, 0x01, 0xff)), 2, "192.0.2.1:5000", false, false},
+		{"v2 LOCAL", v2Header(0x20, 0x00, nil), 2, "", true, false},
+		{"v2 unix socket", v2Header(0x21, 0x31, make([]byte, 216)), 2, "", true, false},
+		{"v2 wrong version", v2Header(0x11, 0x11, v4), 0, "", false, true},
+		{"v2 unknown command", v2Header(0x22, 0x11, v4), 0, "", false, true},
+		{"v2 short address block", v2Header(0x21, 0x11, v4[:8]), 0, "", false, true},
+		{"v2 truncated", v2Header(0x21, 0x11, v4)[:20], 0, "", false, true},
+		{"no header", "PING:\n", 0, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input + "PING:\n"))
+			header, err := ReadHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadHeader = %+v, want an error", header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadHeader: %v", err)
+			}
+			if header.Version != tt.version || header.Local != tt.local {
+				t.Errorf("header = %+v, want version %d, local %v", header, tt.version, tt.local)
+			}
+			source := ""
+			if header.Source != nil {
+				source = header.Source.String()
+			}
+			if source != tt.source {
+				t.Errorf("source = %q, want %q", source, tt.source)
+			}
+			// The connection's own data follows the header
+			if rest, _ := io.ReadAll(r); string(rest) != "PING:\n" {
+				t.Errorf("data after header = %q", rest)
+			}
+		})
+	}
+
+	if _, err := ReadHeader(bufio.NewReader(strings.NewReader("PING:\n"))); !errors.Is(err, ErrNoHeader) {
+		t.Errorf("ReadHeader without header = %v, want ErrNoHeader", err)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"10.0.0.0/8, 192.0.2.7", []string{"10.0.0.0/8", "192.0.2.7/32"}, false},
+		{"2001:db8::1,,fd00::/8", []string{"2001:db8::1/128", "fd00::/8"}, false},
+		{"10.0.0.0/33", nil, true},
+		{"proxy.local", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			networks, err := ParseCIDRs(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCIDRs err = %v, want error %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, network := range networks {
+				got = append(got, network.String())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("ParseCIDRs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
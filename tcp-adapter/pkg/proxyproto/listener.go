@@ -0,0 +1,158 @@
+// Package proxyproto implements the receiving side of the HAProxy PROXY
+// protocol (versions 1 and 2), so connections accepted behind a load
+// balancer report the original client address from RemoteAddr.
+package proxyproto
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a trusted proxy may take to send
+// the header after connecting
+const DefaultHeaderTimeout = 5 * time.Second
+
+// Config controls which peers may send PROXY headers
+type Config struct {
+	// TrustedProxies lists the networks whose connections must start with
+	// a PROXY header. Headers from other peers are never parsed, so
+	// clients cannot spoof their address.
+	TrustedProxies []*net.IPNet
+	// HeaderTimeout bounds the time to read the header
+	HeaderTimeout time.Duration
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs or bare IPs
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address: %s", item)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// trusts reports whether addr belongs to a trusted proxy
+func (c *Config) trusts(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range c.TrustedProxies {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Listener wraps a net.Listener and decodes PROXY headers from trusted peers
+type Listener struct {
+	net.Listener
+	config Config
+}
+
+// NewListener wraps inner with PROXY protocol support
+func NewListener(inner net.Listener, config Config) *Listener {
+	if config.HeaderTimeout <= 0 {
+		config.HeaderTimeout = DefaultHeaderTimeout
+	}
+	return &Listener{Listener: inner, config: config}
+}
+
+// Accept returns the next connection. Connections from trusted proxies
+// are returned as *Conn; the header is read on first use so a slow proxy
+// never blocks the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.config.trusts(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: l.config.HeaderTimeout,
+	}, nil
+}
+
+// Conn is a connection from a trusted proxy whose addresses come from
+// the PROXY header
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	header *Header
+	err    error
+}
+
+// readHeader reads the header exactly once
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.header, c.err = ReadHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			c.err = fmt.Errorf("reading PROXY header from %s: %w", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+// Header returns the decoded PROXY header, reading it if necessary
+func (c *Conn) Header() (*Header, error) {
+	c.readHeader()
+	return c.header, c.err
+}
+
+// Read reads data following the header. It fails if the header is invalid.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the original client address from the header
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && !c.header.Local && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client originally connected to
+func (c *Conn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && !c.header.Local && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
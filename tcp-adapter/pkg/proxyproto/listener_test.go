@@ -0,0 +1,55 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestListener(t *testing.T) {
+	loopback, _ := ParseCIDRs("127.0.0.1")
+	other, _ := ParseCIDRs("192.0.2.0/24")
+	tests := []struct {
+		name    string
+		trusted []*net.IPNet
+		send    string
+		remote  string
+		data    string
+	}{
+		{"trusted proxy", loopback, "PROXY TCP4 203.0.113.5 198.51.100.2 5000 443\r\nPING:\n", "203.0.113.5:5000", "PING:\n"},
+		{"health check", loopback, "PROXY UNKNOWN\r\nPING:\n", "127.0.0.1", "PING:\n"},
+		// Headers from untrusted peers are passed through as data
+		{"untrusted peer", other, "PROXY TCP4 203.0.113.5 198.51.100.2 5000 443\r\n", "127.0.0.1", "PROXY TCP4 203.0.113.5 198.51.100.2 5000 443\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := NewListener(inner, Config{TrustedProxies: tt.trusted})
+			defer l.Close()
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Write([]byte(tt.send))
+			client.Close()
+
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if host := conn.RemoteAddr().String(); host != tt.remote {
+				if h, _, _ := net.SplitHostPort(host); h != tt.remote {
+					t.Errorf("RemoteAddr = %s, want %s", host, tt.remote)
+				}
+			}
+			if data, err := io.ReadAll(conn); err != nil || string(data) != tt.data {
+				t.Errorf("read %q, %v, want %q", data, err, tt.data)
+			}
+		})
+	}
+}
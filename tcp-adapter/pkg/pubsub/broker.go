@@ -0,0 +1,135 @@
+// Package pubsub routes published messages to the subscribers of a topic
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/protocol"
+)
+
+// MessageCommand is the command of messages delivered to subscribers
+const MessageCommand = protocol.MessageCommand
+
+// Subscriber receives the messages published to its topics
+type Subscriber interface {
+	Deliver(msg *protocol.Message)
+}
+
+// Forwarder passes publications on to other brokers, e.g. on the other
+// nodes of a cluster, and returns how many subscribers they reach
+type Forwarder interface {
+	Forward(topic, payload string) int
+}
+
+// Broker keeps the subscriptions of every topic
+type Broker struct {
+	mu        sync.RWMutex
+	topics    map[string]map[Subscriber]struct{}
+	forwarder Forwarder
+}
+
+// NewBroker creates an empty broker
+func NewBroker() *Broker {
+	return &Broker{
+		topics: make(map[string]map[Subscriber]struct{}),
+	}
+}
+
+// ValidTopic reports whether topic can be used in the MESSAGE format
+func ValidTopic(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("topic is required")
+	}
+	if strings.ContainsAny(topic, ": \t") {
+		return fmt.Errorf("topic %q must not contain ':' or spaces", topic)
+	}
+	return nil
+}
+
+// Subscribe adds sub to topic; subscribing twice has no effect
+func (b *Broker) Subscribe(topic string, sub Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.topics[topic]
+	if !ok {
+		subs = make(map[Subscriber]struct{})
+		b.topics[topic] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+// Unsubscribe removes sub from topic
+func (b *Broker) Unsubscribe(topic string, sub Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(topic, sub)
+}
+
+// UnsubscribeAll removes sub from every topic
+func (b *Broker) UnsubscribeAll(sub Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for topic := range b.topics {
+		b.removeLocked(topic, sub)
+	}
+}
+
+func (b *Broker) removeLocked(topic string, sub Subscriber) {
+	subs := b.topics[topic]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.topics, topic)
+	}
+}
+
+// SetForwarder makes Publish also hand every publication to f; nil
+// keeps publications local
+func (b *Broker) SetForwarder(f Forwarder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.forwarder = f
+}
+
+// Publish delivers payload to every subscriber of topic, local and
+// through the forwarder, and returns how many there were
+func (b *Broker) Publish(topic, payload string) int {
+	b.mu.RLock()
+	forwarder := b.forwarder
+	b.mu.RUnlock()
+
+	n := b.Deliver(topic, payload)
+	if forwarder != nil {
+		n += forwarder.Forward(topic, payload)
+	}
+	return n
+}
+
+// Deliver delivers payload to the local subscribers of topic only and
+// returns how many there were. Forwarders use it for publications that
+// were made elsewhere.
+func (b *Broker) Deliver(topic, payload string) int {
+	b.mu.RLock()
+	subs := make([]Subscriber, 0, len(b.topics[topic]))
+	for sub := range b.topics[topic] {
+		subs = append(subs, sub)
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		// Each subscriber gets its own copy so it can add headers
+		sub.Deliver(protocol.NewMessage(MessageCommand, topic+":"+payload))
+	}
+	return len(subs)
+}
+
+// Topics returns the number of subscribers of every topic
+func (b *Broker) Topics() map[string]int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	topics := make(map[string]int, len(b.topics))
+	for topic, subs := range b.topics {
+		topics[topic] = len(subs)
+	}
+	return topics
+}
@@ -0,0 +1,53 @@
+package pubsub
+
+import (
+	"strconv"
+	"strings"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+)
+
+// SubscriberFunc returns the subscriber that represents a connection
+type SubscriberFunc func(ctx *handler.Context) Subscriber
+
+// Register adds the pub/sub commands to router:
+//
+//	SUBSCRIBE:<topic>          -> SUBSCRIBED:<topic>
+//	UNSUBSCRIBE:<topic>        -> UNSUBSCRIBED:<topic>
+//	PUBLISH:<topic>:<payload>  -> PUBLISHED:<subscriber count>
+func Register(router *handler.Router, broker *Broker, subscriber SubscriberFunc) {
+	router.Handle("SUBSCRIBE", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		sub := subscriber(ctx)
+		if sub == nil {
+			return protocol.ErrorMessage(protocol.CodeInternal, "No subscriber for connection")
+		}
+		topic := strings.TrimSpace(msg.Payload)
+		if err := ValidTopic(topic); err != nil {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, err.Error())
+		}
+		broker.Subscribe(topic, sub)
+		return protocol.NewMessage("SUBSCRIBED", topic)
+	})
+
+	router.Handle("UNSUBSCRIBE", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		sub := subscriber(ctx)
+		if sub == nil {
+			return protocol.ErrorMessage(protocol.CodeInternal, "No subscriber for connection")
+		}
+		topic := strings.TrimSpace(msg.Payload)
+		broker.Unsubscribe(topic, sub)
+		return protocol.NewMessage("UNSUBSCRIBED", topic)
+	})
+
+	router.Handle("PUBLISH", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		topic, payload, ok := strings.Cut(msg.Payload, ":")
+		if !ok {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, "Expected PUBLISH:<topic>:<payload>")
+		}
+		if err := ValidTopic(topic); err != nil {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, err.Error())
+		}
+		n := broker.Publish(topic, payload)
+		return protocol.NewMessage("PUBLISHED", strconv.Itoa(n))
+	})
+}
@@ -0,0 +1,219 @@
+package session
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/pubsub"
+	"time"
+)
+
+// Config controls how long sessions outlive their connection
+type Config struct {
+	// Grace is how long a disconnected session is kept for RESUME.
+	// Zero disables resumption: state ends with the connection.
+	Grace time.Duration
+	// MaxBuffered is how many recent messages are kept for replay
+	MaxBuffered int
+}
+
+// Manager creates, resumes and expires sessions
+type Manager struct {
+	config Config
+	broker *pubsub.Broker
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewManager creates a manager whose sessions subscribe through broker
+func NewManager(config Config, broker *pubsub.Broker) *Manager {
+	if config.MaxBuffered <= 0 {
+		config.MaxBuffered = DefaultMaxBuffered
+	}
+	return &Manager{
+		config:   config,
+		broker:   broker,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Broker returns the broker that delivers to sessions
+func (m *Manager) Broker() *pubsub.Broker {
+	return m.broker
+}
+
+// Register adds RESUME (when resumption is enabled) and the pub/sub
+// commands to router
+func (m *Manager) Register(router *handler.Router) {
+	if m.config.Grace > 0 {
+		router.Handle("RESUME", m.resume)
+	}
+	pubsub.Register(router, m.broker, func(ctx *handler.Context) pubsub.Subscriber {
+		if s := From(ctx); s != nil {
+			return s
+		}
+		return nil
+	})
+}
+
+// Connect starts a session for a new connection and, when resumption is
+// enabled, announces its token on the welcome message. It is meant for
+// handler.Options.OnConnect.
+func (m *Manager) Connect(ctx *handler.Context, welcome *protocol.Message) {
+	s := &Session{token: newToken(), manager: m, ctx: ctx}
+	m.mu.Lock()
+	m.sessions[s.token] = s
+	m.mu.Unlock()
+
+	ctx.Set(contextKey, s)
+	if m.config.Grace > 0 {
+		welcome.SetHeader(TokenHeader, s.token)
+	}
+}
+
+// Disconnect detaches the session from a closed connection and schedules
+// its expiry. It is meant for handler.Options.OnDisconnect.
+func (m *Manager) Disconnect(ctx *handler.Context) {
+	s := From(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ctx != ctx {
+		// Another connection resumed the session
+		s.mu.Unlock()
+		return
+	}
+	s.ctx = nil
+	s.authenticated = ctx.Authenticated()
+	if m.config.Grace > 0 {
+		s.expiry = time.AfterFunc(m.config.Grace, func() { m.expire(s) })
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	m.remove(s)
+}
+
+// expire ends a session that was not resumed within the grace period
+func (m *Manager) expire(s *Session) {
+	s.mu.Lock()
+	if s.ctx != nil || s.removed {
+		s.mu.Unlock()
+		return
+	}
+	s.removed = true
+	s.mu.Unlock()
+
+	log.Printf("Session %s expired", shortToken(s.token))
+	m.forget(s)
+}
+
+// remove ends a session immediately
+func (m *Manager) remove(s *Session) {
+	s.mu.Lock()
+	s.removed = true
+	s.mu.Unlock()
+	m.forget(s)
+}
+
+// forget drops a removed session and its subscriptions
+func (m *Manager) forget(s *Session) {
+	m.mu.Lock()
+	delete(m.sessions, s.token)
+	m.mu.Unlock()
+	m.broker.UnsubscribeAll(s)
+}
+
+// Count returns the number of live and resumable sessions
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// resume implements RESUME:<token>[:<last seq>]. The connection adopts
+// the session's authentication and subscriptions, then receives RESUMED
+// followed by every message after <last seq> (or after the last one
+// sent before the disconnect) in order. The connection's own new session
+// is discarded, so RESUME should be the first command after connecting.
+func (m *Manager) resume(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+	token, lastSeq, hasSeq := strings.Cut(strings.TrimSpace(msg.Payload), ":")
+	var after uint64
+	if hasSeq {
+		n, err := strconv.ParseUint(lastSeq, 10, 64)
+		if err != nil {
+			return protocol.ErrorMessage(protocol.CodeBadRequest, "Expected RESUME:<token>[:<last seq>]")
+		}
+		after = n
+	}
+
+	m.mu.Lock()
+	s := m.sessions[token]
+	m.mu.Unlock()
+	if s == nil {
+		return protocol.ErrorMessage(protocol.CodeNotFound, "Unknown or expired session")
+	}
+	current := From(ctx)
+	if current == s {
+		return resumed(s, 0)
+	}
+
+	s.mu.Lock()
+	if s.removed {
+		s.mu.Unlock()
+		return protocol.ErrorMessage(protocol.CodeNotFound, "Unknown or expired session")
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	if previous := s.ctx; previous != nil {
+		// The old connection has not noticed it is gone; take over
+		s.authenticated = previous.Authenticated()
+		log.Printf("Session %s taken over by %s from %s", shortToken(token), ctx.RemoteAddr, previous.RemoteAddr)
+		go previous.Close()
+	}
+	s.ctx = ctx
+	ctx.Set(contextKey, s)
+	if s.authenticated {
+		ctx.SetAuthenticated(true)
+	}
+	if !hasSeq {
+		after = s.sent
+	}
+
+	// RESUMED is sent here rather than returned so that it precedes the
+	// replayed messages
+	ctx.Send(resumed(s, s.lost(after)))
+	s.replay(ctx, after)
+	s.mu.Unlock()
+
+	if current != nil {
+		m.remove(current)
+	}
+	log.Printf("Session %s resumed by %s", shortToken(token), ctx.RemoteAddr)
+	return nil
+}
+
+// resumed builds the RESUMED answer
+func resumed(s *Session, lost uint64) *protocol.Message {
+	response := protocol.NewMessage("RESUMED", s.token)
+	response.SetHeader(SeqHeader, strconv.FormatUint(s.seq, 10))
+	if lost > 0 {
+		response.SetHeader(LostHeader, strconv.FormatUint(lost, 10))
+	}
+	return response
+}
+
+// shortToken abbreviates a token for log messages
+func shortToken(token string) string {
+	if len(token) > 8 {
+		return token[:8]
+	}
+	return token
+}
@@ -0,0 +1,137 @@
+// Package session keeps per-client state (authentication, pub/sub
+// subscriptions and recent messages) beyond the life of one connection,
+// so a client whose connection drops can RESUME where it left off.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// Headers used by sessions
+const (
+	TokenHeader = protocol.HeaderSession
+	SeqHeader   = protocol.HeaderSeq
+	LostHeader  = protocol.HeaderLost
+)
+
+// DefaultMaxBuffered is how many recent messages a session keeps for replay
+const DefaultMaxBuffered = 256
+
+// contextKey stores the session on the handler context
+const contextKey = "session"
+
+// Session is the state of one client across reconnects
+type Session struct {
+	token   string
+	manager *Manager
+
+	mu sync.Mutex
+	// ctx is the attached connection, nil while disconnected
+	ctx           *handler.Context
+	authenticated bool
+	// seq is the sequence number of the last message delivered
+	seq uint64
+	// sent is the highest seq written to a connection; RESUME without
+	// a seq replays what follows it
+	sent uint64
+	// buffer holds the most recent messages for replay, oldest first
+	buffer []*protocol.Message
+	expiry *time.Timer
+	// removed is set once the session has ended for good
+	removed bool
+}
+
+// From returns the session of a connection, or nil if sessions are disabled
+func From(ctx *handler.Context) *Session {
+	value, ok := ctx.Get(contextKey)
+	if !ok {
+		return nil
+	}
+	s, _ := value.(*Session)
+	return s
+}
+
+// Token returns the secret that resumes the session
+func (s *Session) Token() string {
+	return s.token
+}
+
+// Deliver numbers msg, keeps it for replay and sends it to the attached
+// connection. Messages delivered while disconnected are sent on RESUME.
+func (s *Session) Deliver(msg *protocol.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	msg.SetHeader(SeqHeader, strconv.FormatUint(s.seq, 10))
+	if s.manager.config.Grace > 0 {
+		s.buffer = append(s.buffer, msg)
+		if over := len(s.buffer) - s.manager.config.MaxBuffered; over > 0 {
+			copy(s.buffer, s.buffer[over:])
+			s.buffer = s.buffer[:len(s.buffer)-over]
+		}
+	}
+
+	// Sending only queues the message, so holding the lock keeps the
+	// order without waiting on the network
+	if s.ctx != nil {
+		s.send(s.ctx, msg)
+	}
+}
+
+// send queues msg on ctx and counts it as sent once it is written, so a
+// connection that drops with messages still queued gets them on RESUME
+func (s *Session) send(ctx *handler.Context, msg *protocol.Message) {
+	seq := seqOf(msg)
+	ctx.SendWritten(msg, func() {
+		s.mu.Lock()
+		if seq > s.sent {
+			s.sent = seq
+		}
+		s.mu.Unlock()
+	})
+}
+
+// lost returns how many messages after seq are no longer buffered
+func (s *Session) lost(after uint64) uint64 {
+	if len(s.buffer) == 0 {
+		if s.seq > after {
+			return s.seq - after
+		}
+		return 0
+	}
+	if first := seqOf(s.buffer[0]); first > after+1 {
+		return first - after - 1
+	}
+	return 0
+}
+
+// replay sends the buffered messages after seq to ctx in order
+func (s *Session) replay(ctx *handler.Context, after uint64) {
+	for _, msg := range s.buffer {
+		if seqOf(msg) > after {
+			s.send(ctx, msg)
+		}
+	}
+}
+
+// seqOf returns the sequence number of a delivered message
+func seqOf(msg *protocol.Message) uint64 {
+	seq, _ := strconv.ParseUint(msg.Header(SeqHeader), 10, 64)
+	return seq
+}
+
+// newToken returns a random, unguessable session token
+func newToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("session: no randomness: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
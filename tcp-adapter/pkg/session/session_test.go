@@ -0,0 +1,157 @@
+package session_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"tcp-adapter/pkg/adaptertest"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"tcp-adapter/pkg/pubsub"
+	"tcp-adapter/pkg/session"
+)
+
+// newServer enables sessions and adds LOGIN and AUTHED to check that
+// authentication survives a resume
+func newServer(t *testing.T, config session.Config) (*adaptertest.Server, *session.Manager) {
+	t.Helper()
+	srv := adaptertest.NewServer(t)
+	m := srv.Adapter.EnableSessions(config)
+	srv.Router().Handle("LOGIN", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		ctx.SetAuthenticated(true)
+		return protocol.NewMessage("LOGGED_IN", "")
+	})
+	srv.Router().Handle("AUTHED", func(ctx *handler.Context, msg *protocol.Message) *protocol.Message {
+		return protocol.NewMessage("AUTHED", strconv.FormatBool(ctx.Authenticated()))
+	})
+	return srv, m
+}
+
+// expectMessage reads a published message with its sequence number
+func expectMessage(t *testing.T, c *adaptertest.Client, payload string, seq int) {
+	t.Helper()
+	got := c.Read()
+	adaptertest.AssertMessage(t, got, protocol.NewMessage(pubsub.MessageCommand, "news:"+payload))
+	if got.Header(session.SeqHeader) != strconv.Itoa(seq) {
+		t.Errorf("%s has seq %q, want %d", payload, got.Header(session.SeqHeader), seq)
+	}
+}
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBuffered int
+		// resume is the RESUME payload after the token
+		resume   string
+		lost     int
+		replayed []int
+	}{
+		{"after the last message sent", 0, "", 0, []int{3, 4}},
+		{"after a given seq", 0, ":1", 0, []int{2, 3, 4}},
+		{"nothing to replay", 0, ":4", 0, nil},
+		{"beyond the buffer", 2, ":0", 2, []int{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, m := newServer(t, session.Config{Grace: time.Minute, MaxBuffered: tt.maxBuffered})
+			alice := srv.Client()
+			token := alice.Welcome.Header(session.TokenHeader)
+			if token == "" {
+				t.Fatal("WELCOME has no session token")
+			}
+			alice.Run(`
+				LOGIN: => LOGGED_IN:
+				SUBSCRIBE:news => SUBSCRIBED:news
+			`)
+			publisher := srv.Client()
+			for i := 1; i <= 2; i++ {
+				publisher.Expect(fmt.Sprintf("PUBLISH:news:m%d", i), "PUBLISHED:1")
+				expectMessage(t, alice, fmt.Sprintf("m%d", i), i)
+			}
+			alice.Close()
+			// Published while alice is away
+			for i := 3; i <= 4; i++ {
+				publisher.Expect(fmt.Sprintf("PUBLISH:news:m%d", i), "PUBLISHED:1")
+			}
+
+			bob := srv.Client()
+			resumed := bob.Send("RESUME:" + token + tt.resume)
+			adaptertest.AssertMessage(t, resumed, protocol.NewMessage("RESUMED", token))
+			if got := resumed.Header(session.SeqHeader); got != "4" {
+				t.Errorf("RESUMED seq = %q, want 4", got)
+			}
+			lost := 0
+			if h := resumed.Header(session.LostHeader); h != "" {
+				lost, _ = strconv.Atoi(h)
+			}
+			if lost != tt.lost {
+				t.Errorf("RESUMED lost = %d, want %d", lost, tt.lost)
+			}
+			for _, seq := range tt.replayed {
+				expectMessage(t, bob, fmt.Sprintf("m%d", seq), seq)
+			}
+
+			// bob has alice's session: its login, subscriptions and numbering
+			bob.Expect("AUTHED:", "AUTHED:true")
+			publisher.Expect("PUBLISH:news:m5", "PUBLISHED:1")
+			expectMessage(t, bob, "m5", 5)
+			if n := m.Count(); n != 2 {
+				t.Errorf("%d sessions, want bob's and the publisher's", n)
+			}
+		})
+	}
+}
+
+func TestResumeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   time.Duration
+		request string
+		code    protocol.ErrorCode
+	}{
+		{"unknown token", time.Minute, "RESUME:nope", protocol.CodeNotFound},
+		{"bad seq", time.Minute, "RESUME:nope:x", protocol.CodeBadRequest},
+		{"resumption disabled", 0, "RESUME:nope", protocol.CodeUnknownCommand},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, session.Config{Grace: tt.grace})
+			c := srv.Client()
+			if tt.grace == 0 && c.Welcome.Header(session.TokenHeader) != "" {
+				t.Error("WELCOME announces a token that cannot be resumed")
+			}
+			c.ExpectError(tt.request, tt.code)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	tests := []struct {
+		name  string
+		grace time.Duration
+	}{
+		{"after the grace period", 50 * time.Millisecond},
+		{"without resumption", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, m := newServer(t, session.Config{Grace: tt.grace})
+			c := srv.Client()
+			c.Expect("SUBSCRIBE:news", "SUBSCRIBED:news")
+			c.Close()
+
+			deadline := time.Now().Add(adaptertest.DefaultTimeout)
+			for m.Count() > 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n := m.Count(); n != 0 {
+				t.Fatalf("%d session(s) left", n)
+			}
+			// Its subscriptions ended with it
+			publisher := srv.Client()
+			publisher.Expect("PUBLISH:news:x", "PUBLISHED:0")
+		})
+	}
+}
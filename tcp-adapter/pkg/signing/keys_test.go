@@ -0,0 +1,45 @@
+package signing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{"keys", "# key-id secret\n\n2024-q1 4f1c0d9a2b7e4c55\n  2024-q2\t9e2b5c7d1a3f4e6b8c0d  \n",
+			map[string]string{"2024-q1": "4f1c0d9a2b7e4c55", "2024-q2": "9e2b5c7d1a3f4e6b8c0d"}, ""},
+		{"empty", "", map[string]string{}, ""},
+		{"missing secret", "2024-q1\n", nil, "line 1: expected <key-id> <secret>"},
+		{"extra field", "2024-q1 4f1c0d9a2b7e4c55 x\n", nil, "line 1: expected <key-id> <secret>"},
+		{"duplicate", "k 4f1c0d9a2b7e4c55\nk 9e2b5c7d1a3f4e6b\n", nil, `line 2: duplicate key "k"`},
+		{"short secret", "# rotated\nk 4f1c0d9a2b7e4c5\n", nil, `line 2: secret of key "k" is shorter than 16 bytes`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseKeys = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeys: %v", err)
+			}
+			if len(keys) != len(tt.want) {
+				t.Fatalf("ParseKeys = %q, want %q", keys, tt.want)
+			}
+			for id, secret := range tt.want {
+				if string(keys[id]) != secret {
+					t.Errorf("key %s = %q, want %q", id, keys[id], secret)
+				}
+			}
+		})
+	}
+}
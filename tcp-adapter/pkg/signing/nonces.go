@@ -0,0 +1,48 @@
+package signing
+
+import (
+	"sync"
+	"time"
+)
+
+// nonceCache remembers the nonces seen recently, forgetting each once a
+// message carrying it could no longer pass the timestamp check
+type nonceCache struct {
+	ttl time.Duration
+	max int
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// order lists the nonces by expiry, which is their insertion order
+	order []string
+}
+
+func newNonceCache(ttl time.Duration, max int) *nonceCache {
+	return &nonceCache{
+		ttl:  ttl,
+		max:  max,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// add records nonce at now. It reports ok=false if the nonce was already
+// seen and full=true if the cache has no room left.
+func (c *nonceCache) add(nonce string, now time.Time) (ok, full bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.order) > 0 && !now.Before(c.seen[c.order[0]]) {
+		delete(c.seen, c.order[0])
+		c.order[0] = ""
+		c.order = c.order[1:]
+	}
+	if _, replayed := c.seen[nonce]; replayed {
+		return false, false
+	}
+	if len(c.seen) >= c.max {
+		return false, true
+	}
+	c.seen[nonce] = now.Add(c.ttl)
+	c.order = append(c.order, nonce)
+	return true, false
+}
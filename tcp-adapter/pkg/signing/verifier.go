@@ -0,0 +1,154 @@
+package signing
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+	"tcp-adapter/pkg/handler"
+	"tcp-adapter/pkg/protocol"
+	"time"
+)
+
+// DefaultMaxSkew is how far a signature timestamp may be from the
+// server's clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// DefaultMaxNonces bounds the memory used for replay detection
+const DefaultMaxNonces = 1 << 20
+
+// contextKey stores the key ID of the last verified message on the
+// handler context
+const contextKey = "signing-key"
+
+// Config controls signature verification
+type Config struct {
+	Keys *Keyring
+	// Required lists the commands that must be signed; "*" means all.
+	// Signatures on other commands are verified when present.
+	Required []string
+	// MaxSkew is the accepted difference between a message's timestamp
+	// and the server's clock, in either direction
+	MaxSkew time.Duration
+	// MaxNonces is how many recent nonces are remembered; signed
+	// messages beyond that get TOO_MANY_REQUESTS
+	MaxNonces int
+}
+
+// Verifier checks message signatures, timestamps and nonces
+type Verifier struct {
+	keys     *Keyring
+	required map[string]bool
+	all      bool
+	maxSkew  time.Duration
+	nonces   *nonceCache
+
+	verified int64
+	rejected int64
+}
+
+// NewVerifier creates a verifier for config
+func NewVerifier(config Config) *Verifier {
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultMaxSkew
+	}
+	if config.MaxNonces <= 0 {
+		config.MaxNonces = DefaultMaxNonces
+	}
+	v := &Verifier{
+		keys:     config.Keys,
+		required: make(map[string]bool),
+		maxSkew:  config.MaxSkew,
+		// A message is accepted until its timestamp is MaxSkew old, and
+		// that timestamp may itself be up to MaxSkew in the future
+		nonces: newNonceCache(2*config.MaxSkew, config.MaxNonces),
+	}
+	for _, command := range config.Required {
+		command = strings.ToUpper(strings.TrimSpace(command))
+		if command == "*" {
+			v.all = true
+		} else if command != "" {
+			v.required[command] = true
+		}
+	}
+	return v
+}
+
+// Required reports whether command must be signed
+func (v *Verifier) Required(command string) bool {
+	return v.all || v.required[strings.ToUpper(command)]
+}
+
+// Verify checks the signature of msg. Unsigned messages pass unless
+// their command is required to be signed.
+func (v *Verifier) Verify(msg *protocol.Message) *protocol.Error {
+	if !protocol.Signed(msg) {
+		if v.Required(msg.Command) {
+			return protocol.Errorf(protocol.CodeUnauthorized, "Command %s must be signed", msg.Command)
+		}
+		return nil
+	}
+
+	keyID := msg.Header(protocol.HeaderSigKey)
+	secret, ok := v.keys.Secret(keyID)
+	if !ok {
+		return protocol.Errorf(protocol.CodeUnauthorized, "Unknown signing key %q", keyID)
+	}
+	if !protocol.VerifySignature(msg, secret) {
+		return protocol.NewError(protocol.CodeUnauthorized, "Invalid signature")
+	}
+
+	// Only checked once the signature proves the headers are genuine
+	now := time.Now()
+	signedAt, ok := protocol.SignedAt(msg)
+	if !ok {
+		return protocol.NewError(protocol.CodeBadRequest, "Missing or invalid signature timestamp")
+	}
+	if skew := now.Sub(signedAt); skew > v.maxSkew || skew < -v.maxSkew {
+		return protocol.Errorf(protocol.CodeUnauthorized, "Signature timestamp is %s off the server clock", skew.Round(time.Second))
+	}
+	nonce := msg.Header(protocol.HeaderSigNonce)
+	if nonce == "" {
+		return protocol.NewError(protocol.CodeBadRequest, "Missing signature nonce")
+	}
+	added, full := v.nonces.add(keyID+":"+nonce, now)
+	if full {
+		return protocol.NewError(protocol.CodeTooManyRequests, "Too many signed messages, try again later")
+	}
+	if !added {
+		return protocol.NewError(protocol.CodeConflict, "Replayed message")
+	}
+	return nil
+}
+
+// Interceptor rejects messages that fail Verify and records the key of
+// verified ones on the context (see KeyID)
+func (v *Verifier) Interceptor() handler.Interceptor {
+	return func(ctx *handler.Context, msg *protocol.Message, next handler.CommandFunc) *protocol.Message {
+		if err := v.Verify(msg); err != nil {
+			atomic.AddInt64(&v.rejected, 1)
+			log.Printf("Rejected %s from %s: %s", msg.Command, ctx.RemoteAddr, err.Message)
+			return err.ToMessage()
+		}
+		if protocol.Signed(msg) {
+			atomic.AddInt64(&v.verified, 1)
+			ctx.Set(contextKey, msg.Header(protocol.HeaderSigKey))
+		} else {
+			ctx.Set(contextKey, "")
+		}
+		return next(ctx, msg)
+	}
+}
+
+// Stats returns how many signed messages were verified and how many
+// messages were rejected
+func (v *Verifier) Stats() (verified, rejected int64) {
+	return atomic.LoadInt64(&v.verified), atomic.LoadInt64(&v.rejected)
+}
+
+// KeyID returns the key that signed the command being handled, or "" if
+// it was not signed
+func KeyID(ctx *handler.Context) string {
+	value, _ := ctx.Get(contextKey)
+	id, _ := value.(string)
+	return id
+}
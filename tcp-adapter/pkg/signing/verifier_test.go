@@ -0,0 +1,127 @@
+package signing
+
+import (
+	"testing"
+	"time"
+
+	"tcp-adapter/pkg/protocol"
+)
+
+var (
+	currentSecret = []byte("0123456789abcdef0123")
+	oldSecret     = []byte("fedcba9876543210fedc")
+)
+
+func testVerifier(maxNonces int) *Verifier {
+	return NewVerifier(Config{
+		Keys:      NewKeyringFromMap(map[string][]byte{"current": currentSecret, "old": oldSecret}),
+		Required:  []string{" admin ", ""},
+		MaxSkew:   time.Minute,
+		MaxNonces: maxNonces,
+	})
+}
+
+// signed returns a message signed with key at the given offset from now
+func signed(command, key string, secret []byte, offset time.Duration) *protocol.Message {
+	msg := protocol.NewMessage(command, "payload")
+	protocol.Sign(msg, key, secret, time.Now().Add(offset))
+	return msg
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  func() *protocol.Message
+		code protocol.ErrorCode
+	}{
+		{"unsigned", func() *protocol.Message { return protocol.NewMessage("PING", "") }, 0},
+		{"unsigned required command", func() *protocol.Message { return protocol.NewMessage("Admin", "") }, protocol.CodeUnauthorized},
+		{"signed", func() *protocol.Message { return signed("ADMIN", "current", currentSecret, 0) }, 0},
+		{"older key", func() *protocol.Message { return signed("ADMIN", "old", oldSecret, 0) }, 0},
+		{"unknown key", func() *protocol.Message { return signed("ADMIN", "retired", currentSecret, 0) }, protocol.CodeUnauthorized},
+		{"wrong secret", func() *protocol.Message { return signed("ADMIN", "current", oldSecret, 0) }, protocol.CodeUnauthorized},
+		{"changed payload", func() *protocol.Message {
+			msg := signed("ADMIN", "current", currentSecret, 0)
+			msg.Payload = "other"
+			return msg
+		}, protocol.CodeUnauthorized},
+		{"added header", func() *protocol.Message {
+			msg := signed("ADMIN", "current", currentSecret, 0)
+			msg.SetHeader("role", "root")
+			return msg
+		}, protocol.CodeUnauthorized},
+		{"signature not hex", func() *protocol.Message {
+			msg := signed("ADMIN", "current", currentSecret, 0)
+			msg.SetHeader(protocol.HeaderSignature, "zz")
+			return msg
+		}, protocol.CodeUnauthorized},
+		{"within skew", func() *protocol.Message { return signed("ADMIN", "current", currentSecret, -50*time.Second) }, 0},
+		{"stale", func() *protocol.Message { return signed("ADMIN", "current", currentSecret, -2*time.Minute) }, protocol.CodeUnauthorized},
+		{"from the future", func() *protocol.Message { return signed("ADMIN", "current", currentSecret, 2*time.Minute) }, protocol.CodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testVerifier(0).Verify(tt.msg())
+			if tt.code == 0 {
+				if err != nil {
+					t.Fatalf("Verify = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Code != tt.code {
+				t.Fatalf("Verify = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestVerifyNonces(t *testing.T) {
+	v := testVerifier(2)
+	msg := signed("ADMIN", "current", currentSecret, 0)
+	if err := v.Verify(msg); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := v.Verify(msg); err == nil || err.Code != protocol.CodeConflict {
+		t.Fatalf("replay = %v, want CONFLICT", err)
+	}
+
+	if err := v.Verify(signed("ADMIN", "old", oldSecret, 0)); err != nil {
+		t.Fatalf("second message: %v", err)
+	}
+
+	// Both nonces are still remembered, so the cache is full
+	if err := v.Verify(signed("ADMIN", "current", currentSecret, 0)); err == nil || err.Code != protocol.CodeTooManyRequests {
+		t.Fatalf("full cache = %v, want TOO_MANY_REQUESTS", err)
+	}
+	// Unsigned messages need no nonce
+	if err := v.Verify(protocol.NewMessage("PING", "")); err != nil {
+		t.Fatalf("unsigned: %v", err)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	start := time.Now()
+	c := newNonceCache(time.Minute, 2)
+	steps := []struct {
+		nonce    string
+		at       time.Duration
+		ok, full bool
+	}{
+		{"a", 0, true, false},
+		{"a", 30 * time.Second, false, false},
+		{"b", 30 * time.Second, true, false},
+		{"c", 45 * time.Second, false, true},
+		// a expires a minute after it was added, making room
+		{"c", time.Minute, true, false},
+		{"a", time.Minute, false, true},
+		{"b", time.Minute, false, false},
+		{"b", 90 * time.Second, true, false},
+		{"a", 90 * time.Second, false, true},
+	}
+	for i, step := range steps {
+		ok, full := c.add(step.nonce, start.Add(step.at))
+		if ok != step.ok || full != step.full {
+			t.Errorf("step %d: add(%s) at %s = %v, %v, want %v, %v", i, step.nonce, step.at, ok, full, step.ok, step.full)
+		}
+	}
+}
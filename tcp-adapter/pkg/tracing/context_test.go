@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name    string
+		value   string
+		sampled bool
+		wantErr bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, false},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", false, false},
+		{"other flags", "00-" + traceID + "-" + spanID + "-03", true, false},
+		{"surrounding space", " 00-" + traceID + "-" + spanID + "-01 ", true, false},
+		{"future version with more fields", "cc-" + traceID + "-" + spanID + "-01-extra", true, false},
+		{"version 00 with more fields", "00-" + traceID + "-" + spanID + "-01-extra", false, true},
+		{"version ff", "ff-" + traceID + "-" + spanID + "-01", false, true},
+		{"uppercase", "00-" + "4BF92F3577B34DA6A3CE929D0E0E4736" + "-" + spanID + "-01", false, true},
+		{"zero trace ID", "00-00000000000000000000000000000000-" + spanID + "-01", false, true},
+		{"zero span ID", "00-" + traceID + "-0000000000000000-01", false, true},
+		{"short trace ID", "00-" + traceID[:30] + "-" + spanID + "-01", false, true},
+		{"not hex", "00-" + traceID + "-" + "00f067aa0ba902bz" + "-01", false, true},
+		{"missing flags", "00-" + traceID + "-" + spanID, false, true},
+		{"empty", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTraceparent) {
+					t.Fatalf("ParseTraceparent = %+v, %v, want ErrInvalidTraceparent", sc, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent: %v", err)
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled {
+				t.Errorf("ParseTraceparent = %s, sampled %v", sc.Traceparent(), sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		got, err := ParseTraceparent(sc.Traceparent())
+		if err != nil || got != sc {
+			t.Errorf("ParseTraceparent(%s) = %+v, %v", sc.Traceparent(), got, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+)
+
+// Exporter receives every finished, sampled span
+type Exporter interface {
+	Export(span *Span)
+}
+
+// JSONExporter writes each span as a JSON line, e.g. to stdout for
+// collection by a log shipper
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONExporter creates an exporter writing to w
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+// Export writes span as one line of JSON
+func (e *JSONExporter) Export(span *Span) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(span); err != nil {
+		log.Printf("Span export failed: %v", err)
+	}
+}
+
+// MemoryExporter keeps finished spans in memory for tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewMemoryExporter creates an empty in-memory exporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export stores span
+func (e *MemoryExporter) Export(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans in finishing order
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+// SpansNamed returns the exported spans with the given name
+func (e *MemoryExporter) SpansNamed(name string) []*Span {
+	var result []*Span
+	for _, span := range e.Spans() {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+// Reset discards all stored spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// Span is one timed operation within a trace
+type Span struct {
+	Name       string            `json:"name"`
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Duration   time.Duration     `json:"duration_ns"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+
+	context  SpanContext
+	tracer   *Tracer
+	mu       sync.Mutex
+	finished bool
+}
+
+// Context returns the span context to propagate to children and peers.
+// Methods on Span are safe to call on a nil span, which is returned by
+// helpers when tracing is disabled.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttribute records a key/value pair on the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = message
+}
+
+// Finish ends the span now and hands it to the exporter
+func (s *Span) Finish() {
+	s.FinishAt(time.Now())
+}
+
+// FinishAt ends the span at the given time. Only the first call has effect.
+func (s *Span) FinishAt(end time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.End = end
+	s.Duration = end.Sub(s.Start)
+	s.mu.Unlock()
+
+	if s.tracer != nil && s.context.Sampled {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Tracer creates spans and sends finished ones to an exporter
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a tracer that exports to exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// StartSpan starts a span now; see StartSpanAt
+func (t *Tracer) StartSpan(name string, parent SpanContext) *Span {
+	return t.StartSpanAt(name, parent, time.Now())
+}
+
+// StartSpanAt starts a span at the given time. A valid parent makes the
+// span its child and inherits its sampling decision; otherwise the span
+// starts a new, sampled trace.
+func (t *Tracer) StartSpanAt(name string, parent SpanContext, start time.Time) *Span {
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		SpanID:  newSpanID(),
+		Sampled: parent.Sampled,
+	}
+	span := &Span{
+		Name:   name,
+		SpanID: sc.SpanID.String(),
+		Start:  start,
+		tracer: t,
+	}
+	if parent.IsValid() {
+		span.ParentID = parent.SpanID.String()
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = true
+	}
+	span.TraceID = sc.TraceID.String()
+	span.context = sc
+	return span
+}
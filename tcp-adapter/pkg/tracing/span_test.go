@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartSpan(t *testing.T) {
+	parent := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	tests := []struct {
+		name     string
+		parent   SpanContext
+		newTrace bool
+		exported bool
+	}{
+		{"root", SpanContext{}, true, true},
+		{"sampled parent", SpanContext{TraceID: parent.TraceID, SpanID: parent.SpanID, Sampled: true}, false, true},
+		{"unsampled parent", parent, false, false},
+		// A parent without a span ID is not valid and starts a new trace
+		{"invalid parent", SpanContext{TraceID: parent.TraceID, Sampled: false}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := NewMemoryExporter()
+			tracer := NewTracer(exporter)
+			start := time.Now()
+			span := tracer.StartSpanAt("request", tt.parent, start)
+			span.SetAttribute("command", "PING")
+			span.SetError("boom")
+			span.FinishAt(start.Add(time.Second))
+			span.Finish()
+
+			if tt.newTrace {
+				if span.TraceID == parent.TraceID.String() || span.ParentID != "" {
+					t.Errorf("span %+v continues the parent's trace", span)
+				}
+			} else if span.TraceID != parent.TraceID.String() || span.ParentID != parent.SpanID.String() {
+				t.Errorf("span %+v is not a child of %s", span, parent.Traceparent())
+			}
+			if got := span.Context().SpanID.String(); got != span.SpanID || got == parent.SpanID.String() {
+				t.Errorf("span ID = %s", got)
+			}
+
+			spans := exporter.SpansNamed("request")
+			if exported := len(spans) > 0; exported != tt.exported {
+				t.Fatalf("exported %d span(s), want exported %v", len(spans), tt.exported)
+			}
+			if tt.exported && (len(spans) != 1 || spans[0].Duration != time.Second ||
+				spans[0].Attributes["command"] != "PING" || spans[0].Error != "boom") {
+				t.Errorf("exported %+v", spans)
+			}
+		})
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	var span *Span
+	span.SetAttribute("k", "v")
+	span.SetError("boom")
+	span.Finish()
+	if span.Context().IsValid() {
+		t.Error("nil span has a valid context")
+	}
+}
@@ -0,0 +1,293 @@
+// Package validate checks struct fields against `validate` tags.
+//
+// Supported rules, separated by commas:
+//
+//	required      the field must not be the zero value
+//	min=N, max=N  bounds for numbers, or lengths for strings, slices and maps
+//	oneof=a b c   the value must be one of the space-separated options
+//
+// Field names in errors come from the `json` tag when present, so they
+// match what clients sent.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes why a single field is invalid
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// Errors lists every invalid field of a struct
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + " " + fe.Error
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Struct validates v, a struct or pointer to a struct, including nested
+// structs. It returns Errors if any field is invalid, or nil. Malformed
+// tags are reported as a plain error the first time a type is seen.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %s is not a struct", rv.Kind())
+	}
+	rules, err := compile(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+	validateStruct(rv, rules, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Type checks the `validate` tags of t, a struct or pointer to a struct,
+// so that callers can reject malformed rules when they register a type
+// rather than when the first value arrives
+func Type(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %s is not a struct", t.Kind())
+	}
+	_, err := compile(t)
+	return err
+}
+
+// rule is a parsed entry of a `validate` tag
+type rule struct {
+	name    string
+	arg     string
+	limit   float64
+	options []string
+}
+
+// fieldRules are the rules of one exported field
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+	// nested holds the rules of a struct or pointer to struct field
+	nested *structRules
+}
+
+type structRules struct {
+	fields []fieldRules
+}
+
+var (
+	mu    sync.Mutex
+	cache = map[reflect.Type]*structRules{}
+)
+
+// compile parses the tags of struct type t and its nested structs once
+func compile(t reflect.Type) (*structRules, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return compileLocked(t)
+}
+
+func compileLocked(t reflect.Type) (*structRules, error) {
+	if rules, ok := cache[t]; ok {
+		return rules, nil
+	}
+	// Cached before its fields so that recursive types terminate
+	rules := &structRules{}
+	cache[t] = rules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fr := fieldRules{index: i, name: fieldName(field)}
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			parsed, err := parseTag(field.Type, tag)
+			if err != nil {
+				delete(cache, t)
+				return nil, fmt.Errorf("validate: %s.%s: %w", t.Name(), field.Name, err)
+			}
+			fr.rules = parsed
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			nested, err := compileLocked(ft)
+			if err != nil {
+				delete(cache, t)
+				return nil, err
+			}
+			fr.nested = nested
+		}
+		rules.fields = append(rules.fields, fr)
+	}
+	return rules, nil
+}
+
+// parseTag parses the rules of a field of type t
+func parseTag(t reflect.Type, tag string) ([]rule, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var rules []rule
+	for _, entry := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		r := rule{name: name, arg: arg}
+		switch name {
+		case "":
+			continue
+		case "required":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s=%q", name, arg)
+			}
+			if !bounded(t.Kind()) {
+				return nil, fmt.Errorf("%s does not apply to %s", name, t.Kind())
+			}
+			r.limit = limit
+		case "oneof":
+			r.options = strings.Fields(arg)
+			if len(r.options) == 0 {
+				return nil, errors.New("oneof needs at least one option")
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// bounded reports whether min and max apply to values of kind
+func bounded(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// validateStruct appends the errors of every exported field of rv
+func validateStruct(rv reflect.Value, rules *structRules, prefix string, errs *Errors) {
+	for _, fr := range rules.fields {
+		name := prefix + fr.name
+		value := rv.Field(fr.index)
+
+		if msg := check(value, fr.rules); msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Error: msg})
+			continue
+		}
+
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if fr.nested != nil && value.Kind() == reflect.Struct {
+			validateStruct(value, fr.nested, name+".", errs)
+		}
+	}
+}
+
+// fieldName returns the JSON name of a field
+func fieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("json"); tag != "" {
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// check applies rules to value and returns the first failure
+func check(value reflect.Value, rules []rule) string {
+	for _, r := range rules {
+		if r.name == "required" {
+			if value.IsZero() {
+				return "is required"
+			}
+			continue
+		}
+		if value.Kind() == reflect.Pointer && value.IsNil() {
+			continue
+		}
+		switch r.name {
+		case "min", "max":
+			if msg := checkBound(reflect.Indirect(value), r); msg != "" {
+				return msg
+			}
+		case "oneof":
+			actual := fmt.Sprint(reflect.Indirect(value).Interface())
+			if !contains(r.options, actual) {
+				return "must be one of " + strings.Join(r.options, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+// checkBound compares a number, or the length of a string, slice or map,
+// against the limit of r
+func checkBound(value reflect.Value, r rule) string {
+	var actual float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		actual = float64(value.Len())
+		unit = " items"
+	}
+
+	if r.name == "min" && actual < r.limit {
+		return "must be at least " + r.arg + unit
+	}
+	if r.name == "max" && actual > r.limit {
+		return "must be at most " + r.arg + unit
+	}
+	return ""
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type order struct {
+	Flight  string            `json:"flight" validate:"required,min=3,max=6"`
+	Seats   int               `json:"seats" validate:"min=1,max=9"`
+	Class   string            `json:"class" validate:"oneof=economy business"`
+	Price   *float64          `json:"price" validate:"min=0"`
+	Tags    []string          `validate:"max=2"`
+	Extra   map[string]string `json:"-" validate:"max=1"`
+	Address *address          `json:"address"`
+	Billing address           `json:"billing"`
+}
+
+func TestStruct(t *testing.T) {
+	negative := -1.0
+	valid := func() order {
+		return order{Flight: "AI101", Seats: 2, Class: "economy", Billing: address{City: "Pune"}}
+	}
+	tests := []struct {
+		name   string
+		change func(o *order)
+		want   string
+	}{
+		{"valid", func(o *order) {}, ""},
+		{"required", func(o *order) { o.Flight = "" }, "flight is required"},
+		{"short string", func(o *order) { o.Flight = "AI" }, "flight must be at least 3 characters"},
+		{"long string", func(o *order) { o.Flight = "AI10123" }, "flight must be at most 6 characters"},
+		{"small number", func(o *order) { o.Seats = 0 }, "seats must be at least 1"},
+		{"large number", func(o *order) { o.Seats = 10 }, "seats must be at most 9"},
+		{"oneof", func(o *order) { o.Class = "first" }, "class must be one of economy, business"},
+		{"nil pointer skips bounds", func(o *order) { o.Price = nil }, ""},
+		{"pointer", func(o *order) { o.Price = &negative }, "price must be at least 0"},
+		{"slice", func(o *order) { o.Tags = []string{"a", "b", "c"} }, "Tags must be at most 2 items"},
+		{"json dash keeps the Go name", func(o *order) { o.Extra = map[string]string{"a": "", "b": ""} }, "Extra must be at most 1 items"},
+		{"nested struct", func(o *order) { o.Billing.City = "" }, "billing.city is required"},
+		{"nested pointer", func(o *order) { o.Address = &address{} }, "address.city is required"},
+		{"every field", func(o *order) { o.Flight, o.Seats = "", 0 }, "flight is required; seats must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := valid()
+			tt.change(&o)
+			err := Struct(&o)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Struct = %v, want nil", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Struct = %v, want Errors", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Struct = %q, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if err := Struct((*order)(nil)); err != nil {
+		t.Errorf("nil pointer: %v", err)
+	}
+	if err := Struct(3); err == nil {
+		t.Error("Struct accepted an int")
+	}
+}
+
+func TestMalformedTags(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"unknown rule", &struct {
+			Name string `validate:"requird"`
+		}{}, `unknown rule "requird"`},
+		{"bad bound", &struct {
+			Seats int `validate:"min=one"`
+		}{}, `invalid min="one"`},
+		{"bound on a bool", &struct {
+			Ok bool `validate:"max=1"`
+		}{}, "max does not apply to bool"},
+		{"empty oneof", &struct {
+			Class string `validate:"oneof="`
+		}{}, "oneof needs at least one option"},
+		{"nested", &struct {
+			Inner *struct {
+				Name string `validate:"nope"`
+			}
+		}{}, `unknown rule "nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Type(reflect.TypeOf(tt.v)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Type = %v, want an error containing %q", err, tt.want)
+			}
+			err := Struct(tt.v)
+			var errs Errors
+			if err == nil || errors.As(err, &errs) {
+				t.Fatalf("Struct = %v, want a tag error", err)
+			}
+		})
+	}
+}
+
+type node struct {
+	Name string `validate:"required"`
+	Next *node
+}
+
+func TestRecursiveType(t *testing.T) {
+	err := Struct(&node{Name: "a", Next: &node{}})
+	if err == nil || err.Error() != "Next.Name is required" {
+		t.Fatalf("Struct = %v", err)
+	}
+}
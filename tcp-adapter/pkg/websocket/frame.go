@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Opcodes defined by RFC 6455 section 5.2
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes used by the server (RFC 6455 section 7.4.1)
+const (
+	CloseNormal          = 1000
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+)
+
+// MaxFrameSize limits the payload size of a single frame
+const MaxFrameSize = 1 << 20
+
+var errFrameTooLarge = errors.New("websocket: frame too large")
+
+// frame is a single decoded WebSocket frame
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads one frame from r and unmasks its payload.
+// Client-to-server frames must be masked, so unmasked frames are rejected.
+func readFrame(r io.Reader) (*frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	f := &frame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0F,
+	}
+	if header[0]&0x70 != 0 {
+		return nil, fmt.Errorf("websocket: reserved bits set")
+	}
+
+	masked := header[1]&0x80 != 0
+	if !masked {
+		return nil, fmt.Errorf("websocket: client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if isControl(f.opcode) && (length > 125 || !f.fin) {
+		return nil, fmt.Errorf("websocket: invalid control frame")
+	}
+	if length > MaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return nil, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return nil, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	return f, nil
+}
+
+// writeFrame writes a single unmasked, final frame to w
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// closePayload builds the body of a close frame
+func closePayload(code int, reason string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+func isControl(opcode byte) bool {
+	return opcode&0x8 != 0
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"bytes"
+	"testing"
+)
+
+// maskedFrame builds a client frame for the seed corpus
+func maskedFrame(opcode byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	writeFrame(&buf, opcode, payload)
+	frame := buf.Bytes()
+
+	headerLen := len(frame) - len(payload)
+	mask := []byte{1, 2, 3, 4}
+	out := append([]byte{}, frame[:headerLen]...)
+	out[1] |= 0x80
+	out = append(out, mask...)
+	for i, b := range payload {
+		out = append(out, b^mask[i%4])
+	}
+	return out
+}
+
+func FuzzReadFrame(f *testing.F) {
+	f.Add(maskedFrame(opText, []byte("ECHO:hello")))
+	f.Add(maskedFrame(opPing, []byte("ping")))
+	f.Add(maskedFrame(opText, bytes.Repeat([]byte("a"), 300)))
+	f.Add([]byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o'})
+	f.Add([]byte{0x81, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := bytes.NewReader(data)
+		for {
+			fr, err := readFrame(reader)
+			if err != nil {
+				return
+			}
+			if len(fr.payload) > MaxFrameSize {
+				t.Fatalf("payload of %d bytes exceeds MaxFrameSize", len(fr.payload))
+			}
+			if isControl(fr.opcode) && (!fr.fin || len(fr.payload) > 125) {
+				t.Fatalf("accepted invalid control frame %+v", fr)
+			}
+		}
+	})
+}
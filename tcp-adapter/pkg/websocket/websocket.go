@@ -0,0 +1,306 @@
+// Package websocket implements the server side of RFC 6455 on top of
+// net/http connection hijacking.
+//
+// A Conn exposes a WebSocket as a net.Conn carrying newline-delimited
+// text, so the adapter's connection handler can serve browsers exactly
+// like raw TCP clients: every incoming text frame becomes one line and
+// every outgoing line becomes one text frame.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// acceptGUID is the fixed GUID from RFC 6455 section 1.3
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Conn is a server-side WebSocket connection that implements net.Conn
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// readBuf holds the remainder of the current text message
+	readBuf bytes.Buffer
+	// message accumulates fragments until the final frame arrives
+	message []byte
+
+	writeMu sync.Mutex
+	// pending holds a partial line until its newline is written
+	pending []byte
+
+	deadlineMu sync.Mutex
+	// writeDeadline is the caller's write deadline, restored after a
+	// close frame has been sent under a shorter one
+	writeDeadline time.Time
+
+	closeOnce sync.Once
+}
+
+// Options controls which handshakes Upgrade accepts
+type Options struct {
+	// AllowedOrigins lists browser origins such as https://app.example.com
+	// that may connect in addition to pages served from the endpoint's own
+	// host. "*" allows every origin.
+	AllowedOrigins []string
+}
+
+// Upgrade performs the opening handshake and hijacks the HTTP connection.
+// Requests from a browser page on another origin are refused with 403,
+// so a malicious site cannot drive a logged-in user's connection.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket: method %s not allowed", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	if origin := r.Header.Get("Origin"); !opts.allowsOrigin(origin, r.Host) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket: origin %q not allowed", origin)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid websocket key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack failed: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %w", err)
+	}
+
+	return &Conn{
+		conn:   netConn,
+		reader: rw.Reader,
+	}, nil
+}
+
+// allowsOrigin reports whether a handshake with the Origin header origin
+// may connect to host. Clients other than browsers send no Origin.
+func (o Options) allowsOrigin(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, host) {
+		return true
+	}
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the content of incoming text messages, each terminated by '\n'
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.readBuf.Len() == 0 {
+		if err := c.nextMessage(); err != nil {
+			return 0, err
+		}
+	}
+	return c.readBuf.Read(p)
+}
+
+// nextMessage reads frames until a complete text message is buffered
+func (c *Conn) nextMessage() error {
+	for {
+		f, err := readFrame(c.reader)
+		if err != nil {
+			if errors.Is(err, errFrameTooLarge) {
+				c.closeWithCode(CloseMessageTooBig, "frame too large")
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				c.closeWithCode(CloseProtocolError, "protocol error")
+			}
+			return err
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeControl(opPong, f.payload); err != nil {
+				return err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.closeWithCode(CloseNormal, "")
+			return io.EOF
+		case opBinary:
+			c.closeWithCode(CloseUnsupportedData, "text frames only")
+			return errors.New("websocket: binary frames are not supported")
+		case opText:
+			if c.message != nil {
+				c.closeWithCode(CloseProtocolError, "unexpected text frame")
+				return errors.New("websocket: new message before previous completed")
+			}
+			c.message = f.payload
+		case opContinuation:
+			if c.message == nil {
+				c.closeWithCode(CloseProtocolError, "unexpected continuation")
+				return errors.New("websocket: continuation without message")
+			}
+			if len(c.message)+len(f.payload) > MaxFrameSize {
+				c.closeWithCode(CloseMessageTooBig, "message too large")
+				return errFrameTooLarge
+			}
+			c.message = append(c.message, f.payload...)
+		default:
+			c.closeWithCode(CloseProtocolError, "unknown opcode")
+			return fmt.Errorf("websocket: unknown opcode %d", f.opcode)
+		}
+
+		if !f.fin {
+			continue
+		}
+
+		message := bytes.TrimRight(c.message, "\r\n")
+		c.message = nil
+		if !utf8.Valid(message) {
+			c.closeWithCode(CloseInvalidPayload, "invalid utf-8")
+			return errors.New("websocket: invalid utf-8 in text message")
+		}
+		// One text frame carries exactly one protocol message
+		if bytes.ContainsAny(message, "\r\n") {
+			c.closeWithCode(ClosePolicyViolation, "one message per frame")
+			return errors.New("websocket: text message contains a newline")
+		}
+		c.readBuf.Write(message)
+		c.readBuf.WriteByte('\n')
+		return nil
+	}
+}
+
+// Write sends every complete line in p as its own text frame.
+// A trailing partial line is held back until its newline is written.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.pending = append(c.pending, p...)
+	for {
+		idx := bytes.IndexByte(c.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.TrimRight(c.pending[:idx], "\r")
+		if err := writeFrame(c.conn, opText, line); err != nil {
+			return 0, err
+		}
+		c.pending = c.pending[idx+1:]
+	}
+	if len(c.pending) == 0 {
+		c.pending = nil
+	}
+	return len(p), nil
+}
+
+// writeControl sends a control frame
+func (c *Conn) writeControl(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeFrame(c.conn, opcode, payload)
+}
+
+// closeWithCode sends a close frame once; the underlying connection stays open
+func (c *Conn) closeWithCode(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.deadlineMu.Lock()
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.deadlineMu.Unlock()
+		c.writeControl(opClose, closePayload(code, reason))
+		c.deadlineMu.Lock()
+		c.conn.SetWriteDeadline(c.writeDeadline)
+		c.deadlineMu.Unlock()
+	})
+}
+
+// Close sends a normal close frame and closes the underlying connection
+func (c *Conn) Close() error {
+	c.closeWithCode(CloseNormal, "")
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetDeadline sets the read and write deadlines
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.writeDeadline = t
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// SetWriteDeadline sets the write deadline
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.writeDeadline = t
+	return c.conn.SetWriteDeadline(t)
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a client key
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains reports whether a comma-separated header includes token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAllowsOrigin(t *testing.T) {
+	opts := Options{AllowedOrigins: []string{"https://app.example.com/", " http://localhost:3000"}}
+	tests := []struct {
+		origin string
+		opts   Options
+		want   bool
+	}{
+		{"", Options{}, true},
+		{"http://gateway:8081", Options{}, true},
+		{"https://GATEWAY:8081", Options{}, true},
+		{"http://gateway", Options{}, false},
+		{"https://evil.example", Options{}, false},
+		{"null", Options{}, false},
+		{"https://app.example.com", opts, true},
+		{"http://localhost:3000", opts, true},
+		{"https://app.example.com:8443", opts, false},
+		{"https://evil.example", Options{AllowedOrigins: []string{"*"}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.allowsOrigin(tt.origin, "gateway:8081"); got != tt.want {
+			t.Errorf("allowsOrigin(%q) with %v = %v, want %v", tt.origin, tt.opts.AllowedOrigins, got, tt.want)
+		}
+	}
+}
+
+// deadlineConn records the write deadlines set on it
+type deadlineConn struct {
+	net.Conn
+	written   bytes.Buffer
+	deadlines []time.Time
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func TestCloseRestoresWriteDeadline(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		deadline time.Time
+	}{
+		{"none", time.Time{}},
+		{"caller's", later},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := &deadlineConn{}
+			c := &Conn{conn: raw}
+			if !tt.deadline.IsZero() {
+				c.SetWriteDeadline(tt.deadline)
+			}
+			c.closeWithCode(CloseNormal, "")
+			if raw.written.Len() == 0 {
+				t.Fatal("no close frame written")
+			}
+			if got := raw.deadlines[len(raw.deadlines)-1]; !got.Equal(tt.deadline) {
+				t.Errorf("write deadline after close = %v, want %v", got, tt.deadline)
+			}
+		})
+	}
+}